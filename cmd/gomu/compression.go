@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"log"
+
+	"../../pkg/compression"
+)
+
+// decompressAt decompresses the data starting at the offset, also returning the size it takes compressed
+func decompressAt(data []byte, offset uint) ([]byte, int, error) {
+	if offset >= uint(len(data)) {
+		return nil, 0, errors.New("the offset is past the end of the file")
+	}
+	reader := bytes.NewReader(data[offset:])
+	decompressed, err := compression.DecompressFrom(reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	size := int(reader.Size()) - reader.Len()
+	return decompressed, (size + 3) &^ 3, nil
+}
+
+// decompressCommand extracts compressed data, like graphics stored in a ROM
+func decompressCommand(args []string) {
+	flags := flag.NewFlagSet("decompress", flag.ExitOnError)
+	offset := flags.Uint("offset", 0, "where the compressed data starts in the input")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		usage()
+	}
+	inputPath, outputPath := flags.Arg(0), flags.Arg(1)
+
+	decompressed, size, err := decompressAt(readFile(inputPath), *offset)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%d bytes decompressed from %d compressed bytes at %#x\n", len(decompressed), size, *offset)
+	writeFile(outputPath, decompressed)
+}
+
+// compressCommand compresses a file, either into a new file or into an existing one at an offset,
+// which puts back data extracted with decompress
+func compressCommand(args []string) {
+	flags := flag.NewFlagSet("compress", flag.ExitOnError)
+	formatName := flags.String("format", "lz77", "compression format: lz77, lz77-vram, huffman4, huffman8, rle, diff8 or diff16")
+	offset := flags.Int("offset", -1, "where the compressed data is written into the existing output, replacing the compressed data there")
+	force := flags.Bool("force", false, "write at the offset even when the new data is bigger than the one it replaces, or when there's no valid compressed data there")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		usage()
+	}
+	inputPath, outputPath := flags.Arg(0), flags.Arg(1)
+
+	format, err := compression.ParseFormat(*formatName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	compressed, err := compression.Compress(format, readFile(inputPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%s compressed into %d bytes\n", inputPath, len(compressed))
+
+	if *offset < 0 {
+		writeFile(outputPath, compressed)
+		return
+	}
+
+	output := readFile(outputPath)
+	_, size, err := decompressAt(output, uint(*offset))
+	switch {
+	case err != nil && *force:
+		log.Printf("No valid compressed data at %#x (%v), overwriting it anyway\n", *offset, err)
+	case err != nil:
+		log.Fatalf("No valid compressed data at %#x (%v), use -force to overwrite it anyway", *offset, err)
+	case len(compressed) > size && !*force:
+		log.Fatalf("The compressed data takes %d bytes, but the data at %#x only %d, use -force to overwrite what follows it", len(compressed), *offset, size)
+	}
+	if *offset+len(compressed) > len(output) {
+		output = append(output, make([]byte, *offset+len(compressed)-len(output))...)
+	}
+	copy(output[*offset:], compressed)
+	writeFile(outputPath, output)
+}
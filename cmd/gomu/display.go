@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"../../pkg/display"
+	"../../pkg/filter"
+)
+
+// displayFlags are the flags shared by every command that shows or saves frames
+type displayFlags struct {
+	profile  *string
+	ghosting *float64
+	filter   *string
+	grid     *float64
+}
+
+func registerDisplayFlags(flags *flag.FlagSet) *displayFlags {
+	return &displayFlags{
+		profile:  flags.String("color-profile", "raw", "colors of the screen: raw, gba, ags-101 or gamma"),
+		ghosting: flags.Float64("ghosting", 0, "how much of the previous frame stays visible, from 0 to 1, like on the slow LCD"),
+		filter:   flags.String("filter", "none", "filter enlarging the frames: nearest<N>x, scale2x (or epx), scale3x or xbr2x"),
+		grid:     flags.Float64("lcd-grid", 0, "how much the gaps between the pixels are darkened, from 0 to 1, drawing the pixels 3 times bigger without a filter"),
+	}
+}
+
+// pipeline builds the pipeline processing the frames out of the flags
+func (displayFlags *displayFlags) pipeline() *display.Pipeline {
+	profile, err := display.ParseProfile(*displayFlags.profile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	scaler, err := filter.Parse(*displayFlags.filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return display.NewPipeline(display.Options{
+		Profile:  profile,
+		Ghosting: *displayFlags.ghosting,
+		Filter:   scaler,
+		Grid:     *displayFlags.grid,
+	})
+}
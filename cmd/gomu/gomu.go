@@ -1,9 +1,43 @@
 package main
 
 import (
-	"../../pkg/gba"
+	"fmt"
+	"os"
 )
 
+// commands maps the name of every command into the function running it with the remaining arguments
+var commands = map[string]func(args []string){
+	"run":        runCommand,
+	"patch":      patchCommand,
+	"compress":   compressCommand,
+	"decompress": decompressCommand,
+	"screenshot": screenshotCommand,
+	"record":     recordCommand,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  gomu run [flags] rom")
+	fmt.Fprintln(os.Stderr, "  gomu patch apply [flags] rom patch")
+	fmt.Fprintln(os.Stderr, "  gomu patch create [flags] original modified patch")
+	fmt.Fprintln(os.Stderr, "  gomu compress [flags] input output")
+	fmt.Fprintln(os.Stderr, "  gomu decompress [flags] input output")
+	fmt.Fprintln(os.Stderr, "  gomu screenshot [flags] rom output.png")
+	fmt.Fprintln(os.Stderr, "  gomu record [flags] rom output")
+	fmt.Fprintln(os.Stderr, "Run any command with -h to see its flags")
+	os.Exit(2)
+}
+
 func main() {
-	gba.InitializeROM("cartridge/game.GBA")
+	if len(os.Args) > 1 {
+		if command, found := commands[os.Args[1]]; found {
+			command(os.Args[2:])
+			return
+		}
+		if os.Args[1] == "help" {
+			usage()
+		}
+	}
+	// Without a command, the arguments are the ones of run
+	runCommand(os.Args[1:])
 }
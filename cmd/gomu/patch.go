@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"../../pkg/patch"
+)
+
+func patchCommand(args []string) {
+	if len(args) == 0 {
+		usage()
+	}
+	switch args[0] {
+	case "apply":
+		patchApplyCommand(args[1:])
+	case "create":
+		patchCreateCommand(args[1:])
+	default:
+		usage()
+	}
+}
+
+func readFile(path string) []byte {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return data
+}
+
+func writeFile(path string, data []byte) {
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Println(path, "written")
+}
+
+// patchApplyCommand writes a patched copy of a ROM
+func patchApplyCommand(args []string) {
+	flags := flag.NewFlagSet("patch apply", flag.ExitOnError)
+	outputPath := flags.String("o", "", "where the patched ROM is written (defaults to the patch name with the ROM extension)")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		usage()
+	}
+	romPath, patchPath := flags.Arg(0), flags.Arg(1)
+
+	if *outputPath == "" {
+		*outputPath = strings.TrimSuffix(patchPath, filepath.Ext(patchPath)) + filepath.Ext(romPath)
+	}
+	if *outputPath == romPath {
+		log.Fatal("Refusing to overwrite ", romPath, ", use -o to pick a different output")
+	}
+
+	patched, err := patch.Apply(readFile(romPath), readFile(patchPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	writeFile(*outputPath, patched)
+}
+
+// patchCreateCommand writes a patch that turns the original ROM into the modified one
+func patchCreateCommand(args []string) {
+	flags := flag.NewFlagSet("patch create", flag.ExitOnError)
+	formatName := flags.String("format", "", "format of the patch: ips, ups or bps (defaults to the patch extension)")
+	flags.Parse(args)
+	if flags.NArg() != 3 {
+		usage()
+	}
+	originalPath, modifiedPath, patchPath := flags.Arg(0), flags.Arg(1), flags.Arg(2)
+
+	format, err := patch.FormatFromPath(patchPath)
+	if *formatName != "" {
+		format, err = patch.ParseFormat(*formatName)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	patchData, err := patch.Create(format, readFile(originalPath), readFile(modifiedPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	writeFile(patchPath, patchData)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"../../pkg/display"
+	"../../pkg/gba"
+	"../../pkg/record"
+)
+
+// recordCommand runs a ROM without any display for a number of frames, writing them into a video
+func recordCommand(args []string) {
+	flags := flag.NewFlagSet("record", flag.ExitOnError)
+	coreFlags := registerCoreFlags(flags)
+	displayFlags := registerDisplayFlags(flags)
+	frames := flags.Uint("frames", 600, "number of frames recorded")
+	formatName := flags.String("format", "", "video format: y4m, raw, gif or apng (defaults to the one of the output extension, or y4m)")
+	audioPath := flags.String("audio", "", "WAV file the sound is written into")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		usage()
+	}
+	romPath, outputPath := flags.Arg(0), flags.Arg(1)
+
+	format, err := record.Y4M, error(nil)
+	if *formatName != "" {
+		format, err = record.ParseFormat(*formatName)
+	} else if outputPath != "-" {
+		format, err = record.FormatOf(outputPath)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The output - writes to the standard output, to pipe the streams into an encoder
+	var output io.Writer = os.Stdout
+	var outputFile, audioFile *os.File
+	if outputPath != "-" {
+		if outputFile, err = os.Create(outputPath); err != nil {
+			log.Fatal(err)
+		}
+		output = outputFile
+	}
+	buffered := bufio.NewWriter(output)
+	recorder, err := record.NewRecorder(format, buffered)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var wav *record.WAVWriter
+	if *audioPath != "" {
+		if audioFile, err = os.Create(*audioPath); err != nil {
+			log.Fatal(err)
+		}
+		if wav, err = record.NewWAVWriter(audioFile, gba.AudioSampleRate); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	core := gba.InitializeROM(romPath, coreFlags.options())
+	err = recordFrames(core, displayFlags.pipeline(), recorder, wav, *frames)
+
+	// Everything gets closed before reporting an error, leaving the files readable and the game saved
+	errs := []error{err, recorder.Close(), buffered.Flush()}
+	if outputFile != nil {
+		errs = append(errs, outputFile.Close())
+	}
+	if wav != nil {
+		errs = append(errs, wav.Close(), audioFile.Close())
+	}
+	core.Close()
+	for _, err := range errs {
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	log.Printf("%d frames recorded into %s\n", *frames, outputPath)
+}
+
+// recordFrames runs the console for a number of frames, writing them and their sound
+func recordFrames(core *gba.Core, pipeline *display.Pipeline, recorder record.Recorder, wav *record.WAVWriter, frames uint) error {
+	for i := uint(0); i < frames; i++ {
+		core.RunFrame()
+		if err := recorder.WriteFrame(pipeline.Process(core.Frame())); err != nil {
+			return err
+		}
+		if wav != nil {
+			if err := wav.WriteSamples(core.AudioSamples()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
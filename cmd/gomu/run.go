@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"../../pkg/gba"
+	"../../pkg/record"
+)
+
+// coreFlags are the flags shared by every command that emulates a ROM
+type coreFlags struct {
+	archiveEntry *string
+	saveDir      *string
+	saveInterval *time.Duration
+	flashChip    *string
+	overrides    *string
+	patchPath    *string
+	rtcTime      *string
+	rtcOffset    *time.Duration
+	lightLevel   *uint
+	multiboot    *bool
+	bios         *string
+	replacement  *bool
+}
+
+func registerCoreFlags(flags *flag.FlagSet) *coreFlags {
+	return &coreFlags{
+		archiveEntry: flags.String("entry", "", "name of the ROM picked from zip archives (defaults to the first .gba, .agb, .bin or .mb)"),
+		saveDir:      flags.String("save-dir", "", "directory where .sav files are kept (defaults to the ROM directory)"),
+		saveInterval: flags.Duration("save-interval", 5*time.Second, "how often the game progress is flushed into the .sav file"),
+		flashChip:    flags.String("flash-chip", "", "flash chip model answered to the game: panasonic, sst, macronix, macronix128 or sanyo"),
+		overrides:    flags.String("overrides", "", "JSON file with per game overrides (defaults to gomu/overrides.json in the user config directory)"),
+		patchPath:    flags.String("patch", "", "IPS, UPS or BPS patch applied to the ROM (defaults to a patch named after the ROM)"),
+		rtcTime:      flags.String("rtc-time", "", "fixed date and time answered by the cartridge clock, in RFC 3339 format"),
+		rtcOffset:    flags.Duration("rtc-offset", 0, "offset added to the host time answered by the cartridge clock"),
+		lightLevel:   flags.Uint("light-level", 128, "light hitting the solar sensor of the cartridge, from 0 to 255"),
+		bios:         flags.String("bios", "", "16KB BIOS dump to boot through, showing the boot logo (defaults to starting the game right away)"),
+		replacement:  flags.Bool("replacement-bios", false, "boot through the built in replacement BIOS when -bios isn't given, for games that need a BIOS"),
+		multiboot:    flags.Bool("multiboot", false, "run the file from EWRAM as a multiboot image (always done for .mb files)"),
+	}
+}
+
+// options builds the options of the emulator core out of the flags
+func (coreFlags *coreFlags) options() gba.Options {
+	var clock gba.Clock = gba.OffsetClock{Offset: *coreFlags.rtcOffset}
+	if *coreFlags.rtcTime != "" {
+		fixedTime, err := time.Parse(time.RFC3339, *coreFlags.rtcTime)
+		if err != nil {
+			log.Fatal(err)
+		}
+		clock = gba.FixedClock{Time: fixedTime}
+	}
+
+	if *coreFlags.lightLevel > 255 {
+		log.Fatalf("The light level goes from 0 to 255, not %d", *coreFlags.lightLevel)
+	}
+	sensors := new(gba.Sensors)
+	sensors.SetLightLevel(byte(*coreFlags.lightLevel))
+
+	return gba.Options{
+		ArchiveEntry:    *coreFlags.archiveEntry,
+		PatchPath:       *coreFlags.patchPath,
+		SaveDir:         *coreFlags.saveDir,
+		SaveInterval:    *coreFlags.saveInterval,
+		FlashChip:       *coreFlags.flashChip,
+		OverridesPath:   *coreFlags.overrides,
+		Clock:           clock,
+		LightSource:     sensors,
+		RotationSource:  sensors,
+		Rumble:          sensors,
+		Multiboot:       *coreFlags.multiboot,
+		BIOSPath:        *coreFlags.bios,
+		ReplacementBIOS: *coreFlags.replacement,
+	}
+}
+
+// frameDuration is the time every frame takes on the console
+const frameDuration = time.Second * record.FrameRateDenominator / record.FrameRateNumerator
+
+func runCommand(args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	coreFlags := registerCoreFlags(flags)
+	flags.Parse(args)
+
+	romPath := "cartridge/game.GBA"
+	if flags.NArg() > 0 {
+		romPath = flags.Arg(0)
+	}
+
+	// The console runs at its own pace until the emulator gets interrupted, the game progress being flushed
+	// periodically in the meantime and once more when closing
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	core := gba.InitializeROM(romPath, coreFlags.options())
+	defer core.Close()
+
+	frames := time.NewTicker(frameDuration)
+	defer frames.Stop()
+	for {
+		select {
+		case <-frames.C:
+			core.RunFrame()
+		case <-interrupts:
+			log.Println("Interrupted, closing")
+			return
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"image"
+	"image/png"
+	"log"
+	"os"
+
+	"../../pkg/gba"
+)
+
+// screenshotCommand runs a ROM without any display for a number of frames, and saves the last one as a PNG
+// the way the selected display shows it
+func screenshotCommand(args []string) {
+	flags := flag.NewFlagSet("screenshot", flag.ExitOnError)
+	coreFlags := registerCoreFlags(flags)
+	displayFlags := registerDisplayFlags(flags)
+	frames := flags.Uint("frames", 60, "number of frames emulated before taking the screenshot")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		usage()
+	}
+	romPath, outputPath := flags.Arg(0), flags.Arg(1)
+
+	pipeline := displayFlags.pipeline()
+	core := gba.InitializeROM(romPath, coreFlags.options())
+	// Every frame goes through the pipeline, which remembers the previous ones for the ghosting
+	screen := pipeline.Process(core.Frame())
+	for i := uint(0); i < *frames; i++ {
+		core.RunFrame()
+		screen = pipeline.Process(core.Frame())
+	}
+	// The core is closed before writing the screenshot, so that failing to write it still saves the game
+	core.Close()
+
+	if err := writePNG(outputPath, screen); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Screenshot written into", outputPath)
+}
+
+// writePNG encodes an image into a PNG file, reporting the errors of closing it which lose the last writes
+func writePNG(path string, screen image.Image) error {
+	output, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(output, screen); err != nil {
+		output.Close()
+		return err
+	}
+	return output.Close()
+}
@@ -9,6 +9,10 @@ type CPU struct {
 	CPUMode         int8
 	InstructionMode int8
 	Registers       RegisterSet
+	// SWIHandler services software interrupts in place of the BIOS, the exception is taken when it returns false
+	SWIHandler func(number byte) bool
+	// TrapHandler runs when the trap instruction is executed
+	TrapHandler func()
 }
 
 // RegisterSet envelops all different Registers from every CPU Mode
@@ -48,6 +52,17 @@ func (Registers *RegisterSet) Reset(usingBIOS bool) {
 	Registers.undRegisters.reset(usingBIOS)
 }
 
+// Reset puts the processor in its power on state, either at the reset vector of the BIOS
+// or at the start of the game as the BIOS leaves it
+func (cpu *CPU) Reset(usingBIOS bool) {
+	cpu.Registers.Reset(usingBIOS)
+	cpu.InstructionMode = ARM
+	cpu.CPUMode = SYS
+	if usingBIOS {
+		cpu.CPUMode = SVC
+	}
+}
+
 func (cpu *CPU) getRegister(register uint32) uint32 {
 	switch register {
 	case 0, 1, 2, 3, 4, 5, 6, 7, 15:
@@ -107,6 +122,26 @@ func (cpu *CPU) setRegister(register uint32, value uint32) {
 	}
 }
 
+// Register returns the value of a register as seen from the current CPU mode
+func (cpu *CPU) Register(register uint32) uint32 {
+	return cpu.getRegister(register)
+}
+
+// SetRegister changes the value of a register as seen from the current CPU mode
+func (cpu *CPU) SetRegister(register uint32, value uint32) {
+	cpu.setRegister(register, value)
+}
+
+// ProgramCounter returns the address of the instruction being executed
+func (cpu *CPU) ProgramCounter() uint32 {
+	return cpu.getRegister(15)
+}
+
+// SetProgramCounter moves the execution to the given address
+func (cpu *CPU) SetProgramCounter(address uint32) {
+	cpu.setRegister(15, address)
+}
+
 // BranchWithLink executes correspondent CPU instruction
 func (cpu *CPU) BranchWithLink(instruction []byte) {
 	// First, we correct the byte order of the opcode
@@ -19,6 +19,8 @@ func (suite *Arm7TestSuite) SetupTest() {
 	suite.cpu.Registers.svcRegisters.R13 = 0x25
 	suite.cpu.Registers.irqRegisters.R13 = 0x30
 	suite.cpu.Registers.sysRegisters.Cpsr = 0x35
+	suite.cpu.SWIHandler = nil
+	suite.cpu.TrapHandler = nil
 }
 
 func TestArm7TestSuite(t *testing.T) {
@@ -47,6 +49,19 @@ func (suite *Arm7TestSuite) TestRegisterResetWhenBootingFromBIOS() {
 	assert.Equal(suite.T(), uint32(0xD3), suite.cpu.Registers.sysRegisters.Cpsr)
 }
 
+func (suite *Arm7TestSuite) TestCPUResetSelectsTheBootMode() {
+	suite.cpu.Reset(true)
+	assert.Equal(suite.T(), SVC, suite.cpu.CPUMode)
+	assert.Equal(suite.T(), uint32(0x0), suite.cpu.ProgramCounter())
+
+	suite.cpu.Reset(false)
+	assert.Equal(suite.T(), SYS, suite.cpu.CPUMode)
+	assert.Equal(suite.T(), uint32(0x8000000), suite.cpu.ProgramCounter())
+
+	suite.cpu.SetProgramCounter(0x02000000)
+	assert.Equal(suite.T(), uint32(0x02000000), suite.cpu.ProgramCounter())
+}
+
 func (suite *Arm7TestSuite) TestGetGeneralPurposeRegister() {
 	suite.cpu.CPUMode = SYS
 	assert.Equal(suite.T(), uint32(0x5), suite.cpu.getRegister(0))
@@ -88,3 +103,69 @@ func (suite *Arm7TestSuite) TestBranchWithLink() {
 
 	suite.cpu.BranchWithLink([]byte{0x2E, 0x0, 0x0, 0xEA})
 }
+
+func (suite *Arm7TestSuite) TestSoftwareInterruptEntersSVCMode() {
+	suite.cpu.Reset(false)
+	suite.cpu.InstructionMode = THUMB
+	suite.cpu.Registers.sysRegisters.Cpsr = 0x3F
+	suite.cpu.SetProgramCounter(0x08000102)
+
+	suite.cpu.SoftwareInterrupt(0x05)
+	assert.Equal(suite.T(), SVC, suite.cpu.CPUMode)
+	assert.Equal(suite.T(), ARM, suite.cpu.InstructionMode)
+	assert.Equal(suite.T(), uint32(0x08), suite.cpu.ProgramCounter())
+	assert.Equal(suite.T(), uint32(0x08000102), suite.cpu.Registers.svcRegisters.R14)
+	assert.Equal(suite.T(), uint32(0x3F), suite.cpu.Registers.svcRegisters.Spsr)
+	assert.Equal(suite.T(), uint32(0x93), suite.cpu.Registers.sysRegisters.Cpsr)
+}
+
+func (suite *Arm7TestSuite) TestSoftwareInterruptHandler() {
+	suite.cpu.Reset(false)
+	var serviced byte
+	suite.cpu.SWIHandler = func(number byte) bool {
+		serviced = number
+		return true
+	}
+
+	suite.cpu.SoftwareInterrupt(0x06)
+	assert.Equal(suite.T(), byte(0x06), serviced)
+	assert.Equal(suite.T(), SYS, suite.cpu.CPUMode)
+	assert.Equal(suite.T(), uint32(0x8000000), suite.cpu.ProgramCounter())
+}
+
+func (suite *Arm7TestSuite) TestInterruptAndReturn() {
+	suite.cpu.Reset(false)
+	suite.cpu.InstructionMode = THUMB
+	suite.cpu.SetProgramCounter(0x08000200)
+
+	assert.True(suite.T(), suite.cpu.Interrupt())
+	assert.Equal(suite.T(), IRQ, suite.cpu.CPUMode)
+	assert.Equal(suite.T(), uint32(0x18), suite.cpu.ProgramCounter())
+	assert.Equal(suite.T(), uint32(0x08000204), suite.cpu.Registers.irqRegisters.R14)
+	// Interrupts are disabled while handling one
+	assert.False(suite.T(), suite.cpu.Interrupt())
+
+	suite.cpu.SetRegister(14, 0x08000200)
+	suite.cpu.ReturnFromException()
+	assert.Equal(suite.T(), SYS, suite.cpu.CPUMode)
+	assert.Equal(suite.T(), THUMB, suite.cpu.InstructionMode)
+	assert.Equal(suite.T(), uint32(0x7F), suite.cpu.Registers.sysRegisters.Cpsr)
+	assert.Equal(suite.T(), uint32(0x08000200), suite.cpu.ProgramCounter())
+}
+
+func (suite *Arm7TestSuite) TestUndefinedInstructions() {
+	suite.cpu.Reset(false)
+	trapped := false
+	suite.cpu.TrapHandler = func() {
+		trapped = true
+	}
+
+	suite.cpu.Undefined(TrapInstruction)
+	assert.True(suite.T(), trapped)
+	assert.Equal(suite.T(), SYS, suite.cpu.CPUMode)
+
+	suite.cpu.Undefined(0xE7F000F1)
+	assert.Equal(suite.T(), UND, suite.cpu.CPUMode)
+	assert.Equal(suite.T(), uint32(0x04), suite.cpu.ProgramCounter())
+	assert.Equal(suite.T(), uint32(0x8000000), suite.cpu.Registers.undRegisters.R14)
+}
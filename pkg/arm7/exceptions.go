@@ -0,0 +1,131 @@
+package arm7
+
+// Addresses of the exception vectors in the BIOS
+const (
+	vectorUndefined = 0x04
+	vectorSWI       = 0x08
+	vectorIRQ       = 0x18
+)
+
+// TrapInstruction is an undefined instruction the replacement BIOS runs to hand its calls over to the emulator
+const TrapInstruction = 0xE7F000F0
+
+// Bits of the CPSR
+const (
+	cpsrModeMask    = 0x1F
+	cpsrThumb       = 1 << 5
+	cpsrFIQDisabled = 1 << 6
+	cpsrIRQDisabled = 1 << 7
+)
+
+// Values of the mode bits of the CPSR for every CPU mode
+var cpsrModes = map[int8]uint32{
+	USR: 0x10,
+	FIQ: 0x11,
+	IRQ: 0x12,
+	SVC: 0x13,
+	ABT: 0x17,
+	UND: 0x1B,
+	SYS: 0x1F,
+}
+
+// SoftwareInterrupt takes the exception raised by the SWI instruction, the program counter must already
+// point to the instruction following it. A handler servicing the call skips the exception altogether.
+func (cpu *CPU) SoftwareInterrupt(number byte) {
+	if cpu.SWIHandler != nil && cpu.SWIHandler(number) {
+		return
+	}
+	cpu.enterException(SVC, vectorSWI, cpu.getRegister(15))
+}
+
+// Undefined takes the exception raised by an undefined instruction, the program counter must already point
+// to the instruction following it. The trap instruction goes to the trap handler instead, when there's one.
+func (cpu *CPU) Undefined(instruction uint32) {
+	if instruction == TrapInstruction && cpu.TrapHandler != nil {
+		cpu.TrapHandler()
+		return
+	}
+	cpu.enterException(UND, vectorUndefined, cpu.getRegister(15))
+}
+
+// Interrupt takes the IRQ exception, unless interrupts are disabled in the CPSR. The program counter must point
+// to the next instruction to execute, the handler returns to it with SUBS PC, LR, #4.
+func (cpu *CPU) Interrupt() bool {
+	if cpu.Registers.sysRegisters.Cpsr&cpsrIRQDisabled != 0 {
+		return false
+	}
+	cpu.enterException(IRQ, vectorIRQ, cpu.getRegister(15)+4)
+	return true
+}
+
+// ReturnFromException restores the status saved in the SPSR of the current mode and jumps to its link register
+func (cpu *CPU) ReturnFromException() {
+	returnAddress := cpu.getRegister(14)
+	cpsr := cpu.spsr()
+
+	cpu.Registers.sysRegisters.Cpsr = cpsr
+	for mode, bits := range cpsrModes {
+		if cpsr&cpsrModeMask == bits {
+			cpu.CPUMode = mode
+		}
+	}
+	cpu.InstructionMode = ARM
+	if cpsr&cpsrThumb != 0 {
+		cpu.InstructionMode = THUMB
+	}
+	cpu.setRegister(15, returnAddress)
+}
+
+// enterException switches into the mode of an exception, keeping the return address in its link register
+// and the interrupted status in its SPSR, and jumps to the exception vector in ARM state
+func (cpu *CPU) enterException(mode int8, vector uint32, returnAddress uint32) {
+	cpsr := cpu.Registers.sysRegisters.Cpsr &^ cpsrThumb
+	if cpu.InstructionMode == THUMB {
+		cpsr |= cpsrThumb
+	}
+
+	cpu.CPUMode = mode
+	cpu.setRegister(14, returnAddress)
+	cpu.setSPSR(cpsr)
+
+	cpsr = cpsr&^(cpsrModeMask|cpsrThumb) | cpsrModes[mode] | cpsrIRQDisabled
+	if mode == FIQ {
+		cpsr |= cpsrFIQDisabled
+	}
+	cpu.Registers.sysRegisters.Cpsr = cpsr
+	cpu.InstructionMode = ARM
+	cpu.setRegister(15, vector)
+}
+
+// spsr returns the saved program status register of the current CPU mode
+func (cpu *CPU) spsr() uint32 {
+	switch cpu.CPUMode {
+	case FIQ:
+		return cpu.Registers.fiqRegisters.Spsr
+	case SVC:
+		return cpu.Registers.svcRegisters.Spsr
+	case ABT:
+		return cpu.Registers.abtRegisters.Spsr
+	case IRQ:
+		return cpu.Registers.irqRegisters.Spsr
+	case UND:
+		return cpu.Registers.undRegisters.Spsr
+	}
+	return cpu.Registers.sysRegisters.Cpsr
+}
+
+// setSPSR changes the saved program status register of the current CPU mode
+func (cpu *CPU) setSPSR(value uint32) {
+	switch cpu.CPUMode {
+	case FIQ:
+		cpu.Registers.fiqRegisters.Spsr = value
+	case SVC:
+		cpu.Registers.svcRegisters.Spsr = value
+	case ABT:
+		cpu.Registers.abtRegisters.Spsr = value
+	case IRQ:
+		cpu.Registers.irqRegisters.Spsr = value
+	case UND:
+		cpu.Registers.undRegisters.Spsr = value
+	}
+}
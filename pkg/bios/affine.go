@@ -0,0 +1,56 @@
+package bios
+
+import "math"
+
+// rotationScale builds the matrix that scales by sx and sy after rotating by an angle, whose highest byte
+// goes from 0 to 255 for a whole turn
+func rotationScale(sx int16, sy int16, angle uint16) (float32, float32, float32, float32) {
+	theta := float64(angle>>8) / 128 * math.Pi
+	cos, sin := float32(math.Cos(theta)), float32(math.Sin(theta))
+	scaleX, scaleY := float32(sx)/256, float32(sy)/256
+	return cos * scaleX, -sin * scaleX, sin * scaleY, cos * scaleY
+}
+
+// callBgAffineSet computes the parameters of R2 rotated and scaled backgrounds, from the list of 20 bytes
+// entries in R0 into the list of 16 bytes entries in R1. Every entry holds the center of the rotation
+// in the background and on the screen, the scale and the angle.
+func callBgAffineSet(machine Machine) int {
+	source, destination, count := machine.Register(0), machine.Register(1), machine.Register(2)
+	for i := uint32(0); i < count; i++ {
+		originX := float32(int32(machine.Read32(source))) / 256
+		originY := float32(int32(machine.Read32(source+4))) / 256
+		centerX := float32(int16(machine.Read16(source + 8)))
+		centerY := float32(int16(machine.Read16(source + 10)))
+		a, b, c, d := rotationScale(int16(machine.Read16(source+12)), int16(machine.Read16(source+14)), machine.Read16(source+16))
+		source += 20
+
+		x := originX - (a*centerX + b*centerY)
+		y := originY - (c*centerX + d*centerY)
+		machine.Write16(destination, uint16(int32(a*256)))
+		machine.Write16(destination+2, uint16(int32(b*256)))
+		machine.Write16(destination+4, uint16(int32(c*256)))
+		machine.Write16(destination+6, uint16(int32(d*256)))
+		machine.Write32(destination+8, uint32(int32(x*256)))
+		machine.Write32(destination+12, uint32(int32(y*256)))
+		destination += 16
+	}
+	return 20 + 80*int(count)
+}
+
+// callObjAffineSet computes the parameters of R2 rotated and scaled sprites, from the list of 8 bytes entries
+// in R0 holding the scale and the angle. Every parameter is written R3 bytes apart into R1, 2 for a plain
+// list of parameters and 8 for writing them straight into OAM.
+func callObjAffineSet(machine Machine) int {
+	source, destination, count, stride := machine.Register(0), machine.Register(1), machine.Register(2), machine.Register(3)
+	for i := uint32(0); i < count; i++ {
+		a, b, c, d := rotationScale(int16(machine.Read16(source)), int16(machine.Read16(source+2)), machine.Read16(source+4))
+		source += 8
+
+		machine.Write16(destination, uint16(int32(a*256)))
+		machine.Write16(destination+stride, uint16(int32(b*256)))
+		machine.Write16(destination+stride*2, uint16(int32(c*256)))
+		machine.Write16(destination+stride*3, uint16(int32(d*256)))
+		destination += stride * 4
+	}
+	return 20 + 60*int(count)
+}
@@ -0,0 +1,140 @@
+package bios
+
+import (
+	"math"
+	"math/bits"
+)
+
+// callDiv divides R0 by R1, leaving the quotient in R0, the remainder in R1 and the absolute quotient in R3
+func callDiv(machine Machine) int {
+	return divide(machine, int32(machine.Register(0)), int32(machine.Register(1)))
+}
+
+// callDivArm is Div with the operands swapped, as the ARM compilers call it
+func callDivArm(machine Machine) int {
+	return divide(machine, int32(machine.Register(1)), int32(machine.Register(0)))
+}
+
+func divide(machine Machine, numerator int32, denominator int32) int {
+	if denominator == 0 {
+		// The real BIOS never returns, answer what it leaves in the registers once the loop is broken
+		quotient := int32(1)
+		if numerator < 0 {
+			quotient = -1
+		}
+		machine.SetRegister(0, uint32(quotient))
+		machine.SetRegister(1, uint32(numerator))
+		machine.SetRegister(3, 1)
+		return 20
+	}
+
+	quotient := numerator / denominator
+	machine.SetRegister(0, uint32(quotient))
+	machine.SetRegister(1, uint32(numerator%denominator))
+	machine.SetRegister(3, absolute(quotient))
+
+	// The BIOS divides one bit at a time, from the highest bit of the numerator down to the one of the denominator
+	loops := bits.LeadingZeros32(absolute(denominator)) - bits.LeadingZeros32(absolute(numerator))
+	if loops < 1 {
+		loops = 1
+	}
+	return 4 + 13*loops + 7
+}
+
+func absolute(value int32) uint32 {
+	if value < 0 {
+		return uint32(-value)
+	}
+	return uint32(value)
+}
+
+// callSqrt leaves the integer square root of R0 in R0
+func callSqrt(machine Machine) int {
+	value := machine.Register(0)
+	cycles := 10 + 8*bits.Len32(value)
+	root := uint32(0)
+	for bit := uint32(1) << 30; bit != 0; bit >>= 2 {
+		if value >= root+bit {
+			value -= root + bit
+			root = root>>1 + bit
+		} else {
+			root >>= 1
+		}
+	}
+	machine.SetRegister(0, root)
+	return cycles
+}
+
+// arcTangent approximates the arc tangent with the polynomial of the BIOS, the tangent and the angle are in 1.1.14
+// fixed point. Besides the angle, it returns the intermediate values the BIOS leaves in R1 and R3.
+func arcTangent(tangent int32) (int32, int32, int32) {
+	a := -((tangent * tangent) >> 14)
+	b := ((0xA9 * a) >> 14) + 0x390
+	b = ((b * a) >> 14) + 0x91C
+	b = ((b * a) >> 14) + 0xFB6
+	b = ((b * a) >> 14) + 0x16AA
+	b = ((b * a) >> 14) + 0x2081
+	b = ((b * a) >> 14) + 0x3651
+	b = ((b * a) >> 14) + 0xA2F9
+	return (tangent * b) >> 16, a, b
+}
+
+// callArcTan leaves the arc tangent of R0 in R0, from -0x4000 to 0x4000 for -π/2 to π/2
+func callArcTan(machine Machine) int {
+	angle, a, b := arcTangent(int32(machine.Register(0)))
+	machine.SetRegister(0, uint32(angle))
+	machine.SetRegister(1, uint32(a))
+	machine.SetRegister(3, uint32(b))
+	return 40
+}
+
+// callArcTan2 leaves in R0 the angle of the point with X in R0 and Y in R1, from 0 to 0xFFFF for 0 to 2π.
+// Like the BIOS it leaves the intermediate value of the arc tangent in R1, when it computes one, and 0x170 in R3.
+func callArcTan2(machine Machine) int {
+	x, y := int32(machine.Register(0)), int32(machine.Register(1))
+	var angle int32
+	r1 := y
+	switch {
+	case y == 0:
+		if x < 0 {
+			angle = 0x8000
+		}
+	case x == 0:
+		angle = 0x4000
+		if y < 0 {
+			angle = 0xC000
+		}
+	case y >= 0 && x >= 0 && x >= y:
+		angle, r1, _ = arcTangent((y << 14) / x)
+	case y >= 0 && x < 0 && -x >= y:
+		angle, r1, _ = arcTangent((y << 14) / x)
+		angle += 0x8000
+	case y >= 0:
+		angle, r1, _ = arcTangent((x << 14) / y)
+		angle = 0x4000 - angle
+	case x <= 0 && -x > -y:
+		angle, r1, _ = arcTangent((y << 14) / x)
+		angle += 0x8000
+	case x > 0 && x >= -y:
+		angle, r1, _ = arcTangent((y << 14) / x)
+		angle += 0x10000
+	default:
+		angle, r1, _ = arcTangent((x << 14) / y)
+		angle = 0xC000 - angle
+	}
+	// Angles just under a full turn round up to it, which wraps to 0
+	machine.SetRegister(0, uint32(angle)&0xFFFF)
+	machine.SetRegister(1, uint32(r1))
+	machine.SetRegister(3, 0x170)
+	return 60
+}
+
+// callMidiKey2Freq leaves in R0 the sample rate a wave recorded at the rate of the WaveData in R0 is played at
+// for the MIDI key in R1, with R2 adjusting it in 1/256 of a semitone
+func callMidiKey2Freq(machine Machine) int {
+	rate := float64(machine.Read32(machine.Register(0) + 4))
+	key := float64(machine.Register(1) & 0xFF)
+	fine := float64(machine.Register(2)&0xFF) / 256
+	machine.SetRegister(0, uint32(rate/math.Exp2((180-key-fine)/12)))
+	return 80
+}
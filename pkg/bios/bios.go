@@ -0,0 +1,115 @@
+// Package bios implements the calls of the Game Boy Advance BIOS in Go, for running games without a BIOS dump.
+// Every call reads its arguments from the registers and the memory of the console and leaves its results
+// in the same places the real BIOS does.
+package bios
+
+import (
+	"errors"
+	"fmt"
+
+	"../compression"
+)
+
+// Bus is the memory of the console as seen by the BIOS calls
+type Bus interface {
+	Read8(address uint32) byte
+	Read16(address uint32) uint16
+	Read32(address uint32) uint32
+	Write8(address uint32, value byte)
+	Write16(address uint32, value uint16)
+	Write32(address uint32, value uint32)
+}
+
+// Machine is the part of the console the BIOS calls work with
+type Machine interface {
+	Bus
+	Register(register uint32) uint32
+	SetRegister(register uint32, value uint32)
+	// Boot resets the processor as the BIOS leaves it after booting, starting at the given address
+	Boot(address uint32)
+	// RepeatCall makes the call run again once the processor is resumed, for calls waiting on interrupts
+	RepeatCall()
+}
+
+// ErrUnknownCall is returned for the calls that aren't implemented
+var ErrUnknownCall = errors.New("unknown BIOS call")
+
+// Numbers of the BIOS calls, as given to the SWI instruction
+const (
+	softReset         = 0x00
+	registerRAMReset  = 0x01
+	halt              = 0x02
+	stop              = 0x03
+	intrWait          = 0x04
+	vBlankIntrWait    = 0x05
+	div               = 0x06
+	divArm            = 0x07
+	sqrt              = 0x08
+	arcTan            = 0x09
+	arcTan2           = 0x0A
+	cpuSet            = 0x0B
+	cpuFastSet        = 0x0C
+	bgAffineSet       = 0x0E
+	objAffineSet      = 0x0F
+	bitUnPack         = 0x10
+	lz77UnCompWram    = 0x11
+	lz77UnCompVram    = 0x12
+	huffUnComp        = 0x13
+	rlUnCompWram      = 0x14
+	rlUnCompVram      = 0x15
+	diff8UnFilterWram = 0x16
+	diff8UnFilterVram = 0x17
+	diff16UnFilter    = 0x18
+	midiKey2Freq      = 0x1F
+)
+
+// Every call returns an estimate of the cycles the real BIOS takes to run it
+var calls = map[byte]func(machine Machine) int{
+	softReset:         callSoftReset,
+	registerRAMReset:  callRegisterRAMReset,
+	halt:              callHalt,
+	stop:              callStop,
+	div:               callDiv,
+	divArm:            callDivArm,
+	sqrt:              callSqrt,
+	arcTan:            callArcTan,
+	arcTan2:           callArcTan2,
+	cpuSet:            callCPUSet,
+	cpuFastSet:        callCPUFastSet,
+	bgAffineSet:       callBgAffineSet,
+	objAffineSet:      callObjAffineSet,
+	bitUnPack:         callBitUnPack,
+	lz77UnCompWram:    decompressCall(compression.DecompressLZ77, 1),
+	lz77UnCompVram:    decompressCall(compression.DecompressLZ77, 2),
+	huffUnComp:        decompressCall(compression.DecompressHuffman, 4),
+	rlUnCompWram:      decompressCall(compression.DecompressRLE, 1),
+	rlUnCompVram:      decompressCall(compression.DecompressRLE, 2),
+	diff8UnFilterWram: decompressCall(compression.UnfilterDiff8, 1),
+	diff8UnFilterVram: decompressCall(compression.UnfilterDiff8, 2),
+	diff16UnFilter:    decompressCall(compression.UnfilterDiff16, 2),
+	midiKey2Freq:      callMidiKey2Freq,
+}
+
+// HLE services the BIOS calls of a console
+type HLE struct {
+	// Set while IntrWait is repeated until the interrupts it waits for arrive
+	waiting bool
+}
+
+// Call runs the BIOS call with the given number, returning the cycles it takes
+func (hle *HLE) Call(machine Machine, number byte) (int, error) {
+	switch number {
+	case intrWait:
+		return hle.intrWait(machine, machine.Register(0) != 0, uint16(machine.Register(1))), nil
+	case vBlankIntrWait:
+		machine.SetRegister(0, 1)
+		machine.SetRegister(1, 1)
+		return hle.intrWait(machine, true, 1), nil
+	}
+
+	call, found := calls[number]
+	if !found {
+		return 0, fmt.Errorf("%w %02Xh", ErrUnknownCall, number)
+	}
+	return call(machine), nil
+}
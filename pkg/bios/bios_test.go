@@ -0,0 +1,263 @@
+package bios
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"../compression"
+)
+
+// testMachine is a console made of plain registers and a sparse memory
+type testMachine struct {
+	registers [16]uint32
+	memory    map[uint32]byte
+	booted    uint32
+	repeated  bool
+}
+
+func (machine *testMachine) Read8(address uint32) byte {
+	return machine.memory[address]
+}
+
+func (machine *testMachine) Read16(address uint32) uint16 {
+	return uint16(machine.Read8(address)) | uint16(machine.Read8(address+1))<<8
+}
+
+func (machine *testMachine) Read32(address uint32) uint32 {
+	return uint32(machine.Read16(address)) | uint32(machine.Read16(address+2))<<16
+}
+
+func (machine *testMachine) Write8(address uint32, value byte) {
+	machine.memory[address] = value
+}
+
+func (machine *testMachine) Write16(address uint32, value uint16) {
+	machine.Write8(address, byte(value))
+	machine.Write8(address+1, byte(value>>8))
+}
+
+func (machine *testMachine) Write32(address uint32, value uint32) {
+	machine.Write16(address, uint16(value))
+	machine.Write16(address+2, uint16(value>>16))
+}
+
+func (machine *testMachine) Register(register uint32) uint32 {
+	return machine.registers[register]
+}
+
+func (machine *testMachine) SetRegister(register uint32, value uint32) {
+	machine.registers[register] = value
+}
+
+func (machine *testMachine) Boot(address uint32) {
+	machine.booted = address
+}
+
+func (machine *testMachine) RepeatCall() {
+	machine.repeated = true
+}
+
+type BIOSTestSuite struct {
+	suite.Suite
+	machine *testMachine
+	hle     *HLE
+}
+
+func (suite *BIOSTestSuite) SetupTest() {
+	suite.machine = &testMachine{memory: make(map[uint32]byte)}
+	suite.hle = new(HLE)
+}
+
+func TestBIOSTestSuite(t *testing.T) {
+	suite.Run(t, new(BIOSTestSuite))
+}
+
+func (suite *BIOSTestSuite) call(number byte, registers ...uint32) {
+	copy(suite.machine.registers[:], registers)
+	_, err := suite.hle.Call(suite.machine, number)
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *BIOSTestSuite) TestUnknownCall() {
+	_, err := suite.hle.Call(suite.machine, 0x2A)
+	assert.True(suite.T(), errors.Is(err, ErrUnknownCall))
+}
+
+func (suite *BIOSTestSuite) TestDiv() {
+	suite.call(div, uint32(0xFFFFFFF9), 2)
+	assert.Equal(suite.T(), uint32(0xFFFFFFFD), suite.machine.registers[0])
+	assert.Equal(suite.T(), uint32(0xFFFFFFFF), suite.machine.registers[1])
+	assert.Equal(suite.T(), uint32(3), suite.machine.registers[3])
+
+	suite.call(divArm, 4, 100)
+	assert.Equal(suite.T(), uint32(25), suite.machine.registers[0])
+	assert.Equal(suite.T(), uint32(0), suite.machine.registers[1])
+}
+
+func (suite *BIOSTestSuite) TestSqrt() {
+	suite.call(sqrt, 1000000)
+	assert.Equal(suite.T(), uint32(1000), suite.machine.registers[0])
+	suite.call(sqrt, 0xFFFFFFFF)
+	assert.Equal(suite.T(), uint32(0xFFFF), suite.machine.registers[0])
+}
+
+func (suite *BIOSTestSuite) TestArcTan() {
+	// The tangent of π/4 is 1
+	suite.call(arcTan, 0x4000)
+	assert.InDelta(suite.T(), 0x2000, int32(suite.machine.registers[0]), 2)
+
+	suite.call(arcTan2, 0x100, 0x100)
+	assert.InDelta(suite.T(), 0x2000, suite.machine.registers[0], 2)
+	// R1 holds the negated square of the tangent, and R3 a constant
+	assert.Equal(suite.T(), uint32(0xFFFFC000), suite.machine.registers[1])
+	assert.Equal(suite.T(), uint32(0x170), suite.machine.registers[3])
+	suite.call(arcTan2, 0, uint32(0xFFFFFF00), 0, 0)
+	assert.Equal(suite.T(), uint32(0xC000), suite.machine.registers[0])
+	// Without computing an arc tangent R1 keeps Y
+	assert.Equal(suite.T(), uint32(0xFFFFFF00), suite.machine.registers[1])
+	assert.Equal(suite.T(), uint32(0x170), suite.machine.registers[3])
+	suite.call(arcTan2, uint32(0xFFFFFF00), uint32(0xFFFFFF00))
+	assert.InDelta(suite.T(), 0xA000, suite.machine.registers[0], 2)
+	// A tangent rounding to 0 below the X axis is a full turn, truncated to 16 bits
+	suite.call(arcTan2, 0x10000, uint32(0xFFFFFFFF))
+	assert.Equal(suite.T(), uint32(0), suite.machine.registers[0])
+}
+
+func (suite *BIOSTestSuite) TestCPUSet() {
+	suite.machine.Write32(0x1000, 0x11223344)
+	suite.machine.Write32(0x1004, 0x55667788)
+
+	suite.call(cpuSet, 0x1000, 0x2000, 2|setWords)
+	assert.Equal(suite.T(), uint32(0x55667788), suite.machine.Read32(0x2004))
+
+	suite.call(cpuSet, 0x1000, 0x3000, 3|setFill)
+	assert.Equal(suite.T(), uint16(0x3344), suite.machine.Read16(0x3004))
+	assert.Equal(suite.T(), uint16(0x0000), suite.machine.Read16(0x3006))
+}
+
+func (suite *BIOSTestSuite) TestCPUFastSetRoundsUpToBlocks() {
+	suite.machine.Write32(0x1000, 0xCAFEBABE)
+
+	suite.call(cpuFastSet, 0x1000, 0x2000, 1|setFill)
+	assert.Equal(suite.T(), uint32(0xCAFEBABE), suite.machine.Read32(0x201C))
+	assert.Equal(suite.T(), uint32(0x0), suite.machine.Read32(0x2020))
+}
+
+func (suite *BIOSTestSuite) TestBitUnPack() {
+	suite.machine.Write8(0x1000, 0x1B)
+	// 1 byte of 2 bit units into 8 bit units, adding 0x10 to the nonzero ones
+	suite.machine.Write16(0x1100, 1)
+	suite.machine.Write8(0x1102, 2)
+	suite.machine.Write8(0x1103, 8)
+	suite.machine.Write32(0x1104, 0x10)
+
+	suite.call(bitUnPack, 0x1000, 0x2000, 0x1100)
+	assert.Equal(suite.T(), uint32(0x00111213), suite.machine.Read32(0x2000))
+
+	suite.machine.Write32(0x1104, 0x80000010)
+	suite.call(bitUnPack, 0x1000, 0x2000, 0x1100)
+	assert.Equal(suite.T(), uint32(0x10111213), suite.machine.Read32(0x2000))
+}
+
+func (suite *BIOSTestSuite) TestObjAffineSetIdentity() {
+	suite.machine.Write16(0x1000, 0x100)
+	suite.machine.Write16(0x1002, 0x200)
+	suite.machine.Write16(0x1004, 0)
+
+	suite.call(objAffineSet, 0x1000, 0x2000, 1, 8)
+	assert.Equal(suite.T(), uint16(0x100), suite.machine.Read16(0x2000))
+	assert.Equal(suite.T(), uint16(0x000), suite.machine.Read16(0x2008))
+	assert.Equal(suite.T(), uint16(0x000), suite.machine.Read16(0x2010))
+	assert.Equal(suite.T(), uint16(0x200), suite.machine.Read16(0x2018))
+}
+
+func (suite *BIOSTestSuite) TestBgAffineSetQuarterTurn() {
+	// Origin at (16, 8) in the background, shown at (4, 2) on the screen
+	suite.machine.Write32(0x1000, 16<<8)
+	suite.machine.Write32(0x1004, 8<<8)
+	suite.machine.Write16(0x1008, 4)
+	suite.machine.Write16(0x100A, 2)
+	suite.machine.Write16(0x100C, 0x100)
+	suite.machine.Write16(0x100E, 0x100)
+	suite.machine.Write16(0x1010, 0x4000)
+
+	suite.call(bgAffineSet, 0x1000, 0x2000, 1)
+	assert.Equal(suite.T(), uint16(0x0000), suite.machine.Read16(0x2000))
+	assert.Equal(suite.T(), uint16(0xFF00), suite.machine.Read16(0x2002))
+	assert.Equal(suite.T(), uint16(0x0100), suite.machine.Read16(0x2004))
+	assert.Equal(suite.T(), uint16(0x0000), suite.machine.Read16(0x2006))
+	assert.Equal(suite.T(), uint32(18<<8), suite.machine.Read32(0x2008))
+	assert.Equal(suite.T(), uint32(4<<8), suite.machine.Read32(0x200C))
+}
+
+func (suite *BIOSTestSuite) TestMidiKey2Freq() {
+	suite.machine.Write32(0x1004, 13379<<10)
+
+	// Key 180 plays the sample at its recorded rate, 12 keys lower at half of it
+	suite.call(midiKey2Freq, 0x1000, 168, 0)
+	assert.Equal(suite.T(), uint32(13379<<9), suite.machine.registers[0])
+}
+
+func (suite *BIOSTestSuite) TestVBlankIntrWaitHaltsUntilTheInterrupt() {
+	suite.machine.Write16(biosInterruptFlags, 0x1)
+
+	// The interrupt acknowledged before the call is discarded
+	suite.call(vBlankIntrWait)
+	assert.True(suite.T(), suite.machine.repeated)
+	assert.Equal(suite.T(), uint16(0x0), suite.machine.Read16(biosInterruptFlags))
+	assert.Equal(suite.T(), uint16(0x1), suite.machine.Read16(ioStart+ioIME))
+
+	// Once the handler of the game acknowledges a new one the call returns
+	suite.machine.repeated = false
+	suite.machine.Write16(biosInterruptFlags, 0x1)
+	suite.call(vBlankIntrWait)
+	assert.False(suite.T(), suite.machine.repeated)
+	assert.Equal(suite.T(), uint16(0x0), suite.machine.Read16(biosInterruptFlags))
+}
+
+func (suite *BIOSTestSuite) TestIntrWaitReturnsForOldFlags() {
+	suite.machine.Write16(biosInterruptFlags, 0x5)
+
+	suite.call(intrWait, 0, 0x4)
+	assert.False(suite.T(), suite.machine.repeated)
+	assert.Equal(suite.T(), uint16(0x1), suite.machine.Read16(biosInterruptFlags))
+}
+
+func (suite *BIOSTestSuite) TestSoftReset() {
+	suite.machine.Write8(softResetTarget, 1)
+	suite.machine.Write32(iwramSystemArea, 0xFFFFFFFF)
+
+	suite.call(softReset)
+	assert.Equal(suite.T(), uint32(ewramStart), suite.machine.booted)
+	assert.Equal(suite.T(), uint32(0x0), suite.machine.Read32(iwramSystemArea))
+}
+
+func (suite *BIOSTestSuite) TestRegisterRAMReset() {
+	suite.machine.Write32(ewramStart+0x100, 0xFFFFFFFF)
+	suite.machine.Write32(iwramSystemArea, 0xFFFFFFFF)
+
+	suite.call(registerRAMReset, resetEWRAM|resetIWRAM)
+	assert.Equal(suite.T(), uint32(0x0), suite.machine.Read32(ewramStart+0x100))
+	assert.Equal(suite.T(), uint32(0xFFFFFFFF), suite.machine.Read32(iwramSystemArea))
+	assert.Equal(suite.T(), uint16(0x0080), suite.machine.Read16(ioStart+ioDISPCNT))
+}
+
+func (suite *BIOSTestSuite) TestDecompressionIntoVRAM() {
+	data := []byte("GOMU GOMU GOMU GOMU!")
+	compressed := compression.CompressLZ77(data, true)
+	for i, value := range compressed {
+		suite.machine.Write8(ewramStart+uint32(i), value)
+	}
+
+	suite.call(lz77UnCompVram, ewramStart, vramStart)
+	for i, value := range data {
+		assert.Equal(suite.T(), value, suite.machine.Read8(vramStart+uint32(i)))
+	}
+
+	// Data in the BIOS is left alone
+	suite.call(lz77UnCompWram, 0x0, 0x03000000)
+	assert.Equal(suite.T(), byte(0), suite.machine.Read8(0x03000000))
+}
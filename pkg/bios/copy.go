@@ -0,0 +1,91 @@
+package bios
+
+// Flags of the control word of CpuSet and CpuFastSet, the count takes the lowest 21 bits
+const (
+	setCountMask = 0x1FFFFF
+	setFill      = 1 << 24
+	setWords     = 1 << 26
+)
+
+// callCPUSet copies or fills R2 halfwords or words from R0 into R1
+func callCPUSet(machine Machine) int {
+	source, destination, control := machine.Register(0), machine.Register(1), machine.Register(2)
+	count := control & setCountMask
+
+	if control&setWords != 0 {
+		source, destination = source&^0x3, destination&^0x3
+		value := machine.Read32(source)
+		for i := uint32(0); i < count; i++ {
+			if control&setFill == 0 {
+				value = machine.Read32(source + i*4)
+			}
+			machine.Write32(destination+i*4, value)
+		}
+	} else {
+		source, destination = source&^0x1, destination&^0x1
+		value := machine.Read16(source)
+		for i := uint32(0); i < count; i++ {
+			if control&setFill == 0 {
+				value = machine.Read16(source + i*2)
+			}
+			machine.Write16(destination+i*2, value)
+		}
+	}
+	return 20 + 6*int(count)
+}
+
+// callCPUFastSet copies or fills R2 words from R0 into R1, in blocks of 8 words
+func callCPUFastSet(machine Machine) int {
+	source, destination, control := machine.Register(0)&^0x3, machine.Register(1)&^0x3, machine.Register(2)
+	count := (control&setCountMask + 7) &^ 7
+
+	value := machine.Read32(source)
+	for i := uint32(0); i < count; i++ {
+		if control&setFill == 0 {
+			value = machine.Read32(source + i*4)
+		}
+		machine.Write32(destination+i*4, value)
+	}
+	return 20 + 5*int(count/8)*4
+}
+
+// callBitUnPack widens the units of R0 into R1, as described by the header pointed by R2: the length of the
+// source in bytes, the widths of the source and destination units and an offset added to every unit.
+// The highest bit of the offset selects whether it's also added to the units equal to zero.
+func callBitUnPack(machine Machine) int {
+	source, destination, header := machine.Register(0), machine.Register(1), machine.Register(2)
+	length := uint32(machine.Read16(header))
+	sourceWidth := uint32(machine.Read8(header + 2))
+	destinationWidth := uint32(machine.Read8(header + 3))
+	offset := machine.Read32(header + 4)
+	zeroesOffset := offset&0x80000000 != 0
+	offset &= 0x7FFFFFFF
+
+	switch {
+	case sourceWidth != 1 && sourceWidth != 2 && sourceWidth != 4 && sourceWidth != 8:
+		return 20
+	case destinationWidth != 1 && destinationWidth != 2 && destinationWidth != 4 && destinationWidth != 8 &&
+		destinationWidth != 16 && destinationWidth != 32:
+		return 20
+	}
+
+	var buffer uint32
+	var bufferBits uint32
+	for i := uint32(0); i < length; i++ {
+		value := uint32(machine.Read8(source + i))
+		for bit := uint32(0); bit < 8; bit += sourceWidth {
+			unit := (value >> bit) & (1<<sourceWidth - 1)
+			if unit != 0 || zeroesOffset {
+				unit += offset
+			}
+			buffer |= unit << bufferBits
+			bufferBits += destinationWidth
+			if bufferBits == 32 {
+				machine.Write32(destination, buffer)
+				destination += 4
+				buffer, bufferBits = 0, 0
+			}
+		}
+	}
+	return 30 + 10*int(length*8/sourceWidth)
+}
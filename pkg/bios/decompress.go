@@ -0,0 +1,47 @@
+package bios
+
+import "io"
+
+// busReader reads compressed data straight from the memory of the console
+type busReader struct {
+	bus     Bus
+	address uint32
+}
+
+func (reader *busReader) ReadByte() (byte, error) {
+	value := reader.bus.Read8(reader.address)
+	reader.address++
+	return value, nil
+}
+
+// decompressCall makes the call decompressing the data in R0 into R1. The data is written in units of the given
+// size, VRAM can't be written a byte at a time, so the calls for it write halfwords, padding an odd last byte.
+func decompressCall(decompress func(source io.ByteReader) ([]byte, error), unitSize uint32) func(machine Machine) int {
+	return func(machine Machine) int {
+		source, destination := machine.Register(0), machine.Register(1)
+		// The BIOS refuses to read its own contents
+		if source < ewramStart {
+			return 20
+		}
+		data, err := decompress(&busReader{machine, source})
+		if err != nil {
+			return 20
+		}
+
+		for len(data)%int(unitSize) != 0 {
+			data = append(data, 0)
+		}
+		for offset := uint32(0); offset < uint32(len(data)); offset += unitSize {
+			switch unitSize {
+			case 1:
+				machine.Write8(destination+offset, data[offset])
+			case 2:
+				machine.Write16(destination+offset, uint16(data[offset])|uint16(data[offset+1])<<8)
+			case 4:
+				machine.Write32(destination+offset, uint32(data[offset])|uint32(data[offset+1])<<8|
+					uint32(data[offset+2])<<16|uint32(data[offset+3])<<24)
+			}
+		}
+		return 20 + 12*len(data)
+	}
+}
@@ -0,0 +1,143 @@
+package bios
+
+// Addresses used by the system calls
+const (
+	ewramStart = 0x02000000
+	ewramSize  = 0x40000
+	iwramStart = 0x03000000
+	// The top of IWRAM holds the stacks and the interrupt vectors, which the BIOS manages by itself
+	iwramSystemArea = 0x03007E00
+	iwramSystemSize = 0x200
+	// Flags of the interrupts the handler of the game has acknowledged
+	biosInterruptFlags = 0x03007FF8
+	// Nonzero when SoftReset must start the program in EWRAM instead of the cartridge
+	softResetTarget = 0x03007FFA
+	paletteStart    = 0x05000000
+	paletteSize     = 0x400
+	vramStart       = 0x06000000
+	vramSize        = 0x18000
+	oamStart        = 0x07000000
+	oamSize         = 0x400
+	ioStart         = 0x04000000
+	romStart        = 0x08000000
+)
+
+// Offsets of the I/O registers touched by the system calls
+const (
+	ioDISPCNT   = 0x000
+	ioSOUNDBIAS = 0x088
+	ioRCNT      = 0x134
+	ioIE        = 0x200
+	ioIF        = 0x202
+	ioIME       = 0x208
+	ioHALTCNT   = 0x301
+)
+
+// Flags of RegisterRamReset selecting what gets cleared
+const (
+	resetEWRAM = 1 << iota
+	resetIWRAM
+	resetPalette
+	resetVRAM
+	resetOAM
+	resetSIO
+	resetSound
+	resetRegisters
+)
+
+// fill clears a block of memory, returning the cycles it takes
+func fill(bus Bus, address uint32, size uint32) int {
+	for offset := uint32(0); offset < size; offset += 4 {
+		bus.Write32(address+offset, 0)
+	}
+	return int(size / 4)
+}
+
+// callSoftReset clears the system area of IWRAM and restarts the program, either from the cartridge
+// or from EWRAM as selected by the byte at 0x03007FFA
+func callSoftReset(machine Machine) int {
+	entryPoint := uint32(romStart)
+	if machine.Read8(softResetTarget) != 0 {
+		entryPoint = ewramStart
+	}
+	cycles := fill(machine, iwramSystemArea, iwramSystemSize)
+	machine.Boot(entryPoint)
+	return cycles
+}
+
+// callRegisterRAMReset clears the memory areas and the I/O registers selected by R0,
+// and always leaves the screen in forced blank
+func callRegisterRAMReset(machine Machine) int {
+	flags := machine.Register(0)
+	cycles := 0
+	if flags&resetEWRAM != 0 {
+		cycles += fill(machine, ewramStart, ewramSize)
+	}
+	if flags&resetIWRAM != 0 {
+		cycles += fill(machine, iwramStart, iwramSystemArea-iwramStart)
+	}
+	if flags&resetPalette != 0 {
+		cycles += fill(machine, paletteStart, paletteSize)
+	}
+	if flags&resetVRAM != 0 {
+		cycles += fill(machine, vramStart, vramSize)
+	}
+	if flags&resetOAM != 0 {
+		cycles += fill(machine, oamStart, oamSize)
+	}
+	if flags&resetSIO != 0 {
+		cycles += fill(machine, ioStart+0x120, 0x10)
+		machine.Write16(ioStart+ioRCNT, 0x8000)
+		cycles += fill(machine, ioStart+0x140, 0x04)
+		cycles += fill(machine, ioStart+0x150, 0x0C)
+	}
+	if flags&resetSound != 0 {
+		cycles += fill(machine, ioStart+0x060, ioSOUNDBIAS-0x060)
+		cycles += fill(machine, ioStart+0x090, 0x18)
+	}
+	if flags&resetRegisters != 0 {
+		cycles += fill(machine, ioStart, 0x060)
+		cycles += fill(machine, ioStart+0x0B0, 0x60)
+		machine.Write16(ioStart+ioIE, 0)
+		machine.Write16(ioStart+ioIF, 0xFFFF)
+		machine.Write32(ioStart+0x204, 0)
+		machine.Write16(ioStart+ioIME, 0)
+	}
+	machine.Write16(ioStart+ioDISPCNT, 0x0080)
+	return 20 + cycles
+}
+
+// callHalt stops the processor until an interrupt is requested
+func callHalt(machine Machine) int {
+	machine.Write8(ioStart+ioHALTCNT, 0x00)
+	return 10
+}
+
+// callStop turns off most of the console until a keypad, cartridge or serial interrupt is requested
+func callStop(machine Machine) int {
+	machine.Write8(ioStart+ioHALTCNT, 0x80)
+	return 10
+}
+
+// intrWait halts until one of the wanted interrupts is acknowledged by the handler of the game. Unless
+// discarded, the interrupts acknowledged before the call count as well. The call is repeated after every
+// interrupt until it's satisfied, which lets the handler of the game run in between.
+func (hle *HLE) intrWait(machine Machine, discard bool, wanted uint16) int {
+	machine.Write16(ioStart+ioIME, 1)
+
+	flags := machine.Read16(biosInterruptFlags)
+	if discard && !hle.waiting {
+		flags &^= wanted
+		machine.Write16(biosInterruptFlags, flags)
+	}
+	if flags&wanted != 0 {
+		machine.Write16(biosInterruptFlags, flags&^wanted)
+		hle.waiting = false
+		return 40
+	}
+
+	hle.waiting = true
+	machine.Write8(ioStart+ioHALTCNT, 0x00)
+	machine.RepeatCall()
+	return 40
+}
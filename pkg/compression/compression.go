@@ -0,0 +1,174 @@
+// Package compression implements the compression formats understood by the decompression calls of the
+// Game Boy Advance BIOS, LZ77, Huffman and run length encoding, along with the difference filters.
+// All of them start with a 4 bytes header holding the type of the data and its decompressed size.
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format identifies a compression format, along with the variant used when compressing
+type Format int
+
+// Constants for defining the supported formats
+const (
+	LZ77 Format = iota
+	// LZ77VRAM data is also LZ77, but safe to decompress straight into VRAM, which is written 16 bits at a time
+	LZ77VRAM
+	Huffman4
+	Huffman8
+	RLE
+	Diff8
+	Diff16
+)
+
+// Formats lists every supported format
+var Formats = []Format{LZ77, LZ77VRAM, Huffman4, Huffman8, RLE, Diff8, Diff16}
+
+var formatNames = map[Format]string{
+	LZ77:     "lz77",
+	LZ77VRAM: "lz77-vram",
+	Huffman4: "huffman4",
+	Huffman8: "huffman8",
+	RLE:      "rle",
+	Diff8:    "diff8",
+	Diff16:   "diff16",
+}
+
+// Types stored in the highest 4 bits of the first byte of the header
+const (
+	typeLZ77    = 0x1
+	typeHuffman = 0x2
+	typeRLE     = 0x3
+	typeDiff    = 0x8
+)
+
+// Size of the header, and the biggest decompressed size it can hold
+const (
+	headerSize = 4
+	maxSize    = 0xFFFFFF
+)
+
+// Errors returned when data can't be compressed or decompressed
+var (
+	ErrUnknownFormat = errors.New("unknown compression format")
+	ErrCorrupt       = errors.New("invalid or truncated compressed data")
+	ErrTooBig        = errors.New("the data is bigger than the 16MB the header can hold")
+)
+
+func (format Format) String() string {
+	return formatNames[format]
+}
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(name)
+	for format, formatName := range formatNames {
+		if formatName == name {
+			return format, nil
+		}
+	}
+	return 0, fmt.Errorf("%v: %s", ErrUnknownFormat, name)
+}
+
+// header is the start of compressed data, with the type in the highest 4 bits of the first byte,
+// a parameter of the type in its lowest 4 bits and the decompressed size in the following 3 bytes
+type header struct {
+	kind      byte
+	parameter byte
+	size      int
+}
+
+func readHeader(source io.ByteReader) (header, error) {
+	var data [headerSize]byte
+	for i := range data {
+		value, err := source.ReadByte()
+		if err != nil {
+			return header{}, ErrCorrupt
+		}
+		data[i] = value
+	}
+	return header{
+		kind:      data[0] >> 4,
+		parameter: data[0] & 0xF,
+		size:      int(data[1]) | int(data[2])<<8 | int(data[3])<<16,
+	}, nil
+}
+
+func writeHeader(buffer *bytes.Buffer, kind byte, parameter byte, size int) {
+	buffer.Write([]byte{kind<<4 | parameter, byte(size), byte(size >> 8), byte(size >> 16)})
+}
+
+// readByte reads from the compressed data, turning its end into a corruption error
+func readByte(source io.ByteReader) (byte, error) {
+	value, err := source.ReadByte()
+	if err != nil {
+		return 0, ErrCorrupt
+	}
+	return value, nil
+}
+
+// align pads the compressed data to a multiple of 4 bytes, since the BIOS expects it to be word aligned
+func align(buffer *bytes.Buffer) []byte {
+	for buffer.Len()%4 != 0 {
+		buffer.WriteByte(0)
+	}
+	return buffer.Bytes()
+}
+
+// Decompress decompresses data in any of the formats, detected by its header
+func Decompress(data []byte) ([]byte, error) {
+	return DecompressFrom(bytes.NewReader(data))
+}
+
+// DecompressFrom decompresses data in any of the formats, detected by its header, reading no further than its end
+func DecompressFrom(source io.ByteScanner) ([]byte, error) {
+	kind, err := source.ReadByte()
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	source.UnreadByte()
+
+	switch kind >> 4 {
+	case typeLZ77:
+		return DecompressLZ77(source)
+	case typeHuffman:
+		return DecompressHuffman(source)
+	case typeRLE:
+		return DecompressRLE(source)
+	case typeDiff:
+		if kind&0xF == 2 {
+			return UnfilterDiff16(source)
+		}
+		return UnfilterDiff8(source)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// Compress compresses data into the given format
+func Compress(format Format, data []byte) ([]byte, error) {
+	if len(data) > maxSize {
+		return nil, ErrTooBig
+	}
+	switch format {
+	case LZ77:
+		return CompressLZ77(data, false), nil
+	case LZ77VRAM:
+		return CompressLZ77(data, true), nil
+	case Huffman4:
+		return CompressHuffman(data, 4)
+	case Huffman8:
+		return CompressHuffman(data, 8)
+	case RLE:
+		return CompressRLE(data), nil
+	case Diff8:
+		return FilterDiff8(data), nil
+	case Diff16:
+		return FilterDiff16(data), nil
+	}
+	return nil, ErrUnknownFormat
+}
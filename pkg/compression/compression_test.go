@@ -0,0 +1,126 @@
+package compression
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type CompressionTestSuite struct {
+	suite.Suite
+	samples [][]byte
+}
+
+func (suite *CompressionTestSuite) SetupTest() {
+	random := rand.New(rand.NewSource(1))
+	noise := make([]byte, 3000)
+	random.Read(noise)
+	tiles := bytes.Repeat([]byte{0x11, 0x11, 0x21, 0x32, 0x00, 0x00, 0x00, 0x00, 0x45}, 300)
+	gradient := make([]byte, 1001)
+	for i := range gradient {
+		gradient[i] = byte(i / 7)
+	}
+
+	suite.samples = [][]byte{{}, {0x42}, bytes.Repeat([]byte{0x7}, 500), noise, tiles, gradient}
+}
+
+func TestCompressionTestSuite(t *testing.T) {
+	suite.Run(t, new(CompressionTestSuite))
+}
+
+func (suite *CompressionTestSuite) TestRoundTrip() {
+	for _, format := range Formats {
+		for _, sample := range suite.samples {
+			compressed, err := Compress(format, sample)
+			assert.NoError(suite.T(), err, format.String())
+			assert.Equal(suite.T(), 0, len(compressed)%4, format.String())
+
+			decompressed, err := Decompress(compressed)
+			assert.NoError(suite.T(), err, format.String())
+			expected := sample
+			if format == Diff16 {
+				expected = sample[:len(sample)&^0x1]
+			}
+			assert.Equal(suite.T(), len(expected), len(decompressed), format.String())
+			assert.True(suite.T(), bytes.Equal(expected, decompressed), format.String())
+		}
+	}
+}
+
+func (suite *CompressionTestSuite) TestHuffmanTreesOfEveryShape() {
+	random := rand.New(rand.NewSource(2))
+	for symbols := 2; symbols <= 256; symbols += 13 {
+		for _, skew := range []float64{0, 1, 3} {
+			data := make([]byte, 4000)
+			for i := range data {
+				// Skewed data makes deep and unbalanced trees
+				data[i] = byte(int(float64(symbols)*random.Float64()*(1-skew/4)+skew*random.ExpFloat64()) % symbols)
+			}
+			compressed, err := CompressHuffman(data, 8)
+			if assert.NoError(suite.T(), err) {
+				decompressed, err := Decompress(compressed)
+				assert.NoError(suite.T(), err)
+				assert.True(suite.T(), bytes.Equal(data, decompressed))
+			}
+		}
+	}
+}
+
+func (suite *CompressionTestSuite) TestDecompressLZ77() {
+	// "ab" followed by a reference copying 4 bytes from 2 bytes back
+	data := []byte{0x10, 0x06, 0x00, 0x00, 0x20, 'a', 'b', 0x10, 0x01}
+
+	decompressed, err := Decompress(data)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []byte("ababab"), decompressed)
+}
+
+func (suite *CompressionTestSuite) TestLZ77ForVRAMNeverRefersToThePreviousByte() {
+	compressed := CompressLZ77(bytes.Repeat([]byte{0x1}, 100), true)
+
+	reader := bytes.NewReader(compressed[headerSize:])
+	for decompressed := 0; decompressed < 100; {
+		flags, _ := reader.ReadByte()
+		for bit := 7; bit >= 0 && decompressed < 100; bit-- {
+			if flags&(1<<uint(bit)) == 0 {
+				reader.ReadByte()
+				decompressed++
+				continue
+			}
+			high, _ := reader.ReadByte()
+			low, _ := reader.ReadByte()
+			assert.NotEqual(suite.T(), 0, int(high&0xF)<<8|int(low))
+			decompressed += int(high>>4) + lz77MinLength
+		}
+	}
+	assert.True(suite.T(), len(compressed) < 40)
+}
+
+func (suite *CompressionTestSuite) TestDecompressRLE() {
+	data := []byte{0x30, 0x07, 0x00, 0x00, 0x81, 0xAA, 0x02, 0x01, 0x02, 0x03}
+
+	decompressed, err := Decompress(data)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []byte{0xAA, 0xAA, 0xAA, 0xAA, 0x01, 0x02, 0x03}, decompressed)
+}
+
+func (suite *CompressionTestSuite) TestTruncatedData() {
+	compressed, _ := Compress(LZ77, suite.samples[3])
+
+	_, err := Decompress(compressed[:len(compressed)/2])
+	assert.Equal(suite.T(), ErrCorrupt, err)
+	_, err = Decompress([]byte{0x50, 0x01, 0x00, 0x00})
+	assert.Equal(suite.T(), ErrUnknownFormat, err)
+}
+
+func (suite *CompressionTestSuite) TestParseFormat() {
+	format, err := ParseFormat("LZ77-VRAM")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), LZ77VRAM, format)
+
+	_, err = ParseFormat("zip")
+	assert.Error(suite.T(), err)
+}
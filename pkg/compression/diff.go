@@ -0,0 +1,85 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+)
+
+// Parameters of the difference filter header, the size of its units in bytes
+const (
+	diffUnits8  = 1
+	diffUnits16 = 2
+)
+
+// UnfilterDiff8 undoes the 8 bits difference filter, where every byte is stored as the difference from the previous one
+func UnfilterDiff8(source io.ByteReader) ([]byte, error) {
+	header, err := readHeader(source)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]byte, header.size)
+	var value byte
+	for i := range output {
+		difference, err := readByte(source)
+		if err != nil {
+			return nil, err
+		}
+		value += difference
+		output[i] = value
+	}
+	return output, nil
+}
+
+// UnfilterDiff16 undoes the 16 bits difference filter, where every halfword is stored as the difference
+// from the previous one
+func UnfilterDiff16(source io.ByteReader) ([]byte, error) {
+	header, err := readHeader(source)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]byte, header.size&^0x1)
+	var value uint16
+	for i := 0; i < len(output); i += 2 {
+		low, err := readByte(source)
+		if err != nil {
+			return nil, err
+		}
+		high, err := readByte(source)
+		if err != nil {
+			return nil, err
+		}
+		value += uint16(low) | uint16(high)<<8
+		output[i], output[i+1] = byte(value), byte(value>>8)
+	}
+	return output, nil
+}
+
+// FilterDiff8 stores every byte as the difference from the previous one, which helps compressing smooth data
+func FilterDiff8(data []byte) []byte {
+	buffer := new(bytes.Buffer)
+	writeHeader(buffer, typeDiff, diffUnits8, len(data))
+
+	var previous byte
+	for _, value := range data {
+		buffer.WriteByte(value - previous)
+		previous = value
+	}
+	return align(buffer)
+}
+
+// FilterDiff16 stores every halfword as the difference from the previous one, an odd last byte is dropped
+func FilterDiff16(data []byte) []byte {
+	buffer := new(bytes.Buffer)
+	writeHeader(buffer, typeDiff, diffUnits16, len(data)&^0x1)
+
+	var previous uint16
+	for i := 0; i+1 < len(data); i += 2 {
+		value := uint16(data[i]) | uint16(data[i+1])<<8
+		difference := value - previous
+		buffer.Write([]byte{byte(difference), byte(difference >> 8)})
+		previous = value
+	}
+	return align(buffer)
+}
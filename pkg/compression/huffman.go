@@ -0,0 +1,233 @@
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+)
+
+// Flags of the tree nodes telling whether a child is a data node, the lowest 6 bits hold the offset to the children
+const (
+	huffmanLeaf1     = 0x40
+	huffmanLeaf0     = 0x80
+	huffmanMaxOffset = 0x3F
+)
+
+// ErrHuffmanTree is returned when the tree of a Huffman code can't be laid out within the offsets of the format
+var ErrHuffmanTree = errors.New("the Huffman tree doesn't fit the format")
+
+// DecompressHuffman decompresses Huffman data, made of a tree followed by a stream of 32 bits words. Every code
+// is read from the highest bit of the words, walking the tree from its root until reaching a data node.
+// Units of 4 bits fill every byte starting from the lowest nibble.
+func DecompressHuffman(source io.ByteReader) ([]byte, error) {
+	header, err := readHeader(source)
+	if err != nil {
+		return nil, err
+	}
+	if header.parameter != 4 && header.parameter != 8 {
+		return nil, ErrCorrupt
+	}
+	unitBits := uint(header.parameter)
+
+	// The tree starts with its size, in halfwords minus one, followed by the root node
+	treeSize, err := readByte(source)
+	if err != nil {
+		return nil, err
+	}
+	tree := make([]byte, (int(treeSize)+1)*2)
+	for i := 1; i < len(tree); i++ {
+		if tree[i], err = readByte(source); err != nil {
+			return nil, err
+		}
+	}
+
+	output := make([]byte, 0, header.size)
+	var value byte
+	var valueBits uint
+	node, leaf := 1, false
+	for len(output) < header.size {
+		var word uint32
+		for i := uint(0); i < 32; i += 8 {
+			part, err := readByte(source)
+			if err != nil {
+				return nil, err
+			}
+			word |= uint32(part) << i
+		}
+
+		for bit := 31; bit >= 0 && len(output) < header.size; bit-- {
+			direction := int(word>>uint(bit)) & 0x1
+			children := node&^0x1 + int(tree[node]&huffmanMaxOffset)*2 + 2
+			leaf = tree[node]&(huffmanLeaf0>>uint(direction)) != 0
+			node = children + direction
+			if node >= len(tree) {
+				return nil, ErrCorrupt
+			}
+			if !leaf {
+				continue
+			}
+
+			value |= tree[node] << valueBits
+			valueBits += unitBits
+			if valueBits == 8 {
+				output = append(output, value)
+				value, valueBits = 0, 0
+			}
+			node = 1
+		}
+	}
+	return output, nil
+}
+
+// huffmanNode is a node of the tree built while compressing, the data nodes don't have children
+type huffmanNode struct {
+	value    byte
+	count    int
+	children [2]*huffmanNode
+}
+
+func (node *huffmanNode) isLeaf() bool {
+	return node.children[0] == nil
+}
+
+// codes collects the code of every data node under this one
+func (node *huffmanNode) codes(code uint32, length uint, codes map[byte][2]uint32) {
+	if node.isLeaf() {
+		codes[node.value] = [2]uint32{code, uint32(length)}
+		return
+	}
+	node.children[0].codes(code<<1, length+1, codes)
+	node.children[1].codes(code<<1|1, length+1, codes)
+}
+
+// CompressHuffman compresses data with a Huffman code of 4 or 8 bits units
+func CompressHuffman(data []byte, unitBits uint) ([]byte, error) {
+	var units []byte
+	for _, value := range data {
+		if unitBits == 4 {
+			units = append(units, value&0xF, value>>4)
+		} else {
+			units = append(units, value)
+		}
+	}
+
+	root := buildHuffmanTree(units)
+	tree, err := layoutHuffmanTree(root)
+	if err != nil {
+		return nil, err
+	}
+	codes := make(map[byte][2]uint32)
+	root.codes(0, 0, codes)
+
+	buffer := new(bytes.Buffer)
+	writeHeader(buffer, typeHuffman, byte(unitBits), len(data))
+	buffer.Write(tree)
+
+	var word uint32
+	var wordBits uint32
+	for _, unit := range units {
+		code := codes[unit]
+		for bit := int(code[1]) - 1; bit >= 0; bit-- {
+			word |= (code[0] >> uint(bit) & 0x1) << (31 - wordBits)
+			wordBits++
+			if wordBits == 32 {
+				buffer.Write([]byte{byte(word), byte(word >> 8), byte(word >> 16), byte(word >> 24)})
+				word, wordBits = 0, 0
+			}
+		}
+	}
+	if wordBits > 0 {
+		buffer.Write([]byte{byte(word), byte(word >> 8), byte(word >> 16), byte(word >> 24)})
+	}
+	return buffer.Bytes(), nil
+}
+
+// buildHuffmanTree joins the two least frequent nodes until a single one is left. The root always has two
+// children, so data made of a single unit gets it twice.
+func buildHuffmanTree(units []byte) *huffmanNode {
+	counts := make(map[byte]int)
+	for _, unit := range units {
+		counts[unit]++
+	}
+	var nodes []*huffmanNode
+	for value, count := range counts {
+		nodes = append(nodes, &huffmanNode{value: value, count: count})
+	}
+	// Sorting by value as well keeps the output the same on every run
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].count != nodes[j].count {
+			return nodes[i].count < nodes[j].count
+		}
+		return nodes[i].value < nodes[j].value
+	})
+
+	switch len(nodes) {
+	case 0:
+		leaf := &huffmanNode{}
+		return &huffmanNode{children: [2]*huffmanNode{leaf, leaf}}
+	case 1:
+		return &huffmanNode{children: [2]*huffmanNode{nodes[0], nodes[0]}}
+	}
+
+	for len(nodes) > 1 {
+		parent := &huffmanNode{count: nodes[0].count + nodes[1].count, children: [2]*huffmanNode{nodes[0], nodes[1]}}
+		nodes = nodes[2:]
+		position := sort.Search(len(nodes), func(i int) bool { return nodes[i].count > parent.count })
+		nodes = append(nodes, nil)
+		copy(nodes[position+1:], nodes[position:])
+		nodes[position] = parent
+	}
+	return nodes[0]
+}
+
+// layoutHuffmanTree stores the tree as the format expects it, where the children of a node are stored together
+// at most 63 halfwords after it. The children are laid out depth first, which keeps them close to their parent,
+// unless a node waiting for its children is about to run out of room.
+func layoutHuffmanTree(root *huffmanNode) ([]byte, error) {
+	type pendingNode struct {
+		node  *huffmanNode
+		index int
+	}
+
+	tree := []byte{0, 0}
+	pending := []pendingNode{{root, 1}}
+	for len(pending) > 0 {
+		children := len(tree)
+
+		next := len(pending) - 1
+		oldest := 0
+		for i := range pending {
+			if pending[i].index < pending[oldest].index {
+				oldest = i
+			}
+		}
+		if deadline := pending[oldest].index&^0x1 + huffmanMaxOffset*2 + 2; deadline-children < len(pending)*2 {
+			next = oldest
+		}
+		parent := pending[next]
+		pending = append(pending[:next], pending[next+1:]...)
+
+		offset := (children - parent.index&^0x1 - 2) / 2
+		if offset > huffmanMaxOffset {
+			return nil, ErrHuffmanTree
+		}
+		tree[parent.index] = byte(offset)
+		for direction, child := range parent.node.children {
+			if child.isLeaf() {
+				tree[parent.index] |= huffmanLeaf0 >> uint(direction)
+				tree = append(tree, child.value)
+			} else {
+				tree = append(tree, 0)
+				pending = append(pending, pendingNode{child, children + direction})
+			}
+		}
+	}
+
+	// The code stream that follows the tree must be word aligned
+	for len(tree)%4 != 0 {
+		tree = append(tree, 0)
+	}
+	tree[0] = byte(len(tree)/2 - 1)
+	return tree, nil
+}
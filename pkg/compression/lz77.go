@@ -0,0 +1,110 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+)
+
+// Limits of the references to previous data, 4 bits of length and 12 bits of displacement
+const (
+	lz77MinLength = 3
+	lz77MaxLength = 0xF + lz77MinLength
+	lz77Window    = 0x1000
+)
+
+// DecompressLZ77 decompresses LZ77 data, made of blocks of 8 elements preceded by a byte whose bits, from the
+// highest one, tell whether every element is a byte to copy or a reference to data already decompressed
+func DecompressLZ77(source io.ByteReader) ([]byte, error) {
+	header, err := readHeader(source)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]byte, 0, header.size)
+	for len(output) < header.size {
+		flags, err := readByte(source)
+		if err != nil {
+			return nil, err
+		}
+		for bit := 7; bit >= 0 && len(output) < header.size; bit-- {
+			if flags&(1<<uint(bit)) == 0 {
+				value, err := readByte(source)
+				if err != nil {
+					return nil, err
+				}
+				output = append(output, value)
+				continue
+			}
+
+			high, err := readByte(source)
+			if err != nil {
+				return nil, err
+			}
+			low, err := readByte(source)
+			if err != nil {
+				return nil, err
+			}
+			length := int(high>>4) + lz77MinLength
+			displacement := (int(high&0xF)<<8 | int(low)) + 1
+			if displacement > len(output) {
+				return nil, ErrCorrupt
+			}
+			// The reference may overlap with the bytes it produces, so it's copied one byte at a time
+			for i := 0; i < length && len(output) < header.size; i++ {
+				output = append(output, output[len(output)-displacement])
+			}
+		}
+	}
+	return output, nil
+}
+
+// CompressLZ77 compresses data into LZ77, always picking the longest reference available. Data safe for VRAM
+// never refers to the byte right before the one being decompressed, since VRAM is written 16 bits at a time
+// and that byte isn't there yet when decompressing into it.
+func CompressLZ77(data []byte, vramSafe bool) []byte {
+	buffer := new(bytes.Buffer)
+	writeHeader(buffer, typeLZ77, 0, len(data))
+
+	minDisplacement := 1
+	if vramSafe {
+		minDisplacement = 2
+	}
+
+	for offset := 0; offset < len(data); {
+		flagsOffset := buffer.Len()
+		buffer.WriteByte(0)
+		var flags byte
+		for bit := 7; bit >= 0 && offset < len(data); bit-- {
+			length, displacement := lz77LongestMatch(data, offset, minDisplacement)
+			if length < lz77MinLength {
+				buffer.WriteByte(data[offset])
+				offset++
+				continue
+			}
+			flags |= 1 << uint(bit)
+			value := (length-lz77MinLength)<<12 | (displacement - 1)
+			buffer.Write([]byte{byte(value >> 8), byte(value)})
+			offset += length
+		}
+		buffer.Bytes()[flagsOffset] = flags
+	}
+	return align(buffer)
+}
+
+// lz77LongestMatch looks for the longest run of already compressed bytes matching the ones at the offset
+func lz77LongestMatch(data []byte, offset int, minDisplacement int) (int, int) {
+	bestLength, bestDisplacement := 0, 0
+	for displacement := minDisplacement; displacement <= lz77Window && displacement <= offset; displacement++ {
+		length := 0
+		for length < lz77MaxLength && offset+length < len(data) && data[offset+length] == data[offset+length-displacement] {
+			length++
+		}
+		if length > bestLength {
+			bestLength, bestDisplacement = length, displacement
+			if length == lz77MaxLength {
+				break
+			}
+		}
+	}
+	return bestLength, bestDisplacement
+}
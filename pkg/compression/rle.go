@@ -0,0 +1,85 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+)
+
+// Limits of the runs, 7 bits of length with a different minimum for each kind of run
+const (
+	rleMinRun     = 3
+	rleMaxRun     = 0x7F + rleMinRun
+	rleMaxLiteral = 0x7F + 1
+	rleRunFlag    = 0x80
+)
+
+// DecompressRLE decompresses run length encoded data, made of runs of a repeated byte and runs of bytes to copy,
+// each one preceded by a byte with its kind in the highest bit and its length in the rest
+func DecompressRLE(source io.ByteReader) ([]byte, error) {
+	header, err := readHeader(source)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]byte, 0, header.size)
+	for len(output) < header.size {
+		flag, err := readByte(source)
+		if err != nil {
+			return nil, err
+		}
+		if flag&rleRunFlag != 0 {
+			value, err := readByte(source)
+			if err != nil {
+				return nil, err
+			}
+			for i := 0; i < int(flag&0x7F)+rleMinRun && len(output) < header.size; i++ {
+				output = append(output, value)
+			}
+			continue
+		}
+		for i := 0; i < int(flag&0x7F)+1 && len(output) < header.size; i++ {
+			value, err := readByte(source)
+			if err != nil {
+				return nil, err
+			}
+			output = append(output, value)
+		}
+	}
+	return output, nil
+}
+
+// CompressRLE compresses data with run length encoding
+func CompressRLE(data []byte) []byte {
+	buffer := new(bytes.Buffer)
+	writeHeader(buffer, typeRLE, 0, len(data))
+
+	literals := 0
+	flushLiterals := func(offset int) {
+		for literals > 0 {
+			length := literals
+			if length > rleMaxLiteral {
+				length = rleMaxLiteral
+			}
+			buffer.WriteByte(byte(length - 1))
+			buffer.Write(data[offset-literals : offset-literals+length])
+			literals -= length
+		}
+	}
+
+	for offset := 0; offset < len(data); {
+		run := 1
+		for run < rleMaxRun && offset+run < len(data) && data[offset+run] == data[offset] {
+			run++
+		}
+		if run < rleMinRun {
+			literals++
+			offset++
+			continue
+		}
+		flushLiterals(offset)
+		buffer.Write([]byte{rleRunFlag | byte(run-rleMinRun), data[offset]})
+		offset += run
+	}
+	flushLiterals(len(data))
+	return align(buffer)
+}
@@ -0,0 +1,119 @@
+// Package display turns the frames of the console into what its screen showed, correcting the colors and
+// simulating the slow response and the visible grid of the LCD
+package display
+
+import (
+	"image"
+	"image/color"
+	"sync"
+
+	"../filter"
+)
+
+// Options configure a pipeline
+type Options struct {
+	Profile Profile
+	// Ghosting is how much of the previous frame stays on the screen, from 0 to 1
+	Ghosting float64
+	// Filter enlarges the frames, nil keeps their size
+	Filter filter.Filter
+	// Grid is how much the edges of the pixels are darkened, from 0 to 1. Without a filter enlarging the
+	// frames, every pixel is drawn as a square of GridSize pixels, 3 by default.
+	Grid     float64
+	GridSize int
+}
+
+// Pipeline processes the frames of a session, in the order they are shown
+type Pipeline struct {
+	options  Options
+	palette  *[0x8000]color.RGBA
+	previous *image.RGBA
+}
+
+var (
+	palettes      = map[Profile]*[0x8000]color.RGBA{}
+	palettesMutex sync.Mutex
+)
+
+// NewPipeline creates a pipeline, the colors of every profile are only computed once
+func NewPipeline(options Options) *Pipeline {
+	palettesMutex.Lock()
+	defer palettesMutex.Unlock()
+	palette, found := palettes[options.Profile]
+	if !found {
+		palette = options.Profile.palette()
+		palettes[options.Profile] = palette
+	}
+	if options.Grid > 0 && options.GridSize < 2 {
+		options.GridSize = 3
+	}
+	return &Pipeline{options: options, palette: palette}
+}
+
+// Process turns the next frame into the image shown, the colors of the frame being the ones of the console
+func (pipeline *Pipeline) Process(frame image.Image) *image.RGBA {
+	bounds := frame.Bounds()
+	output := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			r, g, b, _ := frame.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			output.SetRGBA(x, y, pipeline.palette[r>>11|g>>11<<5|b>>11<<10])
+		}
+	}
+
+	if pipeline.options.Ghosting > 0 {
+		if pipeline.previous != nil && pipeline.previous.Bounds() == output.Bounds() {
+			mix(output.Pix, pipeline.previous.Pix, pipeline.options.Ghosting)
+		}
+		pipeline.previous = output
+		// The frame shown next blends with this one, so this one can't be touched by later steps
+		output = cloneRGBA(output)
+	}
+
+	factor := 1
+	if pipeline.options.Filter != nil {
+		factor = pipeline.options.Filter.Factor()
+		if factor > 1 {
+			output = pipeline.options.Filter.Apply(output)
+		}
+	}
+	if pipeline.options.Grid > 0 {
+		if factor < 2 {
+			factor = pipeline.options.GridSize
+			output = filter.Nearest(factor).Apply(output)
+		}
+		grid(output, factor, pipeline.options.Grid)
+	}
+	return output
+}
+
+// mix blends the previous pixels into the current ones
+func mix(current []byte, previous []byte, amount float64) {
+	weight := uint32(amount * 256)
+	for i := range current {
+		current[i] = byte((uint32(current[i])*(256-weight) + uint32(previous[i])*weight) >> 8)
+	}
+}
+
+func cloneRGBA(source *image.RGBA) *image.RGBA {
+	clone := image.NewRGBA(source.Bounds())
+	copy(clone.Pix, source.Pix)
+	return clone
+}
+
+// grid darkens the right and bottom edges of the squares of an enlarged image, like the gaps between the
+// pixels of an LCD
+func grid(output *image.RGBA, size int, amount float64) {
+	darken := uint32((1 - amount) * 256)
+	for y := 0; y < output.Rect.Dy(); y++ {
+		for x := 0; x < output.Rect.Dx(); x++ {
+			if x%size == size-1 || y%size == size-1 {
+				pixel := output.RGBAAt(x, y)
+				pixel.R = byte(uint32(pixel.R) * darken >> 8)
+				pixel.G = byte(uint32(pixel.G) * darken >> 8)
+				pixel.B = byte(uint32(pixel.B) * darken >> 8)
+				output.SetRGBA(x, y, pixel)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package display
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"../filter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DisplayTestSuite struct {
+	suite.Suite
+}
+
+func TestDisplayTestSuite(t *testing.T) {
+	suite.Run(t, new(DisplayTestSuite))
+}
+
+// solid returns a 2x2 image of a single color
+func solid(c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := 0; i < 4; i++ {
+		img.SetRGBA(i%2, i/2, c)
+	}
+	return img
+}
+
+func (suite *DisplayTestSuite) TestParseProfile() {
+	for _, profile := range Profiles {
+		parsed, err := ParseProfile(profile.String())
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), profile, parsed)
+	}
+	_, err := ParseProfile("crt")
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *DisplayTestSuite) TestRawProfile() {
+	output := NewPipeline(Options{}).Process(solid(color.RGBA{0xFF, 0x84, 0x00, 0xFF}))
+	assert.Equal(suite.T(), color.RGBA{0xFF, 0x84, 0x00, 0xFF}, output.RGBAAt(1, 1))
+}
+
+func (suite *DisplayTestSuite) TestSimulatedProfiles() {
+	// Black stays black, and every profile dims the white and the saturated colors
+	for _, profile := range []Profile{GBA, AGS101, Gamma} {
+		pipeline := NewPipeline(Options{Profile: profile})
+		assert.Equal(suite.T(), color.RGBA{0, 0, 0, 0xFF}, pipeline.Process(solid(color.RGBA{A: 0xFF})).RGBAAt(0, 0))
+
+		red := pipeline.Process(solid(color.RGBA{0x80, 0, 0, 0xFF})).RGBAAt(0, 0)
+		assert.True(suite.T(), red.R < 0x80, profile.String())
+	}
+
+	// The GBA LCD mixes the channels, desaturating the colors
+	red := NewPipeline(Options{Profile: GBA}).Process(solid(color.RGBA{0xFF, 0, 0, 0xFF})).RGBAAt(0, 0)
+	assert.True(suite.T(), red.G > 0 && red.B > 0)
+	white := NewPipeline(Options{Profile: Gamma}).Process(solid(color.RGBA{0xFF, 0xFF, 0xFF, 0xFF})).RGBAAt(0, 0)
+	assert.Equal(suite.T(), color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}, white)
+}
+
+func (suite *DisplayTestSuite) TestGhosting() {
+	pipeline := NewPipeline(Options{Ghosting: 0.5})
+	first := pipeline.Process(solid(color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}))
+	assert.Equal(suite.T(), color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}, first.RGBAAt(0, 0))
+
+	second := pipeline.Process(solid(color.RGBA{A: 0xFF}))
+	assert.Equal(suite.T(), color.RGBA{0x7F, 0x7F, 0x7F, 0xFF}, second.RGBAAt(0, 0))
+	third := pipeline.Process(solid(color.RGBA{A: 0xFF}))
+	assert.Equal(suite.T(), color.RGBA{0x3F, 0x3F, 0x3F, 0xFF}, third.RGBAAt(0, 0))
+}
+
+func (suite *DisplayTestSuite) TestGrid() {
+	output := NewPipeline(Options{Grid: 0.5}).Process(solid(color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}))
+	assert.Equal(suite.T(), image.Rect(0, 0, 6, 6), output.Bounds())
+	assert.Equal(suite.T(), color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}, output.RGBAAt(1, 1))
+	assert.Equal(suite.T(), color.RGBA{0x7F, 0x7F, 0x7F, 0xFF}, output.RGBAAt(2, 1))
+	assert.Equal(suite.T(), color.RGBA{0x7F, 0x7F, 0x7F, 0xFF}, output.RGBAAt(4, 5))
+}
+
+func (suite *DisplayTestSuite) TestFilter() {
+	output := NewPipeline(Options{Filter: filter.Scale3x}).Process(solid(color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}))
+	assert.Equal(suite.T(), image.Rect(0, 0, 6, 6), output.Bounds())
+
+	// The grid follows the pixels enlarged by the filter
+	output = NewPipeline(Options{Filter: filter.Nearest(2), Grid: 0.5}).Process(solid(color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}))
+	assert.Equal(suite.T(), image.Rect(0, 0, 4, 4), output.Bounds())
+	assert.Equal(suite.T(), color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}, output.RGBAAt(0, 0))
+	assert.Equal(suite.T(), color.RGBA{0x7F, 0x7F, 0x7F, 0xFF}, output.RGBAAt(1, 0))
+}
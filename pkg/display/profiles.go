@@ -0,0 +1,131 @@
+package display
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Profile selects how the colors of the console are turned into the colors of a modern screen
+type Profile int
+
+// Constants for defining the supported profiles
+const (
+	// Raw scales the channels from 5 to 8 bits, which looks more vivid than any real console
+	Raw Profile = iota
+	// GBA mimics the dim and desaturated reflective LCD of the original console
+	GBA
+	// AGS101 mimics the backlit LCD of the later Game Boy Advance SP
+	AGS101
+	// Gamma only compensates the gamma of the LCD, keeping the hues
+	Gamma
+)
+
+// Profiles lists every supported profile
+var Profiles = []Profile{Raw, GBA, AGS101, Gamma}
+
+var profileNames = map[Profile]string{
+	Raw:    "raw",
+	GBA:    "gba",
+	AGS101: "ags-101",
+	Gamma:  "gamma",
+}
+
+// ErrUnknownProfile is returned when parsing the name of a profile that doesn't exist
+var ErrUnknownProfile = errors.New("unknown color profile")
+
+func (profile Profile) String() string {
+	return profileNames[profile]
+}
+
+// ParseProfile returns the profile with the given name
+func ParseProfile(name string) (Profile, error) {
+	name = strings.ToLower(name)
+	for profile, profileName := range profileNames {
+		if profileName == name {
+			return profile, nil
+		}
+	}
+	return 0, fmt.Errorf("%v: %s", ErrUnknownProfile, name)
+}
+
+// Gamma of the LCDs, and of the screens the colors are shown on
+const (
+	lcdGamma     = 4.0
+	displayGamma = 2.2
+)
+
+// screen describes the response of an LCD: the gamma of its channels, its brightness, and how much every
+// channel of the console leaks into the red, green and blue seen on the screen
+type screen struct {
+	gamma     float64
+	luminance float64
+	matrix    [3][3]float64
+}
+
+var screens = map[Profile]screen{
+	GBA: {
+		gamma:     displayGamma + 1,
+		luminance: 0.94,
+		matrix: [3][3]float64{
+			{0.82, 0.24, -0.06},
+			{0.125, 0.665, 0.21},
+			{0.195, 0.075, 0.73},
+		},
+	},
+	AGS101: {
+		gamma:     displayGamma,
+		luminance: 0.94,
+		matrix: [3][3]float64{
+			{0.86, 0.19, -0.05},
+			{0.11, 0.66, 0.23},
+			{0.1325, 0.0575, 0.81},
+		},
+	},
+	Gamma: {
+		gamma:     lcdGamma,
+		luminance: 1,
+		matrix: [3][3]float64{
+			{1, 0, 0},
+			{0, 1, 0},
+			{0, 0, 1},
+		},
+	},
+}
+
+// palette returns the color shown for every color of the console, indexed by their BGR555 value
+func (profile Profile) palette() *[0x8000]color.RGBA {
+	palette := new([0x8000]color.RGBA)
+	screen, simulated := screens[profile]
+	for value := range palette {
+		channels := [3]float64{}
+		for i := range channels {
+			channels[i] = float64(value>>(5*uint(i))&0x1F) / 31
+		}
+		if !simulated {
+			palette[value] = color.RGBA{expand(value), expand(value >> 5), expand(value >> 10), 0xFF}
+			continue
+		}
+
+		// Light emitted by the LCD, mixed by the matrix and encoded again for the screen
+		for i := range channels {
+			channels[i] = math.Min(math.Pow(channels[i], screen.gamma)*screen.luminance, 1)
+		}
+		var output [3]uint8
+		for i, row := range screen.matrix {
+			mixed := row[0]*channels[0] + row[1]*channels[1] + row[2]*channels[2]
+			mixed = math.Max(0, math.Min(mixed, 1))
+			output[i] = uint8(math.Round(math.Pow(mixed, 1/displayGamma) * 0xFF))
+		}
+		palette[value] = color.RGBA{output[0], output[1], output[2], 0xFF}
+	}
+	return palette
+}
+
+// expand scales a 5 bits channel to 8 bits
+func expand(channel int) uint8 {
+	channel &= 0x1F
+	return uint8(channel<<3 | channel>>2)
+}
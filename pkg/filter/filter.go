@@ -0,0 +1,137 @@
+// Package filter implements filters enlarging the frames of the console by a whole factor, from plain nearest
+// neighbour scaling to filters smoothing the edges of pixel art like Scale2x and xBR
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Filter enlarges images by a whole factor
+type Filter interface {
+	// Factor is how many times bigger the images get
+	Factor() int
+	Apply(source *image.RGBA) *image.RGBA
+	String() string
+}
+
+// Filters that don't need any parameter
+var (
+	// Scale2x doubles the size rounding the diagonal edges, it's also known as EPX
+	Scale2x Filter = scale2x{}
+	Scale3x Filter = scale3x{}
+	// XBR2x doubles the size blending along the edges it detects
+	XBR2x Filter = xbr2x{}
+)
+
+// Names lists the names of the filters, nearest stands for any nearest<N>x
+var Names = []string{"nearest<N>x", "scale2x", "epx", "scale3x", "xbr2x"}
+
+// ErrUnknownFilter is returned when parsing the name of a filter that doesn't exist
+var ErrUnknownFilter = errors.New("unknown filter")
+
+var nearestName = regexp.MustCompile(`^nearest([1-9][0-9]?)x$`)
+
+// Parse returns the filter with the given name, an empty name or none is the same as nearest1x
+func Parse(name string) (Filter, error) {
+	name = strings.ToLower(name)
+	switch name {
+	case "", "none":
+		return Nearest(1), nil
+	case "scale2x", "epx":
+		return Scale2x, nil
+	case "scale3x":
+		return Scale3x, nil
+	case "xbr2x":
+		return XBR2x, nil
+	}
+	if match := nearestName.FindStringSubmatch(name); match != nil {
+		factor, _ := strconv.Atoi(match[1])
+		return Nearest(factor), nil
+	}
+	return nil, fmt.Errorf("%v: %s", ErrUnknownFilter, name)
+}
+
+// nearest repeats every pixel into a square
+type nearest int
+
+// Nearest returns a filter repeating every pixel factor times in both directions
+func Nearest(factor int) Filter {
+	if factor < 1 {
+		factor = 1
+	}
+	return nearest(factor)
+}
+
+func (filter nearest) Factor() int {
+	return int(filter)
+}
+
+func (filter nearest) String() string {
+	return fmt.Sprintf("nearest%dx", int(filter))
+}
+
+func (filter nearest) Apply(source *image.RGBA) *image.RGBA {
+	factor := int(filter)
+	output := newOutput(source, factor)
+	width := source.Rect.Dx()
+	for y := 0; y < source.Rect.Dy(); y++ {
+		row := output.Pix[y*factor*output.Stride : y*factor*output.Stride+width*factor*4]
+		for x := 0; x < width; x++ {
+			pixel := source.Pix[source.PixOffset(source.Rect.Min.X+x, source.Rect.Min.Y+y):][:4]
+			for i := 0; i < factor; i++ {
+				copy(row[(x*factor+i)*4:], pixel)
+			}
+		}
+		for i := 1; i < factor; i++ {
+			copy(output.Pix[(y*factor+i)*output.Stride:], row)
+		}
+	}
+	return output
+}
+
+// newOutput creates the image a filter writes into, starting at the origin
+func newOutput(source *image.RGBA, factor int) *image.RGBA {
+	return image.NewRGBA(image.Rect(0, 0, source.Rect.Dx()*factor, source.Rect.Dy()*factor))
+}
+
+// neighbourhood reads the pixels around the one being scaled, repeating the pixels on the edges of the image
+type neighbourhood struct {
+	source *image.RGBA
+	x, y   int
+}
+
+func (pixels *neighbourhood) at(dx, dy int) color.RGBA {
+	bounds := pixels.source.Rect
+	x, y := clamp(pixels.x+dx, bounds.Min.X, bounds.Max.X-1), clamp(pixels.y+dy, bounds.Min.Y, bounds.Max.Y-1)
+	return pixels.source.RGBAAt(x, y)
+}
+
+func clamp(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+// scaleEach calls a function for every pixel of the source, with its neighbourhood and the position of the
+// square it becomes in the output
+func scaleEach(source *image.RGBA, factor int, scale func(pixels *neighbourhood, output *image.RGBA, x, y int)) *image.RGBA {
+	output := newOutput(source, factor)
+	pixels := &neighbourhood{source: source}
+	for y := 0; y < source.Rect.Dy(); y++ {
+		for x := 0; x < source.Rect.Dx(); x++ {
+			pixels.x, pixels.y = source.Rect.Min.X+x, source.Rect.Min.Y+y
+			scale(pixels, output, x*factor, y*factor)
+		}
+	}
+	return output
+}
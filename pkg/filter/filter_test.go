@@ -0,0 +1,118 @@
+package filter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type FilterTestSuite struct {
+	suite.Suite
+}
+
+func TestFilterTestSuite(t *testing.T) {
+	suite.Run(t, new(FilterTestSuite))
+}
+
+var (
+	black = color.RGBA{0, 0, 0, 0xFF}
+	white = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+// picture builds an image out of rows of characters, # for black and anything else for white
+func picture(rows ...string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, pixel := range row {
+			if pixel == '#' {
+				img.SetRGBA(x, y, black)
+			} else {
+				img.SetRGBA(x, y, white)
+			}
+		}
+	}
+	return img
+}
+
+func (suite *FilterTestSuite) TestParse() {
+	for name, expected := range map[string]Filter{
+		"":          Nearest(1),
+		"nearest3x": Nearest(3),
+		"EPX":       Scale2x,
+		"scale3x":   Scale3x,
+		"xbr2x":     XBR2x,
+	} {
+		filter, err := Parse(name)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), expected, filter, name)
+	}
+	for _, name := range []string{"bilinear", "nearest0x", "nearestx"} {
+		_, err := Parse(name)
+		assert.NotNil(suite.T(), err, name)
+	}
+	assert.Equal(suite.T(), "nearest4x", Nearest(4).String())
+}
+
+func (suite *FilterTestSuite) TestNearest() {
+	source := picture("#.", ".#")
+	output := Nearest(3).Apply(source)
+	assert.Equal(suite.T(), image.Rect(0, 0, 6, 6), output.Bounds())
+	assert.Equal(suite.T(), picture("###...", "###...", "###...", "...###", "...###", "...###"), output)
+
+	// Sub images keep their own bounds
+	assert.Equal(suite.T(), picture("##", "##"), Nearest(2).Apply(source.SubImage(image.Rect(1, 1, 2, 2)).(*image.RGBA)))
+}
+
+func (suite *FilterTestSuite) TestScale2x() {
+	// The corners next to a diagonal line fill the gaps, while straight lines stay the same
+	output := Scale2x.Apply(picture(
+		"#..",
+		".#.",
+		"..#",
+	))
+	assert.Equal(suite.T(), picture(
+		"##....",
+		"#.#...",
+		".###..",
+		"..###.",
+		"...#.#",
+		"....##",
+	), output)
+	assert.Equal(suite.T(), picture("##", "##", "..", ".."), Scale2x.Apply(picture("#", ".")))
+}
+
+func (suite *FilterTestSuite) TestScale3x() {
+	output := Scale3x.Apply(picture(
+		"#.",
+		".#",
+	))
+	assert.Equal(suite.T(), picture(
+		"###...",
+		"##.#..",
+		"#..##.",
+		".##..#",
+		"..#.##",
+		"...###",
+	), output)
+}
+
+func (suite *FilterTestSuite) TestXBR2x() {
+	// The corners along the diagonal edge of a triangle are blended
+	output := XBR2x.Apply(picture(
+		"####",
+		"###.",
+		"##..",
+		"#...",
+	))
+	assert.Equal(suite.T(), image.Rect(0, 0, 8, 8), output.Bounds())
+	gray := color.RGBA{0x7F, 0x7F, 0x7F, 0xFF}
+	assert.Equal(suite.T(), gray, output.RGBAAt(3, 5))
+	assert.Equal(suite.T(), black, output.RGBAAt(2, 5))
+	assert.Equal(suite.T(), white, output.RGBAAt(4, 5))
+
+	// Flat areas stay the same
+	assert.Equal(suite.T(), picture("####", "####", "####", "####"), XBR2x.Apply(picture("##", "##")))
+}
@@ -0,0 +1,91 @@
+package filter
+
+import (
+	"image"
+	"image/color"
+)
+
+// scale2x implements the Scale2x algorithm: every corner of the square takes the color of its two
+// neighbours when they match, unless the square is in the middle of a straight line
+type scale2x struct{}
+
+func (scale2x) Factor() int {
+	return 2
+}
+
+func (scale2x) String() string {
+	return "scale2x"
+}
+
+func (scale2x) Apply(source *image.RGBA) *image.RGBA {
+	return scaleEach(source, 2, func(pixels *neighbourhood, output *image.RGBA, x, y int) {
+		b, d, e, f, h := pixels.at(0, -1), pixels.at(-1, 0), pixels.at(0, 0), pixels.at(1, 0), pixels.at(0, 1)
+		square := [4]color.RGBA{e, e, e, e}
+		if b != h && d != f {
+			if d == b {
+				square[0] = d
+			}
+			if b == f {
+				square[1] = f
+			}
+			if d == h {
+				square[2] = d
+			}
+			if h == f {
+				square[3] = f
+			}
+		}
+		for i, pixel := range square {
+			output.SetRGBA(x+i%2, y+i/2, pixel)
+		}
+	})
+}
+
+// scale3x implements the Scale3x algorithm, which extends the rules of Scale2x to the edges of the square
+type scale3x struct{}
+
+func (scale3x) Factor() int {
+	return 3
+}
+
+func (scale3x) String() string {
+	return "scale3x"
+}
+
+func (scale3x) Apply(source *image.RGBA) *image.RGBA {
+	return scaleEach(source, 3, func(pixels *neighbourhood, output *image.RGBA, x, y int) {
+		a, b, c := pixels.at(-1, -1), pixels.at(0, -1), pixels.at(1, -1)
+		d, e, f := pixels.at(-1, 0), pixels.at(0, 0), pixels.at(1, 0)
+		g, h, i := pixels.at(-1, 1), pixels.at(0, 1), pixels.at(1, 1)
+		square := [9]color.RGBA{e, e, e, e, e, e, e, e, e}
+		if b != h && d != f {
+			if d == b {
+				square[0] = d
+			}
+			if (d == b && e != c) || (b == f && e != a) {
+				square[1] = b
+			}
+			if b == f {
+				square[2] = f
+			}
+			if (d == b && e != g) || (d == h && e != a) {
+				square[3] = d
+			}
+			if (b == f && e != i) || (h == f && e != c) {
+				square[5] = f
+			}
+			if d == h {
+				square[6] = d
+			}
+			if (d == h && e != i) || (h == f && e != g) {
+				square[7] = h
+			}
+			if h == f {
+				square[8] = f
+			}
+		}
+		for j, pixel := range square {
+			output.SetRGBA(x+j%3, y+j/3, pixel)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package filter
+
+import (
+	"image"
+	"image/color"
+)
+
+// xbr2x implements the first level of the 2xBR algorithm. Every corner of the square looks for an edge
+// crossing it, comparing the differences of colors along both diagonals, and blends with the color on the
+// other side of the edge when there's one.
+type xbr2x struct{}
+
+func (xbr2x) Factor() int {
+	return 2
+}
+
+func (xbr2x) String() string {
+	return "xbr2x"
+}
+
+func (xbr2x) Apply(source *image.RGBA) *image.RGBA {
+	return scaleEach(source, 2, func(pixels *neighbourhood, output *image.RGBA, x, y int) {
+		// The corners in the order a quarter turn leads from one to the next
+		corners := [4]image.Point{{1, 1}, {0, 1}, {0, 0}, {1, 0}}
+		for turn, corner := range corners {
+			output.SetRGBA(x+corner.X, y+corner.Y, xbrCorner(pixels, turn))
+		}
+	})
+}
+
+// xbrCorner computes the bottom right corner of the square, with the neighbourhood turned by quarters
+func xbrCorner(pixels *neighbourhood, turns int) color.RGBA {
+	at := func(dx, dy int) color.RGBA {
+		for i := 0; i < turns; i++ {
+			dx, dy = -dy, dx
+		}
+		return pixels.at(dx, dy)
+	}
+	b, c, d, e, f := at(0, -1), at(1, -1), at(-1, 0), at(0, 0), at(1, 0)
+	g, h, i := at(-1, 1), at(0, 1), at(1, 1)
+	f4, i4, h5, i5 := at(2, 0), at(2, 1), at(0, 2), at(1, 2)
+
+	// Weights of the edge going through H and F, and of the one going through E and I
+	edgeHF := distance(e, c) + distance(e, g) + distance(i, f4) + distance(i, h5) + 4*distance(h, f)
+	edgeEI := distance(h, d) + distance(h, i5) + distance(f, i4) + distance(f, b) + 4*distance(e, i)
+	if edgeHF >= edgeEI {
+		return e
+	}
+	other := h
+	if distance(e, f) <= distance(e, h) {
+		other = f
+	}
+	return color.RGBA{average(e.R, other.R), average(e.G, other.G), average(e.B, other.B), average(e.A, other.A)}
+}
+
+func average(a, b uint8) uint8 {
+	return uint8((uint16(a) + uint16(b)) / 2)
+}
+
+// distance compares two colors in the YUV space, which weights the differences the way the eye sees them
+func distance(a, b color.RGBA) int {
+	dr, dg, db := int(a.R)-int(b.R), int(a.G)-int(b.G), int(a.B)-int(b.B)
+	y := (299*dr + 587*dg + 114*db) / 1000
+	u := (-169*dr - 331*dg + 500*db) / 1000
+	v := (500*dr - 419*dg - 81*db) / 1000
+	return 48*abs(y) + 7*abs(u) + 6*abs(v)
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
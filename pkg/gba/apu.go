@@ -0,0 +1,301 @@
+package gba
+
+// Offsets of the sound registers
+const (
+	ioSOUND1CNTL = 0x060
+	ioSOUND1CNTH = 0x062
+	ioSOUND1CNTX = 0x064
+	ioSOUND2CNTL = 0x068
+	ioSOUND2CNTH = 0x06C
+	ioSOUND3CNTL = 0x070
+	ioSOUND3CNTH = 0x072
+	ioSOUND3CNTX = 0x074
+	ioSOUND4CNTL = 0x078
+	ioSOUND4CNTH = 0x07C
+	ioSOUNDCNTL  = 0x080
+	ioSOUNDCNTH  = 0x082
+	ioSOUNDCNTX  = 0x084
+	ioSOUNDBIAS  = 0x088
+	ioWAVERAM    = 0x090
+	ioSoundEnd   = 0x0A8
+)
+
+// AudioSampleRate is the number of stereo samples produced every second
+const AudioSampleRate = 32768
+
+// Timing of the sound, in cycles: the samples, and the steps of the sequencer clocking the length counters,
+// the sweep and the envelopes 512 times per second
+const (
+	cyclesPerSample        = 1 << 24 / AudioSampleRate
+	cyclesPerSequencerStep = 1 << 24 / 512
+)
+
+// Samples kept when nobody takes them, older ones get dropped
+const maxBufferedSamples = AudioSampleRate * 2
+
+// Bits of SOUNDCNT_X
+const soundcntxEnabled = 1 << 7
+
+// Bits of the sound registers that can be read back, the others read as 0
+var soundReadMasks = map[uint32]uint16{
+	ioSOUND1CNTL: 0x007F,
+	ioSOUND1CNTH: 0xFFC0,
+	ioSOUND1CNTX: 0x4000,
+	ioSOUND2CNTL: 0xFFC0,
+	ioSOUND2CNTH: 0x4000,
+	ioSOUND3CNTL: 0x00E0,
+	ioSOUND3CNTH: 0xE000,
+	ioSOUND3CNTX: 0x4000,
+	ioSOUND4CNTL: 0xFF00,
+	ioSOUND4CNTH: 0x40FF,
+	ioSOUNDCNTL:  0xFF77,
+	ioSOUNDCNTH:  0x770F,
+	ioSOUNDCNTX:  0x0080,
+	ioSOUNDBIAS:  0xC3FE,
+}
+
+// Shift applied to the PSG channels by SOUNDCNT_H, for 25%, 50% and 100%, 3 being prohibited
+var psgVolumeShifts = [4]uint{2, 1, 0, 2}
+
+// apu is the audio processing unit, which runs the sound channels and mixes them into samples
+type apu struct {
+	memory  *Memory
+	squares [2]squareChannel
+	wave    waveChannel
+	noise   noiseChannel
+	// Direct Sound A and B
+	directSounds [2]directSound
+	// Wave RAM holds two banks, the CPU sees the one that isn't played
+	waveRAM [2][16]byte
+	// Step of the sequencer, and the cycles until the next one and until the next sample
+	sequencerStep   int
+	sequencerCycles int
+	sampleCycles    int
+	// Samples not taken yet, left and right interleaved
+	samples []int16
+}
+
+func newAPU(memory *Memory) *apu {
+	apu := &apu{memory: memory, sequencerCycles: cyclesPerSequencerStep, sampleCycles: cyclesPerSample}
+	apu.reset()
+	memory.apu = apu
+	memory.storeIO16(ioSOUNDBIAS, 0x200)
+	return apu
+}
+
+func (apu *apu) enabled() bool {
+	return apu.memory.io[ioSOUNDCNTX]&soundcntxEnabled != 0
+}
+
+// tick runs the channels, stopping at every step of the sequencer and every sample
+func (apu *apu) tick(cycles int) {
+	for cycles > 0 {
+		step := cycles
+		if apu.sequencerCycles < step {
+			step = apu.sequencerCycles
+		}
+		if apu.sampleCycles < step {
+			step = apu.sampleCycles
+		}
+		cycles -= step
+
+		if apu.enabled() {
+			apu.squares[0].tick(step)
+			apu.squares[1].tick(step)
+			apu.wave.tick(step)
+			apu.noise.tick(step)
+		}
+
+		if apu.sequencerCycles -= step; apu.sequencerCycles == 0 {
+			apu.sequencerCycles = cyclesPerSequencerStep
+			apu.stepSequencer()
+		}
+		if apu.sampleCycles -= step; apu.sampleCycles == 0 {
+			apu.sampleCycles = cyclesPerSample
+			apu.mix()
+		}
+	}
+}
+
+// stepSequencer clocks the length counters at 256Hz, the sweep at 128Hz and the envelopes at 64Hz
+func (apu *apu) stepSequencer() {
+	if !apu.enabled() {
+		return
+	}
+	if apu.sequencerStep%2 == 0 {
+		apu.squares[0].length.step(&apu.squares[0].enabled)
+		apu.squares[1].length.step(&apu.squares[1].enabled)
+		apu.wave.length.step(&apu.wave.enabled)
+		apu.noise.length.step(&apu.noise.enabled)
+	}
+	if apu.sequencerStep == 2 || apu.sequencerStep == 6 {
+		apu.squares[0].stepSweep()
+	}
+	if apu.sequencerStep == 7 {
+		apu.squares[0].envelope.step()
+		apu.squares[1].envelope.step()
+		apu.noise.envelope.step()
+	}
+	apu.sequencerStep = (apu.sequencerStep + 1) % 8
+}
+
+// mix adds the channels enabled on every side into a sample. The outputs of the PSG channels are scaled by the
+// master volumes of SOUNDCNT_L and SOUNDCNT_H, then the Direct Sound ones are added, and the sum goes through
+// the 10 bits DAC with the bias.
+func (apu *apu) mix() {
+	if !apu.enabled() {
+		apu.addSamples(0, 0)
+		return
+	}
+
+	outputs := [4]int{
+		apu.squares[0].output(),
+		apu.squares[1].output(),
+		apu.wave.output(),
+		apu.noise.output(),
+	}
+	soundcntl := apu.memory.readIO16(ioSOUNDCNTL)
+	shift := psgVolumeShifts[apu.memory.readIO16(ioSOUNDCNTH)&0x3]
+	bias := int(apu.memory.readIO16(ioSOUNDBIAS) & 0x3FE)
+
+	var sides [2]int16
+	for side := range sides {
+		// The right side comes first in SOUNDCNT_L
+		volume := int(soundcntl>>(4*uint(side))&0x7) + 1
+		enables := soundcntl >> (8 + 4*uint(side))
+		psg := 0
+		for channel, output := range outputs {
+			if enables&(1<<uint(channel)) != 0 {
+				psg += output
+			}
+		}
+		level := psg * volume >> shift
+		for i := range apu.directSounds {
+			level += apu.directSoundLevel(i, side)
+		}
+		sides[side] = dac(bias + level)
+	}
+	apu.addSamples(sides[1], sides[0])
+}
+
+// dac converts a 10 bits output of the sound into a signed 16 bits sample
+func dac(value int) int16 {
+	if value < 0 {
+		value = 0
+	} else if value > 0x3FF {
+		value = 0x3FF
+	}
+	return int16((value - 0x200) << 6)
+}
+
+func (apu *apu) addSamples(left int16, right int16) {
+	if len(apu.samples) >= maxBufferedSamples*2 {
+		apu.samples = append(apu.samples[:0], apu.samples[maxBufferedSamples:]...)
+	}
+	apu.samples = append(apu.samples, left, right)
+}
+
+// takeSamples returns the samples produced since the last call
+func (apu *apu) takeSamples() []int16 {
+	samples := apu.samples
+	apu.samples = nil
+	return samples
+}
+
+// read8 reads a sound register, hiding the bits that can't be read back
+func (apu *apu) read8(offset uint32) byte {
+	if offset >= ioFIFOA {
+		return 0
+	}
+	if offset >= ioWAVERAM {
+		return apu.waveRAM[1-apu.wave.bank()][offset-ioWAVERAM]
+	}
+	register := offset &^ 1
+	mask, found := soundReadMasks[register]
+	value := apu.memory.readIO16(register) & mask
+	if register == ioSOUNDCNTX {
+		for i, enabled := range []bool{apu.squares[0].enabled, apu.squares[1].enabled, apu.wave.enabled, apu.noise.enabled} {
+			if enabled {
+				value |= 1 << uint(i)
+			}
+		}
+	}
+	if !found {
+		return 0
+	}
+	return byte(value >> (8 * (offset & 1)))
+}
+
+// write8 writes a sound register. While the sound is disabled the PSG registers can't be written.
+func (apu *apu) write8(offset uint32, value byte) {
+	if offset >= ioFIFOA {
+		apu.directSounds[(offset-ioFIFOA)/4].push(int8(value))
+		return
+	}
+	if offset >= ioWAVERAM {
+		apu.waveRAM[1-apu.wave.bank()][offset-ioWAVERAM] = value
+		return
+	}
+	if offset < ioSOUNDCNTH && !apu.enabled() {
+		return
+	}
+	if offset == ioSOUNDCNTH+1 {
+		// The FIFO reset bits don't stay set
+		for i := range apu.directSounds {
+			if uint16(value)<<8&(directSoundReset<<uint(4*i)) != 0 {
+				apu.directSounds[i].reset()
+			}
+		}
+		value &^= (directSoundReset | directSoundReset<<4) >> 8
+	}
+	if offset == ioSOUNDCNTX {
+		value &= soundcntxEnabled
+		if value == 0 && apu.enabled() {
+			apu.reset()
+		}
+	}
+	apu.memory.io[offset] = value
+
+	switch offset {
+	case ioSOUND1CNTH:
+		apu.squares[0].length.load(64, int(value&0x3F))
+	case ioSOUND1CNTH + 1:
+		apu.squares[0].envelopeWritten(value)
+	case ioSOUND1CNTX + 1:
+		apu.squares[0].controlWritten(value)
+	case ioSOUND2CNTL:
+		apu.squares[1].length.load(64, int(value&0x3F))
+	case ioSOUND2CNTL + 1:
+		apu.squares[1].envelopeWritten(value)
+	case ioSOUND2CNTH + 1:
+		apu.squares[1].controlWritten(value)
+	case ioSOUND3CNTL:
+		if value&wavePlayback == 0 {
+			apu.wave.enabled = false
+		}
+	case ioSOUND3CNTH:
+		apu.wave.length.load(256, int(value))
+	case ioSOUND3CNTX + 1:
+		apu.wave.controlWritten(value)
+	case ioSOUND4CNTL:
+		apu.noise.length.load(64, int(value&0x3F))
+	case ioSOUND4CNTL + 1:
+		apu.noise.envelopeWritten(value)
+	case ioSOUND4CNTH + 1:
+		apu.noise.controlWritten(value)
+	}
+}
+
+// reset clears the PSG registers and stops the channels, which happens when the sound gets disabled
+func (apu *apu) reset() {
+	for offset := uint32(ioSOUND1CNTL); offset < ioSOUNDCNTH; offset++ {
+		apu.memory.io[offset] = 0
+	}
+	apu.squares = [2]squareChannel{
+		{memory: apu.memory, envelopeRegister: ioSOUND1CNTH + 1, frequencyRegister: ioSOUND1CNTX, sweep: true},
+		{memory: apu.memory, envelopeRegister: ioSOUND2CNTL + 1, frequencyRegister: ioSOUND2CNTH},
+	}
+	apu.wave = waveChannel{memory: apu.memory}
+	apu.noise = noiseChannel{memory: apu.memory}
+	apu.sequencerStep = 0
+}
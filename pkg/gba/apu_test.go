@@ -0,0 +1,243 @@
+package gba
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type APUTestSuite struct {
+	suite.Suite
+	memory *Memory
+	apu    *apu
+}
+
+func (suite *APUTestSuite) SetupTest() {
+	suite.memory = newMemory(emptyCartridge())
+	suite.apu = newAPU(suite.memory)
+	suite.write(ioSOUNDCNTX, soundcntxEnabled)
+	suite.write(ioSOUNDCNTH, 0x0002)
+}
+
+func TestAPUTestSuite(t *testing.T) {
+	suite.Run(t, new(APUTestSuite))
+}
+
+func (suite *APUTestSuite) write(offset uint32, value uint16) {
+	suite.memory.Write16(0x04000000+offset, value)
+}
+
+func (suite *APUTestSuite) read(offset uint32) uint16 {
+	return suite.memory.Read16(0x04000000 + offset)
+}
+
+// startSquare plays the second square channel on both sides at full volume
+func (suite *APUTestSuite) startSquare(control uint16, frequency uint16) {
+	suite.write(ioSOUNDCNTL, 0x2277)
+	suite.write(ioSOUND2CNTL, control)
+	suite.write(ioSOUND2CNTH, soundRestart<<8|frequency)
+}
+
+func (suite *APUTestSuite) TestRegistersReadBackMasked() {
+	suite.write(ioSOUND1CNTX, 0xC123)
+	suite.write(ioSOUND2CNTL, 0xF0BF)
+	assert.Equal(suite.T(), uint16(0x4000), suite.read(ioSOUND1CNTX))
+	assert.Equal(suite.T(), uint16(0xF080), suite.read(ioSOUND2CNTL))
+	assert.Equal(suite.T(), uint16(0x0200), suite.read(ioSOUNDBIAS))
+	assert.Equal(suite.T(), uint16(0x0080), suite.read(ioSOUNDCNTX))
+}
+
+func (suite *APUTestSuite) TestDisablingClearsRegisters() {
+	suite.startSquare(0xF000, 0)
+	suite.write(ioSOUNDCNTX, 0)
+	assert.Equal(suite.T(), uint16(0), suite.read(ioSOUND2CNTL))
+	assert.Equal(suite.T(), uint16(0), suite.read(ioSOUNDCNTL))
+	assert.False(suite.T(), suite.apu.squares[1].enabled)
+
+	suite.write(ioSOUND2CNTL, 0xF000)
+	assert.Equal(suite.T(), uint16(0), suite.read(ioSOUND2CNTL))
+	suite.write(ioSOUNDCNTH, 0x0001)
+	assert.Equal(suite.T(), uint16(0x0001), suite.read(ioSOUNDCNTH))
+}
+
+func (suite *APUTestSuite) TestSquareDutyCycle() {
+	// 4096 cycles per step of the 50% duty cycle, 8 samples each
+	suite.startSquare(0xF080, 1792)
+	suite.apu.tick(cyclesPerSample * 64 * 4)
+	samples := suite.apu.takeSamples()
+
+	assert.Len(suite.T(), samples, 64*4*2)
+	high := 0
+	for i := 0; i < len(samples); i += 2 {
+		assert.Equal(suite.T(), samples[i], samples[i+1])
+		if samples[i] == 15*8<<6 {
+			high++
+		} else {
+			assert.Equal(suite.T(), int16(-15*8<<6), samples[i])
+		}
+	}
+	assert.Equal(suite.T(), 32*4, high)
+}
+
+func (suite *APUTestSuite) TestMixingSidesAndVolumes() {
+	suite.startSquare(0xF080, 0)
+	suite.write(ioSOUNDCNTL, 0x2003)
+	suite.write(ioSOUNDCNTH, 0x0001)
+	suite.apu.tick(cyclesPerSample)
+	samples := suite.apu.takeSamples()
+	// Left at volume 1 and 50%, nothing on the right
+	assert.Equal(suite.T(), []int16{15 * 1 / 2 << 6, 0}, samples)
+}
+
+func (suite *APUTestSuite) TestLengthCounterStopsChannel() {
+	suite.startSquare(0xF03F, soundLengthEnabled<<8)
+	assert.Equal(suite.T(), uint16(0x0082), suite.read(ioSOUNDCNTX))
+	suite.apu.tick(cyclesPerSequencerStep)
+	assert.Equal(suite.T(), uint16(0x0080), suite.read(ioSOUNDCNTX))
+}
+
+func (suite *APUTestSuite) TestEnvelope() {
+	// Decreasing by one every 64th of a second
+	suite.startSquare(0xF100, 0)
+	suite.apu.tick(cyclesPerSequencerStep * 8 * 2)
+	assert.Equal(suite.T(), 13, suite.apu.squares[1].envelope.volume)
+
+	// Without volume or increase the channel is off
+	suite.write(ioSOUND2CNTL, 0x0700)
+	assert.False(suite.T(), suite.apu.squares[1].enabled)
+}
+
+func (suite *APUTestSuite) TestSweep() {
+	suite.write(ioSOUND1CNTH, 0xF000)
+	suite.write(ioSOUND1CNTL, 0x0012)
+	suite.write(ioSOUND1CNTX, soundRestart<<8|1024)
+	suite.apu.tick(cyclesPerSequencerStep * 3)
+	assert.Equal(suite.T(), 1280, suite.apu.squares[0].frequency())
+	assert.True(suite.T(), suite.apu.squares[0].enabled)
+
+	// Overflowing on the first calculation stops the channel
+	suite.write(ioSOUND1CNTX, soundRestart<<8|2000)
+	assert.False(suite.T(), suite.apu.squares[0].enabled)
+}
+
+func (suite *APUTestSuite) TestWaveRAMBanks() {
+	suite.write(ioSOUND3CNTL, 0)
+	suite.write(ioWAVERAM, 0x3412)
+	assert.Equal(suite.T(), byte(0x12), suite.apu.waveRAM[1][0])
+	assert.Equal(suite.T(), uint16(0x3412), suite.read(ioWAVERAM))
+
+	suite.write(ioSOUND3CNTL, waveBank)
+	assert.Equal(suite.T(), uint16(0), suite.read(ioWAVERAM))
+}
+
+func (suite *APUTestSuite) TestWaveChannel() {
+	// Bank 0 holds rising samples and bank 1 falling ones, played as 64 samples one per output sample
+	for i := 0; i < 16; i++ {
+		suite.apu.waveRAM[0][i] = byte(i%8*2)<<4 | byte(i%8*2+1)
+		suite.apu.waveRAM[1][i] = byte(15-i%8*2)<<4 | byte(14-i%8*2)
+	}
+	suite.write(ioSOUND3CNTL, wavePlayback|waveDimension)
+	suite.write(ioSOUND3CNTH, 0x2000)
+	suite.write(ioSOUND3CNTX, soundRestart<<8|1984)
+	var levels []int
+	for i := 0; i < 64; i++ {
+		levels = append(levels, suite.apu.wave.output())
+		suite.apu.wave.tick(cyclesPerSample)
+	}
+	assert.Equal(suite.T(), -15, levels[0])
+	assert.Equal(suite.T(), 15, levels[15])
+	assert.Equal(suite.T(), 15, levels[32])
+	assert.Equal(suite.T(), -15, levels[47])
+	assert.Equal(suite.T(), -15, suite.apu.wave.output())
+
+	// Forced to 75% and then 25%
+	suite.write(ioSOUND3CNTH, 0x8000)
+	assert.Equal(suite.T(), -11, suite.apu.wave.output())
+	suite.write(ioSOUND3CNTH, 0x6000)
+	assert.Equal(suite.T(), -4, suite.apu.wave.output())
+
+	suite.write(ioSOUND3CNTL, 0)
+	assert.False(suite.T(), suite.apu.wave.enabled)
+}
+
+func (suite *APUTestSuite) TestNoisePeriods() {
+	for _, test := range []struct {
+		control uint16
+		period  int
+	}{
+		{0x0000, 1<<15 - 1},
+		{0x0008, 1<<7 - 1},
+	} {
+		suite.write(ioSOUND4CNTL, 0xF000)
+		suite.write(ioSOUND4CNTH, soundRestart<<8|test.control)
+		noise := &suite.apu.noise
+		start := noise.lfsr & 0x7F
+		period := 0
+		for period == 0 || noise.lfsr&0x7F != start || test.control == 0 && noise.lfsr != 0x7FFF {
+			noise.tick(noise.period())
+			period++
+		}
+		assert.Equal(suite.T(), test.period, period)
+	}
+}
+
+func (suite *APUTestSuite) TestSamplesPerSecond() {
+	suite.apu.tick(1 << 24)
+	assert.Len(suite.T(), suite.apu.takeSamples(), AudioSampleRate*2)
+	assert.Len(suite.T(), suite.apu.takeSamples(), 0)
+}
+
+// startDirectSound plays Direct Sound A at full volume on both sides, driven by timer 0 overflowing every cycle
+func (suite *APUTestSuite) startDirectSound() {
+	suite.write(ioSOUNDCNTH, 0x0002|directSoundFullVolume|directSoundRight|directSoundLeft)
+	suite.memory.Write32(0x04000100, uint32(timerEnabled)<<16|0xFFFF)
+}
+
+func (suite *APUTestSuite) TestDirectSound() {
+	suite.startDirectSound()
+	suite.memory.Write32(0x04000000+ioFIFOA, 0x40C020F0)
+	assert.Equal(suite.T(), 4, suite.apu.directSounds[0].count)
+
+	suite.memory.timers.tick(2)
+	suite.apu.tick(cyclesPerSample)
+	assert.Equal(suite.T(), []int16{0x20 * 4 << 6, 0x20 * 4 << 6}, suite.apu.takeSamples())
+
+	// Half volume on the left only
+	suite.write(ioSOUNDCNTH, 0x0002|directSoundLeft)
+	suite.memory.timers.tick(1)
+	suite.apu.tick(cyclesPerSample)
+	assert.Equal(suite.T(), []int16{-0x40 * 2 << 6, 0}, suite.apu.takeSamples())
+}
+
+func (suite *APUTestSuite) TestDirectSoundReset() {
+	suite.startDirectSound()
+	suite.memory.Write8(0x04000000+ioFIFOB, 0x10)
+	suite.write(ioSOUNDCNTH, 0x0002|directSoundReset<<4)
+	assert.Equal(suite.T(), 0, suite.apu.directSounds[1].count)
+	assert.Equal(suite.T(), uint16(0x0002), suite.read(ioSOUNDCNTH))
+	assert.Equal(suite.T(), byte(0x00), suite.memory.io[ioSOUNDCNTH+1])
+}
+
+func (suite *APUTestSuite) TestDirectSoundDMARefill() {
+	for i := uint32(0); i < 64; i++ {
+		suite.memory.Write8(0x02000000+i, byte(i))
+	}
+	suite.memory.Write32(0x040000BC, 0x02000000)
+	suite.memory.Write32(0x040000C0, 0x04000000+ioFIFOA)
+	suite.memory.Write16(0x040000C6, dmaEnabled|dmaRepeat|dmaWord|dmaSpecial<<12|dmaFixed<<5)
+	suite.startDirectSound()
+
+	// The empty FIFO gets four words, then the next overflow leaves it half empty again
+	suite.memory.timers.tick(1)
+	assert.Equal(suite.T(), 16, suite.apu.directSounds[0].count)
+	suite.memory.timers.tick(1)
+	assert.Equal(suite.T(), 31, suite.apu.directSounds[0].count)
+	assert.Equal(suite.T(), int8(0), suite.apu.directSounds[0].sample)
+	assert.Equal(suite.T(), uint32(0x02000020), suite.memory.dma.channels[1].source)
+	assert.Equal(suite.T(), uint32(0x04000000+ioFIFOA), suite.memory.dma.channels[1].destination)
+
+	suite.memory.timers.tick(1)
+	assert.Equal(suite.T(), int8(1), suite.apu.directSounds[0].sample)
+	assert.Equal(suite.T(), 30, suite.apu.directSounds[0].count)
+}
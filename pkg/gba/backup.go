@@ -0,0 +1,189 @@
+package gba
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// backup is the chip of a cartridge where the game progress is kept, mapped at 0x0E000000
+type backup interface {
+	read8(address uint32) byte
+	write8(address uint32, value byte)
+}
+
+// backupType identifies which kind of chip a game expects on its cartridge
+type backupType int
+
+// Constants for defining the backup chip types
+const (
+	backupNone backupType = iota
+	backupSRAM
+	backupFlash64K
+	backupFlash128K
+	backupEEPROM
+	backupEEPROM512
+	backupEEPROM8K
+)
+
+// Games built with the official SDK embed the name of the backup library they were linked with,
+// which is the most reliable hint of the chip they expect.
+var backupSignatures = []struct {
+	signature  string
+	backupType backupType
+}{
+	{"SRAM_V", backupSRAM},
+	{"SRAM_F_V", backupSRAM},
+	{"FLASH_V", backupFlash64K},
+	{"FLASH512_V", backupFlash64K},
+	{"FLASH1M_V", backupFlash128K},
+	{"EEPROM_V", backupEEPROM},
+}
+
+func detectBackupType(romData []byte) backupType {
+	for _, entry := range backupSignatures {
+		if bytes.Contains(romData, []byte(entry.signature)) {
+			return entry.backupType
+		}
+	}
+	return backupNone
+}
+
+// savePath returns where the .sav file of a ROM lives, next to the ROM unless a directory is configured
+func savePath(romPath string, saveDir string) string {
+	name := filepath.Base(romBasePath(romPath)) + ".sav"
+	if saveDir == "" {
+		return filepath.Join(filepath.Dir(romPath), name)
+	}
+	return filepath.Join(saveDir, name)
+}
+
+// backupStorage holds the raw bytes of a backup chip and persists them into a .sav file.
+// It's shared between the emulation, which modifies it, and the periodic flusher, so every access is locked.
+type backupStorage struct {
+	mutex sync.Mutex
+	bytes []byte
+	path  string
+	dirty bool
+	done  chan struct{}
+}
+
+// newBackupStorage creates a storage of the given size, restoring the contents of an existing .sav file
+func newBackupStorage(path string, size int) *backupStorage {
+	storage := &backupStorage{bytes: make([]byte, size), path: path}
+	for i := range storage.bytes {
+		storage.bytes[i] = 0xFF
+	}
+
+	saved, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return storage
+	}
+	if err != nil {
+		log.Println("Unable to read save file:", err)
+		return storage
+	}
+
+	log.Println("Save file", path, "loaded")
+	copy(storage.bytes, saved)
+	return storage
+}
+
+func (storage *backupStorage) get(offset int) byte {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	return storage.bytes[offset]
+}
+
+func (storage *backupStorage) set(offset int, value byte) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	if storage.bytes[offset] != value {
+		storage.bytes[offset] = value
+		storage.dirty = true
+	}
+}
+
+func (storage *backupStorage) len() int {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	return len(storage.bytes)
+}
+
+// resize changes the size of the contents once the real size of the chip is known
+func (storage *backupStorage) resize(size int) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	if size == len(storage.bytes) {
+		return
+	}
+	bytes := make([]byte, size)
+	for i := copy(bytes, storage.bytes); i < size; i++ {
+		bytes[i] = 0xFF
+	}
+	storage.bytes = bytes
+}
+
+// flush writes the contents into the .sav file, only if they changed since the last flush
+func (storage *backupStorage) flush() error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	if !storage.dirty {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(storage.path), 0755); err != nil {
+		return err
+	}
+	// Write into a temporary file first, so a crash in the middle never leaves a truncated save behind
+	temporaryPath := storage.path + ".tmp"
+	if err := ioutil.WriteFile(temporaryPath, storage.bytes, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(temporaryPath, storage.path); err != nil {
+		return err
+	}
+	storage.dirty = false
+	return nil
+}
+
+// startAutoFlush periodically flushes the storage in the background until it's closed
+func (storage *backupStorage) startAutoFlush(interval time.Duration) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	if interval <= 0 || storage.done != nil {
+		return
+	}
+	storage.done = make(chan struct{})
+
+	go func(done chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := storage.flush(); err != nil {
+					log.Println("Unable to write save file:", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}(storage.done)
+}
+
+// close stops the periodic flushing and flushes one last time. It may be called from a signal handler while
+// the emulation closes the storage too.
+func (storage *backupStorage) close() error {
+	storage.mutex.Lock()
+	if storage.done != nil {
+		close(storage.done)
+		storage.done = nil
+	}
+	storage.mutex.Unlock()
+	return storage.flush()
+}
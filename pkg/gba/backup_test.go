@@ -0,0 +1,257 @@
+package gba
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type BackupTestSuite struct {
+	suite.Suite
+	saveDir string
+}
+
+func (suite *BackupTestSuite) SetupTest() {
+	suite.saveDir, _ = ioutil.TempDir("", "gomu")
+}
+
+func (suite *BackupTestSuite) TearDownTest() {
+	os.RemoveAll(suite.saveDir)
+}
+
+func TestBackupTestSuite(t *testing.T) {
+	suite.Run(t, new(BackupTestSuite))
+}
+
+func (suite *BackupTestSuite) newMemoryWithBackup(backupType backupType) *Memory {
+	cartridge := newCartridge(make([]byte, 0x200))
+	cartridge.attachBackup(backupType, filepath.Join(suite.saveDir, "game.sav"))
+	return newMemory(cartridge)
+}
+
+func (suite *BackupTestSuite) TestBackupTypeDetection() {
+	rom := make([]byte, 0x200)
+	assert.Equal(suite.T(), backupNone, detectBackupType(rom))
+
+	copy(rom[0x100:], "SRAM_V113")
+	assert.Equal(suite.T(), backupSRAM, detectBackupType(rom))
+
+	copy(rom[0x100:], "FLASH1M_V103")
+	assert.Equal(suite.T(), backupFlash128K, detectBackupType(rom))
+}
+
+func (suite *BackupTestSuite) TestSavePath() {
+	assert.Equal(suite.T(), filepath.Join("roms", "game.sav"), savePath(filepath.Join("roms", "game.gba"), ""))
+	assert.Equal(suite.T(), filepath.Join("saves", "game.sav"), savePath(filepath.Join("roms", "game.gba"), "saves"))
+	// Compressed ROMs share the save of the uncompressed one
+	for _, name := range []string{"game.zip", "game.gba.gz", "game.GBA.GZ", "game.mb.gz"} {
+		assert.Equal(suite.T(), filepath.Join("roms", "game.sav"), savePath(filepath.Join("roms", name), ""), name)
+	}
+}
+
+func (suite *BackupTestSuite) TestSRAMIsMirrored() {
+	memory := suite.newMemoryWithBackup(backupSRAM)
+
+	memory.Write8(0x0E000010, 0x42)
+	assert.Equal(suite.T(), byte(0x42), memory.Read8(0x0E008010))
+	assert.Equal(suite.T(), byte(0x42), memory.Read8(0x0F000010))
+}
+
+func (suite *BackupTestSuite) TestSRAMOnlyAllowsByteAccesses() {
+	memory := suite.newMemoryWithBackup(backupSRAM)
+
+	memory.Write8(0x0E000000, 0x12)
+	assert.Equal(suite.T(), uint16(0x1212), memory.Read16(0x0E000000))
+	assert.Equal(suite.T(), uint32(0x12121212), memory.Read32(0x0E000000))
+
+	memory.Write16(0x0E000001, 0xABCD)
+	assert.Equal(suite.T(), byte(0xAB), memory.Read8(0x0E000001))
+	memory.Write32(0x0E000002, 0x11223344)
+	assert.Equal(suite.T(), byte(0x22), memory.Read8(0x0E000002))
+	assert.Equal(suite.T(), byte(0xFF), memory.Read8(0x0E000003))
+}
+
+func (suite *BackupTestSuite) TestSRAMIsPersisted() {
+	memory := suite.newMemoryWithBackup(backupSRAM)
+	memory.Write8(0x0E000020, 0x99)
+	memory.cartridge.close()
+
+	saved, err := ioutil.ReadFile(filepath.Join(suite.saveDir, "game.sav"))
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), sramSize, len(saved))
+	assert.Equal(suite.T(), byte(0x99), saved[0x20])
+
+	memory = suite.newMemoryWithBackup(backupSRAM)
+	assert.Equal(suite.T(), byte(0x99), memory.Read8(0x0E000020))
+}
+
+func writeFlashCommand(memory *Memory, command byte) {
+	memory.Write8(0x0E005555, 0xAA)
+	memory.Write8(0x0E002AAA, 0x55)
+	memory.Write8(0x0E005555, command)
+}
+
+func (suite *BackupTestSuite) TestConcurrentCloses() {
+	memory := suite.newMemoryWithBackup(backupSRAM)
+	memory.cartridge.storage.startAutoFlush(time.Millisecond)
+	memory.Write8(0x0E000000, 0x42)
+
+	// A signal handler closes the cartridge while the emulation does it as well
+	var group sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			memory.cartridge.close()
+		}()
+	}
+	group.Wait()
+
+	saved, _ := ioutil.ReadFile(filepath.Join(suite.saveDir, "game.sav"))
+	assert.Equal(suite.T(), byte(0x42), saved[0])
+}
+
+func (suite *BackupTestSuite) TestFlashAnswersChipID() {
+	memory := suite.newMemoryWithBackup(backupFlash128K)
+
+	writeFlashCommand(memory, 0x90)
+	assert.Equal(suite.T(), byte(0x62), memory.Read8(0x0E000000))
+	assert.Equal(suite.T(), byte(0x13), memory.Read8(0x0E000001))
+
+	writeFlashCommand(memory, 0xF0)
+	assert.Equal(suite.T(), byte(0xFF), memory.Read8(0x0E000000))
+}
+
+func (suite *BackupTestSuite) TestFlashChipCanBeForced() {
+	memory := suite.newMemoryWithBackup(backupFlash128K)
+	memory.cartridge.setFlashChip("macronix128")
+
+	writeFlashCommand(memory, 0x90)
+	assert.Equal(suite.T(), byte(0xC2), memory.Read8(0x0E000000))
+	assert.Equal(suite.T(), byte(0x09), memory.Read8(0x0E000001))
+}
+
+func (suite *BackupTestSuite) TestFlashProgramsAndErases() {
+	memory := suite.newMemoryWithBackup(backupFlash64K)
+
+	memory.Write8(0x0E001000, 0x12)
+	assert.Equal(suite.T(), byte(0xFF), memory.Read8(0x0E001000), "writes without a command are ignored")
+
+	writeFlashCommand(memory, 0xA0)
+	memory.Write8(0x0E001000, 0x12)
+	writeFlashCommand(memory, 0xA0)
+	memory.Write8(0x0E002000, 0x34)
+	assert.Equal(suite.T(), byte(0x12), memory.Read8(0x0E001000))
+	assert.Equal(suite.T(), byte(0x34), memory.Read8(0x0E002000))
+
+	writeFlashCommand(memory, 0x80)
+	memory.Write8(0x0E005555, 0xAA)
+	memory.Write8(0x0E002AAA, 0x55)
+	memory.Write8(0x0E001000, 0x30)
+	assert.Equal(suite.T(), byte(0xFF), memory.Read8(0x0E001000))
+	assert.Equal(suite.T(), byte(0x34), memory.Read8(0x0E002000))
+
+	writeFlashCommand(memory, 0x80)
+	writeFlashCommand(memory, 0x10)
+	assert.Equal(suite.T(), byte(0xFF), memory.Read8(0x0E002000))
+}
+
+func (suite *BackupTestSuite) TestFlashSwitchesBanks() {
+	memory := suite.newMemoryWithBackup(backupFlash128K)
+
+	writeFlashCommand(memory, 0xB0)
+	memory.Write8(0x0E000000, 0x1)
+	writeFlashCommand(memory, 0xA0)
+	memory.Write8(0x0E000010, 0x56)
+	assert.Equal(suite.T(), byte(0x56), memory.Read8(0x0E000010))
+
+	writeFlashCommand(memory, 0xB0)
+	memory.Write8(0x0E000000, 0x0)
+	assert.Equal(suite.T(), byte(0xFF), memory.Read8(0x0E000010))
+
+	memory.cartridge.close()
+	saved, _ := ioutil.ReadFile(filepath.Join(suite.saveDir, "game.sav"))
+	assert.Equal(suite.T(), flash128KSize, len(saved))
+	assert.Equal(suite.T(), byte(0x56), saved[flashBankSize+0x10])
+}
+
+// sendEEPROMBits writes a bitstream into EWRAM and sends it through DMA3, like games do
+func sendEEPROMBits(memory *Memory, bits []uint16) {
+	for i, bit := range bits {
+		memory.Write16(0x02000000+uint32(2*i), bit)
+	}
+	memory.Write32(0x040000D4, 0x02000000)
+	memory.Write32(0x040000D8, 0x0D000000)
+	memory.Write16(0x040000DC, uint16(len(bits)))
+	memory.Write16(0x040000DE, 0x8000)
+}
+
+// receiveEEPROMBits reads the answer of a read request through DMA3
+func receiveEEPROMBits(memory *Memory) []uint16 {
+	memory.Write32(0x040000D4, 0x0D000000)
+	memory.Write32(0x040000D8, 0x02001000)
+	memory.Write16(0x040000DC, 68)
+	memory.Write16(0x040000DE, 0x8000)
+
+	bits := make([]uint16, 68)
+	for i := range bits {
+		bits[i] = memory.Read16(0x02001000 + uint32(2*i))
+	}
+	return bits
+}
+
+func eepromRequest(request uint16, address uint16, addressBits int, data []uint16) []uint16 {
+	bits := []uint16{request >> 1, request & 0x1}
+	for i := addressBits - 1; i >= 0; i-- {
+		bits = append(bits, (address>>uint(i))&0x1)
+	}
+	return append(append(bits, data...), 0)
+}
+
+func (suite *BackupTestSuite) TestEEPROMWritesAndReadsBlocks() {
+	memory := suite.newMemoryWithBackup(backupEEPROM)
+	data := make([]uint16, 64)
+	for i := range data {
+		data[i] = uint16(i % 3 & 0x1)
+	}
+
+	sendEEPROMBits(memory, eepromRequest(0x2, 0x5, 14, data))
+	assert.Equal(suite.T(), 14, memory.cartridge.eeprom.addressBits)
+	assert.Equal(suite.T(), uint16(0x0), memory.Read16(0x0D000000), "the chip is busy right after a write")
+	memory.cartridge.tick(eepromWriteCycles)
+	assert.Equal(suite.T(), uint16(0x1), memory.Read16(0x0D000000))
+
+	sendEEPROMBits(memory, eepromRequest(0x3, 0x5, 14, nil))
+	answer := receiveEEPROMBits(memory)
+	assert.Equal(suite.T(), []uint16{0, 0, 0, 0}, answer[:4])
+	assert.Equal(suite.T(), data, answer[4:])
+	assert.Equal(suite.T(), uint16(0x0), memory.Read16(0x040000DE)&0x8000, "DMA is disabled once it's done")
+}
+
+func (suite *BackupTestSuite) TestEEPROMDetects512BytesChips() {
+	memory := suite.newMemoryWithBackup(backupEEPROM)
+	sendEEPROMBits(memory, eepromRequest(0x3, 0x1, 6, nil))
+	assert.Equal(suite.T(), 6, memory.cartridge.eeprom.addressBits)
+
+	sendEEPROMBits(memory, eepromRequest(0x2, 0x1, 6, make([]uint16, 64)))
+	memory.cartridge.close()
+	saved, _ := ioutil.ReadFile(filepath.Join(suite.saveDir, "game.sav"))
+	assert.Equal(suite.T(), eeprom512Size, len(saved))
+	assert.Equal(suite.T(), byte(0x0), saved[8])
+	assert.Equal(suite.T(), byte(0xFF), saved[16])
+
+	memory = suite.newMemoryWithBackup(backupEEPROM)
+	assert.Equal(suite.T(), 6, memory.cartridge.eeprom.addressBits)
+}
+
+func (suite *BackupTestSuite) TestMissingBackupReadsAsOpenBus() {
+	memory := suite.newMemoryWithBackup(backupNone)
+	memory.Write8(0x0E000000, 0x00)
+	assert.Equal(suite.T(), byte(0xFF), memory.Read8(0x0E000000))
+}
@@ -0,0 +1,88 @@
+package gba
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"log"
+
+	"../arm7"
+)
+
+// Known BIOS dumps, by the CRC32 of their contents
+var knownBIOSes = map[uint32]string{
+	0x81977335: "Game Boy Advance",
+	0xA6473709: "Nintendo DS",
+}
+
+// Last opcode fetched by the BIOS before jumping into the game, which is what protected reads answer after booting
+const biosLatchAfterBoot = 0xE129F000
+
+// loadBIOS reads a BIOS dump, dumps that aren't known are used anyway since they may be homebrew replacements
+func loadBIOS(path string) []byte {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(data) != biosSize {
+		log.Fatal("The BIOS must be ", biosSize/1024, "KB, but ", path, " has ", len(data), " bytes")
+	}
+
+	checksum := crc32.ChecksumIEEE(data)
+	if name, found := knownBIOSes[checksum]; found {
+		log.Println("Using the", name, "BIOS")
+	} else {
+		log.Printf("Unknown BIOS with CRC32 %08X, games may not boot\n", checksum)
+	}
+	return data
+}
+
+// readBIOS32 reads a word of the BIOS, which can only be read by code running from it. Reads coming from
+// anywhere else answer the last word read from inside the BIOS, instead of the actual contents.
+// Opcode fetches aren't told apart from data reads, so every read done from inside the BIOS updates it.
+func (memory *Memory) readBIOS32(address uint32) uint32 {
+	if memory.programCounter != nil && memory.programCounter() >= biosSize {
+		return memory.biosLatch
+	}
+	value := binary.LittleEndian.Uint32(memory.bios[address&^0x3:])
+	memory.biosLatch = value
+	return value
+}
+
+// biosMachine exposes the console to the calls of the HLE BIOS
+type biosMachine struct {
+	*Memory
+	cpu *arm7.CPU
+}
+
+func (machine biosMachine) Register(register uint32) uint32 {
+	return machine.cpu.Register(register)
+}
+
+func (machine biosMachine) SetRegister(register uint32, value uint32) {
+	machine.cpu.SetRegister(register, value)
+}
+
+func (machine biosMachine) Boot(address uint32) {
+	machine.cpu.Reset(false)
+	machine.cpu.SetProgramCounter(address)
+}
+
+// RepeatCall moves the program counter back to the SWI instruction
+func (machine biosMachine) RepeatCall() {
+	size := uint32(4)
+	if machine.cpu.InstructionMode == arm7.THUMB {
+		size = 2
+	}
+	machine.cpu.SetProgramCounter(machine.cpu.ProgramCounter() - size)
+}
+
+// serviceSWI runs the BIOS calls of games running without a BIOS, the unknown ones are skipped
+func (core *Core) serviceSWI(number byte) bool {
+	cycles, err := core.hle.Call(biosMachine{core.Memory, core.CPU}, number)
+	if err != nil {
+		log.Println(err)
+	}
+	core.cycles += uint64(cycles)
+	return true
+}
@@ -1,10 +1,17 @@
 package gba
 
 import (
-	"bufio"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+
+	"../patch"
 )
 
 type cartridgeHeader struct {
@@ -75,28 +82,133 @@ type cartridgeHeader struct {
 	joybusEntryPoint []byte
 }
 
-func readROMFile(romPath string) []byte {
+// Extensions of the ROM files looked for inside archives
+var romExtensions = []string{".gba", ".agb", ".bin", ".mb"}
+
+// readROMFile reads a ROM, either a raw file or one compressed inside a .zip or .gz archive.
+// From zip archives the entry with the given name is picked, or the first ROM when empty.
+// It also returns the name of the ROM read, which is the one of the entry for archives.
+func readROMFile(romPath string, entryName string) ([]byte, string) {
 	absRomPath, _ := filepath.Abs(romPath)
 
 	log.Println("Loading rom file ...")
-	romFile, err := os.Open(absRomPath)
+	var romBytes []byte
+	var err error
+	romName := romPath
+	switch extension := filepath.Ext(absRomPath); strings.ToLower(extension) {
+	case ".zip":
+		romBytes, romName, err = readZipEntry(absRomPath, entryName)
+	case ".gz":
+		romBytes, err = readGzipFile(absRomPath)
+		romName = strings.TrimSuffix(romPath, extension)
+	default:
+		romBytes, err = ioutil.ReadFile(absRomPath)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	stats, statsErr := romFile.Stat()
-	if statsErr != nil {
-		log.Fatal(statsErr)
+	log.Println("ROM with", len(romBytes)/1024, "KB loaded")
+	return romBytes, romName
+}
+
+func isROMName(name string) bool {
+	extension := strings.ToLower(path.Ext(name))
+	for _, romExtension := range romExtensions {
+		if extension == romExtension {
+			return true
+		}
+	}
+	return false
+}
+
+func readZipEntry(archivePath string, entryName string) ([]byte, string, error) {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, "", err
+	}
+	defer archive.Close()
+
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		if entryName != "" && entry.Name != entryName && path.Base(entry.Name) != entryName {
+			continue
+		}
+		if entryName == "" && !isROMName(entry.Name) {
+			continue
+		}
+
+		log.Println("Extracting", entry.Name)
+		reader, err := entry.Open()
+		if err != nil {
+			return nil, "", err
+		}
+		defer reader.Close()
+		data, err := ioutil.ReadAll(reader)
+		return data, entry.Name, err
+	}
+
+	if entryName != "" {
+		return nil, "", fmt.Errorf("%s not found inside %s", entryName, archivePath)
+	}
+	return nil, "", fmt.Errorf("no ROM found inside %s", archivePath)
+}
+
+func readGzipFile(archivePath string) ([]byte, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// romBasePath strips the archive and ROM extensions of a path, so that game.gba, game.zip and game.gba.gz
+// all share the files named after game
+func romBasePath(romPath string) string {
+	switch extension := filepath.Ext(romPath); strings.ToLower(extension) {
+	case ".zip", ".gz":
+		romPath = strings.TrimSuffix(romPath, extension)
+	}
+	if isROMName(romPath) {
+		romPath = strings.TrimSuffix(romPath, filepath.Ext(romPath))
 	}
+	return romPath
+}
 
-	size := stats.Size()
-	log.Println("ROM with", size/1024, "KB loaded")
+// findPatch looks for a patch named after the ROM next to it, like game.ips for game.gba
+func findPatch(romPath string) string {
+	basePath := romBasePath(romPath)
+	for _, format := range patch.Formats {
+		patchPath := basePath + format.Extension()
+		if _, err := os.Stat(patchPath); err == nil {
+			return patchPath
+		}
+	}
+	return ""
+}
 
-	romBytes := make([]byte, size)
-	reader := bufio.NewReader(romFile)
-	_, err = reader.Read(romBytes)
+// applyPatch soft-patches the ROM in memory, leaving the file untouched
+func applyPatch(romData []byte, patchPath string) []byte {
+	patchData, err := ioutil.ReadFile(patchPath)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	return romBytes
+	patched, err := patch.Apply(romData, patchData)
+	if err != nil {
+		log.Fatal("Unable to apply ", patchPath, ": ", err)
+	}
+	log.Println("Patch", patchPath, "applied")
+	return patched
 }
 
 func extractHeaderData(romData []byte) *cartridgeHeader {
@@ -135,3 +247,189 @@ func logHeaderData(header *cartridgeHeader) {
 	log.Println("Slave ID Number: ", header.slaveID)
 	log.Printf("Joybus Entry Point: %08b\n", header.joybusEntryPoint)
 }
+
+// cartridge is the Game Pak plugged into the console, its ROM and the chips that come with it
+type cartridge struct {
+	header  *cartridgeHeader
+	rom     []byte
+	backup  backup
+	eeprom  *eeprom
+	storage *backupStorage
+	gpio    *gpio
+	tilt    *tiltSensor
+}
+
+func newCartridge(romData []byte) *cartridge {
+	return &cartridge{header: extractHeaderData(romData[0x000:0x0E3]), rom: romData}
+}
+
+// attachBackup plugs the backup chip the game expects, persisting it into the given .sav file
+func (cartridge *cartridge) attachBackup(backupType backupType, path string) {
+	switch backupType {
+	case backupSRAM:
+		log.Println("Backup type: SRAM")
+		cartridge.storage = newBackupStorage(path, sramSize)
+		cartridge.backup = newSRAM(cartridge.storage)
+	case backupFlash64K, backupFlash128K:
+		chip := defaultFlashChip(flash64KSize)
+		if backupType == backupFlash128K {
+			chip = defaultFlashChip(flash128KSize)
+		}
+		log.Println("Backup type: Flash", chip.size/1024, "KB")
+		cartridge.storage = newBackupStorage(path, chip.size)
+		cartridge.backup = newFlash(cartridge.storage, chip)
+	case backupEEPROM:
+		// The size is only known once the game talks to the chip, unless it was saved before
+		size := eeprom8KSize
+		if stats, err := os.Stat(path); err == nil && stats.Size() == eeprom512Size {
+			size = eeprom512Size
+		}
+		log.Println("Backup type: EEPROM")
+		cartridge.storage = newBackupStorage(path, size)
+		cartridge.eeprom = newEEPROM(cartridge.storage)
+	case backupEEPROM512, backupEEPROM8K:
+		size, addressBits := eeprom8KSize, 14
+		if backupType == backupEEPROM512 {
+			size, addressBits = eeprom512Size, 6
+		}
+		log.Println("Backup type: EEPROM", size, "bytes")
+		cartridge.storage = newBackupStorage(path, size)
+		cartridge.eeprom = newEEPROM(cartridge.storage)
+		cartridge.eeprom.addressBits = addressBits
+	default:
+		log.Println("Backup type: none")
+	}
+}
+
+// attachGPIODevice wires a device to the GPIO port, which is created along with the first device
+func (cartridge *cartridge) attachGPIODevice(device gpioDevice) {
+	if cartridge.gpio == nil {
+		cartridge.gpio = newGPIO()
+	}
+	cartridge.gpio.attach(device)
+}
+
+// attachHardware plugs the extra hardware the game comes with, with the sensors fed by the given sources
+func (cartridge *cartridge) attachHardware(hardware int, clock Clock, light LightSource, rotation RotationSource, rumble Rumble) {
+	if hardware&hardwareRTC != 0 {
+		log.Println("Real time clock detected")
+		cartridge.attachGPIODevice(newRTC(clock))
+	}
+	if hardware&hardwareSolarSensor != 0 {
+		log.Println("Solar sensor detected")
+		cartridge.attachGPIODevice(&solarSensor{source: light})
+	}
+	if hardware&hardwareGyroSensor != 0 {
+		log.Println("Gyro sensor detected")
+		cartridge.attachGPIODevice(&gyroSensor{source: rotation})
+	}
+	if hardware&hardwareRumble != 0 {
+		log.Println("Rumble detected")
+		cartridge.attachGPIODevice(&rumbleMotor{rumble: rumble})
+	}
+	if hardware&hardwareTiltSensor != 0 {
+		log.Println("Tilt sensor detected")
+		cartridge.tilt = &tiltSensor{source: rotation}
+	}
+}
+
+// setFlashChip replaces the model of the flash chip, models of a different size than the game expects are refused
+func (cartridge *cartridge) setFlashChip(name string) {
+	flash, ok := cartridge.backup.(*flash)
+	if !ok {
+		return
+	}
+	chip, found := findFlashChip(name)
+	if !found {
+		log.Println("Unknown flash chip", name)
+		return
+	}
+	if chip.size != flash.chip.size {
+		log.Println("Flash chip", name, "doesn't match the size expected by the game")
+		return
+	}
+	flash.chip = chip
+}
+
+// isEEPROMAddress tells whether an address reaches the EEPROM, which takes the whole 0x0D000000 region
+// except for 32MB ROMs, where it's limited to its last 256 bytes
+func (cartridge *cartridge) isEEPROMAddress(address uint32) bool {
+	if cartridge.eeprom == nil || address>>24 != regionROM2Mirror {
+		return false
+	}
+	return len(cartridge.rom) <= 0x1000000 || address&0xFFFFFF >= 0xFFFF00
+}
+
+func (cartridge *cartridge) readROM16(address uint32) uint16 {
+	if cartridge.isEEPROMAddress(address) {
+		return cartridge.eeprom.read16()
+	}
+	offset := address & 0x1FFFFFE
+	if cartridge.gpio != nil && cartridge.gpio.readable && isGPIORegister(offset) {
+		return cartridge.gpio.read16(offset)
+	}
+	if offset+1 < uint32(len(cartridge.rom)) {
+		return uint16(cartridge.rom[offset]) | uint16(cartridge.rom[offset+1])<<8
+	}
+	// Reading past the end of the ROM returns the lower bits of the halfword address
+	return uint16(offset >> 1)
+}
+
+func (cartridge *cartridge) readROM8(address uint32) byte {
+	return byte(cartridge.readROM16(address) >> (8 * (address & 0x1)))
+}
+
+func (cartridge *cartridge) writeROM16(address uint32, value uint16) {
+	if cartridge.isEEPROMAddress(address) {
+		cartridge.eeprom.write16(value)
+		return
+	}
+	offset := address & 0x1FFFFFE
+	if cartridge.gpio != nil && isGPIORegister(offset) {
+		cartridge.gpio.write16(offset, value)
+	}
+}
+
+// eepromTransferStarted lets the EEPROM know the length of a DMA transfer sent into it
+func (cartridge *cartridge) eepromTransferStarted(length uint32) {
+	if cartridge.eeprom != nil {
+		cartridge.eeprom.transferStarted(length)
+	}
+}
+
+// tick lets the chips of the cartridge that work on their own catch up with the emulated time
+func (cartridge *cartridge) tick(cycles int) {
+	if cartridge.eeprom != nil {
+		cartridge.eeprom.tick(cycles)
+	}
+}
+
+func (cartridge *cartridge) readBackup8(address uint32) byte {
+	if cartridge.tilt != nil && isTiltRegister(address) {
+		return cartridge.tilt.read8(address)
+	}
+	if cartridge.backup == nil {
+		return 0xFF
+	}
+	return cartridge.backup.read8(address)
+}
+
+func (cartridge *cartridge) writeBackup8(address uint32, value byte) {
+	if cartridge.tilt != nil && isTiltRegister(address) {
+		cartridge.tilt.write8(address, value)
+		return
+	}
+	if cartridge.backup != nil {
+		cartridge.backup.write8(address, value)
+	}
+}
+
+// close flushes the backup contents into the .sav file
+func (cartridge *cartridge) close() {
+	if cartridge.storage == nil {
+		return
+	}
+	if err := cartridge.storage.close(); err != nil {
+		log.Println("Unable to write save file:", err)
+	}
+}
@@ -0,0 +1,253 @@
+package gba
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"../arm7"
+)
+
+type CartridgeTestSuite struct {
+	suite.Suite
+	directory string
+	rom       []byte
+}
+
+func (suite *CartridgeTestSuite) SetupTest() {
+	suite.directory, _ = ioutil.TempDir("", "gomu")
+	suite.rom = make([]byte, 0x400)
+	copy(suite.rom[0xA0:], "GOMU TEST")
+}
+
+func (suite *CartridgeTestSuite) TearDownTest() {
+	os.RemoveAll(suite.directory)
+}
+
+func TestCartridgeTestSuite(t *testing.T) {
+	suite.Run(t, new(CartridgeTestSuite))
+}
+
+// writeZip creates an archive with the given entries, as pairs of name and contents
+func (suite *CartridgeTestSuite) writeZip(name string, entries ...interface{}) string {
+	archivePath := filepath.Join(suite.directory, name)
+	file, _ := os.Create(archivePath)
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	for i := 0; i < len(entries); i += 2 {
+		writer, _ := archive.Create(entries[i].(string))
+		writer.Write(entries[i+1].([]byte))
+	}
+	archive.Close()
+	return archivePath
+}
+
+func (suite *CartridgeTestSuite) TestReadROMFromZip() {
+	other := []byte("other rom")
+	archivePath := suite.writeZip("game.zip",
+		"readme.txt", []byte("hello"),
+		"roms/game.GBA", suite.rom,
+		"roms/hack.agb", other,
+	)
+
+	rom, name := readROMFile(archivePath, "")
+	assert.Equal(suite.T(), suite.rom, rom)
+	assert.Equal(suite.T(), "roms/game.GBA", name)
+	rom, _ = readROMFile(archivePath, "hack.agb")
+	assert.Equal(suite.T(), other, rom)
+	rom, name = readROMFile(archivePath, "roms/hack.agb")
+	assert.Equal(suite.T(), other, rom)
+	assert.Equal(suite.T(), "roms/hack.agb", name)
+}
+
+func (suite *CartridgeTestSuite) TestReadROMFromGzip() {
+	archivePath := filepath.Join(suite.directory, "game.gba.gz")
+	file, _ := os.Create(archivePath)
+	writer := gzip.NewWriter(file)
+	writer.Write(suite.rom)
+	writer.Close()
+	file.Close()
+
+	rom, name := readROMFile(archivePath, "")
+	assert.Equal(suite.T(), suite.rom, rom)
+	assert.Equal(suite.T(), filepath.Join(suite.directory, "game.gba"), name)
+}
+
+func (suite *CartridgeTestSuite) TestFindPatchNextToROM() {
+	romPath := filepath.Join(suite.directory, "game.zip")
+	assert.Equal(suite.T(), "", findPatch(romPath))
+
+	patchPath := filepath.Join(suite.directory, "game.ups")
+	ioutil.WriteFile(patchPath, nil, 0644)
+	assert.Equal(suite.T(), patchPath, findPatch(romPath))
+	assert.Equal(suite.T(), patchPath, findPatch(filepath.Join(suite.directory, "game.gba")))
+	assert.Equal(suite.T(), patchPath, findPatch(filepath.Join(suite.directory, "game.gba.gz")))
+}
+
+func (suite *CartridgeTestSuite) TestGameDatabaseIsValid() {
+	for _, override := range gameDatabase {
+		assert.Nil(suite.T(), override.validate(), override.GameCode)
+	}
+}
+
+func (suite *CartridgeTestSuite) TestFindOverrideByGameCode() {
+	override, found := findOverride(suite.rom, []byte("BPEE"), nil)
+	assert.True(suite.T(), found)
+	saveType, _ := override.saveType()
+	assert.Equal(suite.T(), backupFlash128K, saveType)
+	assert.Equal(suite.T(), hardwareRTC, override.hardware())
+
+	_, found = findOverride(suite.rom, []byte("ZZZZ"), nil)
+	assert.False(suite.T(), found)
+}
+
+func (suite *CartridgeTestSuite) TestUserOverridesWin() {
+	crc := fmt.Sprintf("%08X", crc32.ChecksumIEEE(suite.rom))
+	overridesPath := filepath.Join(suite.directory, "overrides.json")
+	ioutil.WriteFile(overridesPath, []byte(`[
+		{"gameCode": "BPEE", "saveType": "sram"},
+		{"crc32": "`+crc+`", "saveType": "eeprom512", "hardware": ["tilt"], "idleLoop": 134218880}
+	]`), 0644)
+	userOverrides := loadOverrides(overridesPath, true)
+
+	override, _ := findOverride(make([]byte, 0x10), []byte("BPEE"), userOverrides)
+	assert.Equal(suite.T(), "sram", override.SaveType)
+
+	override, _ = findOverride(suite.rom, []byte("BPEE"), userOverrides)
+	assert.Equal(suite.T(), "eeprom512", override.SaveType)
+	assert.Equal(suite.T(), hardwareTiltSensor, override.hardware())
+	assert.Equal(suite.T(), uint32(0x08000480), override.IdleLoop)
+}
+
+func (suite *CartridgeTestSuite) TestMissingOverridesFileIsOptional() {
+	assert.Nil(suite.T(), loadOverrides(filepath.Join(suite.directory, "missing.json"), false))
+}
+
+func (suite *CartridgeTestSuite) TestMultibootImageRunsFromEWRAM() {
+	imagePath := filepath.Join(suite.directory, "game.mb")
+	ioutil.WriteFile(imagePath, suite.rom, 0644)
+
+	core := InitializeROM(imagePath, Options{})
+	defer core.Close()
+
+	assert.Equal(suite.T(), uint32(multibootEntryPoint), core.CPU.ProgramCounter())
+	assert.Equal(suite.T(), byte('G'), core.Memory.Read8(0x020000A0))
+	assert.Equal(suite.T(), bootModeMultiplay, core.Memory.Read8(0x020000C4))
+	assert.Equal(suite.T(), byte(0x01), core.Memory.Read8(0x020000C5))
+	// The cartridge slot is empty
+	assert.Equal(suite.T(), uint16(0x0000), core.Memory.Read16(0x08000000))
+}
+
+func (suite *CartridgeTestSuite) TestMultibootImageInsideArchives() {
+	archivePath := suite.writeZip("game.zip", "game.mb", suite.rom)
+	core := InitializeROM(archivePath, Options{})
+	defer core.Close()
+	assert.Equal(suite.T(), uint32(multibootEntryPoint), core.CPU.ProgramCounter())
+	assert.Equal(suite.T(), byte('G'), core.Memory.Read8(0x020000A0))
+
+	archivePath = filepath.Join(suite.directory, "game.mb.gz")
+	file, _ := os.Create(archivePath)
+	writer := gzip.NewWriter(file)
+	writer.Write(suite.rom)
+	writer.Close()
+	file.Close()
+	gzipCore := InitializeROM(archivePath, Options{})
+	defer gzipCore.Close()
+	assert.Equal(suite.T(), uint32(multibootEntryPoint), gzipCore.CPU.ProgramCounter())
+}
+
+func (suite *CartridgeTestSuite) TestBIOSIsReadProtected() {
+	romPath := filepath.Join(suite.directory, "game.gba")
+	ioutil.WriteFile(romPath, suite.rom, 0644)
+	bios := make([]byte, biosSize)
+	copy(bios, []byte{0x12, 0x34, 0x56, 0x78, 0x9A, 0xBC, 0xDE, 0xF0})
+	biosPath := filepath.Join(suite.directory, "bios.bin")
+	ioutil.WriteFile(biosPath, bios, 0644)
+
+	core := InitializeROM(romPath, Options{BIOSPath: biosPath, OverridesPath: suite.writeOverrides()})
+	defer core.Close()
+	// Booting starts from the reset vector, where the BIOS can be read
+	assert.Equal(suite.T(), uint32(0x0), core.CPU.ProgramCounter())
+	assert.Equal(suite.T(), uint32(0xF0DEBC9A), core.Memory.Read32(0x4))
+
+	// Once the game runs, reads answer the last word read from inside the BIOS
+	core.CPU.SetProgramCounter(0x08000000)
+	assert.Equal(suite.T(), uint32(0xF0DEBC9A), core.Memory.Read32(0x0))
+	assert.Equal(suite.T(), byte(0xBC), core.Memory.Read8(0x1))
+}
+
+func (suite *CartridgeTestSuite) TestBIOSReadsAfterDirectBoot() {
+	romPath := filepath.Join(suite.directory, "game.gba")
+	ioutil.WriteFile(romPath, suite.rom, 0644)
+
+	core := InitializeROM(romPath, Options{OverridesPath: suite.writeOverrides()})
+	defer core.Close()
+	assert.Equal(suite.T(), uint32(0x08000000), core.CPU.ProgramCounter())
+	assert.Equal(suite.T(), uint32(biosLatchAfterBoot), core.Memory.Read32(0x100))
+}
+
+// writeOverrides creates an empty overrides file, so the tests don't depend on the user ones
+func (suite *CartridgeTestSuite) writeOverrides() string {
+	overridesPath := filepath.Join(suite.directory, "overrides.json")
+	ioutil.WriteFile(overridesPath, []byte("[]"), 0644)
+	return overridesPath
+}
+
+func (suite *CartridgeTestSuite) TestBIOSCallsWithoutBIOS() {
+	romPath := filepath.Join(suite.directory, "game.gba")
+	ioutil.WriteFile(romPath, suite.rom, 0644)
+	core := InitializeROM(romPath, Options{OverridesPath: suite.writeOverrides()})
+	defer core.Close()
+
+	core.CPU.SetRegister(0, 100)
+	core.CPU.SetRegister(1, 7)
+	core.CPU.SoftwareInterrupt(0x06)
+	assert.Equal(suite.T(), uint32(14), core.CPU.Register(0))
+	assert.Equal(suite.T(), uint32(2), core.CPU.Register(1))
+
+	// VBlankIntrWait halts, and goes back to the SWI instruction to check again after the interrupt
+	core.Memory.Write16(0x04000200, 1<<irqVBlank)
+	core.CPU.SetProgramCounter(0x08000104)
+	core.CPU.SoftwareInterrupt(0x05)
+	assert.True(suite.T(), core.Memory.halted)
+	assert.Equal(suite.T(), uint32(0x08000100), core.CPU.ProgramCounter())
+
+	core.Memory.requestInterrupt(irqVBlank)
+	assert.False(suite.T(), core.Memory.halted)
+}
+
+func (suite *CartridgeTestSuite) TestReplacementBIOSServicesCalls() {
+	// A THUMB swi 0x06 at 0x08000100
+	suite.rom[0x100], suite.rom[0x101] = 0x06, 0xDF
+	romPath := filepath.Join(suite.directory, "game.gba")
+	ioutil.WriteFile(romPath, suite.rom, 0644)
+	core := InitializeROM(romPath, Options{ReplacementBIOS: true, OverridesPath: suite.writeOverrides()})
+	defer core.Close()
+	assert.Equal(suite.T(), uint32(0x0), core.CPU.ProgramCounter())
+
+	core.CPU.Reset(false)
+	core.CPU.InstructionMode = arm7.THUMB
+	core.CPU.SetRegister(0, 100)
+	core.CPU.SetRegister(1, 7)
+	core.CPU.SetProgramCounter(0x08000102)
+
+	// The call goes through the vectors into the trap of the replacement BIOS
+	core.CPU.SoftwareInterrupt(0x06)
+	assert.Equal(suite.T(), uint32(0x08), core.CPU.ProgramCounter())
+	core.CPU.SetProgramCounter(0x88)
+	core.CPU.Undefined(core.Memory.Read32(0x84))
+
+	assert.Equal(suite.T(), uint32(14), core.CPU.Register(0))
+	assert.Equal(suite.T(), arm7.SYS, core.CPU.CPUMode)
+	assert.Equal(suite.T(), arm7.THUMB, core.CPU.InstructionMode)
+	assert.Equal(suite.T(), uint32(0x08000102), core.CPU.ProgramCounter())
+}
@@ -1,15 +1,191 @@
 package gba
 
-import "../arm7"
+import (
+	"log"
+	"time"
+
+	"../arm7"
+	"../bios"
+)
+
+// Options tweaks how a ROM gets loaded and emulated
+type Options struct {
+	// ArchiveEntry is the name of the ROM picked from zip archives, the first ROM inside them when empty
+	ArchiveEntry string
+	// PatchPath is the IPS, UPS or BPS patch applied to the ROM, a patch named after the ROM is looked for when empty
+	PatchPath string
+	// SaveDir is the directory where .sav files are kept, next to the ROM when empty
+	SaveDir string
+	// SaveInterval is how often the backup contents are flushed into the .sav file, never when zero
+	SaveInterval time.Duration
+	// FlashChip forces the model answered by flash backups: panasonic, sst, macronix, macronix128 or sanyo
+	FlashChip string
+	// OverridesPath is a JSON file with per game overrides, checked before the built in database
+	OverridesPath string
+	// Clock is the source of the date and time answered to games with a real time clock, the host time when nil
+	Clock Clock
+	// LightSource, RotationSource and Rumble connect the sensors of the cartridge to the outside world,
+	// a single Sensors value can be used for all of them
+	LightSource    LightSource
+	RotationSource RotationSource
+	Rumble         Rumble
+	// BIOSPath is a 16KB BIOS dump the console boots through, games start right away without the BIOS when empty
+	BIOSPath string
+	// ReplacementBIOS boots through the built in replacement BIOS when there's no BIOS dump, for games that need one
+	ReplacementBIOS bool
+	// Multiboot runs the file from EWRAM as if it had been sent through the link cable, .mb files always are
+	Multiboot bool
+}
+
+// Core envelops all the components of the console
+type Core struct {
+	CPU       *arm7.CPU
+	Memory    *Memory
+	cartridge *cartridge
+	ppu       *ppu
+	apu       *apu
+	// Address of the loop the game spins in while waiting for an interrupt, zero when unknown
+	idleLoop uint32
+	// Services the BIOS calls when running without a BIOS
+	hle *bios.HLE
+	// Cycles elapsed since booting
+	cycles uint64
+}
 
 // InitializeROM loads the rom file and extract it's headers
-func InitializeROM(romPath string) {
-	romData := readROMFile(romPath)
-	headers := extractHeaderData(romData[0x000:0x0E3])
-	logHeaderData(headers)
+func InitializeROM(romPath string, options Options) *Core {
+	romData, romName := readROMFile(romPath, options.ArchiveEntry)
+	patchPath := options.PatchPath
+	if patchPath == "" {
+		patchPath = findPatch(romPath)
+	}
+	if patchPath != "" {
+		romData = applyPatch(romData, patchPath)
+	}
+	if options.Multiboot || isMultibootPath(romName) {
+		return initializeMultiboot(romData, options)
+	}
+
+	cartridge := newCartridge(romData)
+	logHeaderData(cartridge.header)
+
+	overridesPath, required := options.OverridesPath, true
+	if overridesPath == "" {
+		overridesPath, required = defaultOverridesPath(), false
+	}
+	var userOverrides []gameOverride
+	if overridesPath != "" {
+		userOverrides = loadOverrides(overridesPath, required)
+	}
+	override, overridden := findOverride(romData, cartridge.header.gameCode, userOverrides)
+	if overridden {
+		log.Println("Game found in the database")
+	}
+	if override.IdleLoop != 0 {
+		log.Printf("Idle loop at %08X\n", override.IdleLoop)
+	}
 
+	backupType := detectBackupType(romData)
+	if saveType, found := override.saveType(); found {
+		backupType = saveType
+	}
+	cartridge.attachBackup(backupType, savePath(romPath, options.SaveDir))
+	if options.FlashChip != "" {
+		cartridge.setFlashChip(options.FlashChip)
+	}
+	if cartridge.storage != nil {
+		cartridge.storage.startAutoFlush(options.SaveInterval)
+	}
+
+	hardware := override.hardware()
+	if detectRTC(romData) {
+		hardware |= hardwareRTC
+	}
+	cartridge.attachHardware(hardware, options.Clock, options.LightSource, options.RotationSource, options.Rumble)
+
+	core := newCore(cartridge, options, true)
+	core.idleLoop = override.IdleLoop
+	// core.CPU.BranchWithLink(cartridge.header.romEntryPoint)
+	// core.CPU.BranchAndExchange([]byte{0xE5, 0x0, 0x81, 0xE5})
+
+	return core
+}
+
+// initializeMultiboot boots a multiboot image with the cartridge slot left empty
+func initializeMultiboot(image []byte, options Options) *Core {
+	core := newCore(emptyCartridge(), options, false)
+	core.Memory.loadMultiboot(image)
+	logHeaderData(extractHeaderData(image[0:0xE3]))
+	log.Printf("Multiboot image of %d bytes loaded into EWRAM\n", len(image))
+
+	core.CPU.SetProgramCounter(multibootEntryPoint)
+	return core
+}
+
+// newCore connects the CPU to the bus. When a BIOS is given it's mapped at the start of the memory, and
+// booting through it starts from the reset vector, otherwise the CPU is left as the BIOS leaves it
+// and the BIOS calls are serviced in Go. The replacement BIOS services them in Go as well, but through
+// the exception vectors like a real BIOS.
+func newCore(cartridge *cartridge, options Options, bootBIOS bool) *Core {
 	cpu := new(arm7.CPU)
-	cpu.Registers.Reset(false)
-	// cpu.BranchWithLink(headers.romEntryPoint)
-	// cpu.BranchAndExchange([]byte{0xE5, 0x0, 0x81, 0xE5})
+	memory := newMemory(cartridge)
+	memory.programCounter = cpu.ProgramCounter
+	core := &Core{CPU: cpu, Memory: memory, cartridge: cartridge, ppu: newPPU(memory), apu: newAPU(memory)}
+
+	switch {
+	case options.BIOSPath != "":
+		copy(memory.bios[:], loadBIOS(options.BIOSPath))
+	case options.ReplacementBIOS:
+		copy(memory.bios[:], replacementBIOSImage())
+		core.hle = new(bios.HLE)
+		cpu.TrapHandler = core.serviceTrap
+	default:
+		core.hle = new(bios.HLE)
+		cpu.SWIHandler = core.serviceSWI
+		bootBIOS = false
+	}
+	if !bootBIOS {
+		memory.biosLatch = biosLatchAfterBoot
+	}
+	cpu.Reset(bootBIOS)
+
+	return core
+}
+
+// Advance moves the clock of the console forward, running the components timed by it. The CPU doesn't execute
+// instructions yet, so only the display, the timers, the sound and the cartridge move. They run a sample at
+// a time at most, which lets the sound follow the timers feeding its FIFOs.
+func (core *Core) Advance(cycles int) {
+	for cycles > 0 {
+		step := cycles
+		if step > cyclesPerSample {
+			step = cyclesPerSample
+		}
+		core.ppu.tick(step)
+		core.Memory.timers.tick(step)
+		core.apu.tick(step)
+		core.cartridge.tick(step)
+		core.cycles += uint64(step)
+		cycles -= step
+	}
+}
+
+// Frame returns the last frame completed by the display, which is replaced when the next one is
+func (core *Core) Frame() *Frame {
+	return core.ppu.completed
+}
+
+// AudioSamples returns the samples produced since the last call, left and right interleaved at AudioSampleRate
+func (core *Core) AudioSamples() []int16 {
+	return core.apu.takeSamples()
+}
+
+// RunFrame advances the console by a whole frame
+func (core *Core) RunFrame() {
+	core.Advance(cyclesPerFrame)
+}
+
+// Close releases the cartridge, making sure the game progress is written into disk
+func (core *Core) Close() {
+	core.cartridge.close()
 }
@@ -0,0 +1,87 @@
+package gba
+
+// Offsets of the Direct Sound FIFOs, where the CPU or the DMA pushes the samples
+const (
+	ioFIFOA = 0x0A0
+	ioFIFOB = 0x0A4
+)
+
+// Number of 8 bits samples every FIFO holds
+const fifoSize = 32
+
+// Bits of SOUNDCNT_H for Direct Sound A, the ones of B follow: the volume is one bit higher and the others four
+const (
+	directSoundFullVolume = 1 << 2
+	directSoundRight      = 1 << 8
+	directSoundLeft       = 1 << 9
+	directSoundTimer      = 1 << 10
+	directSoundReset      = 1 << 11
+)
+
+// directSound is a channel playing signed 8 bits samples from a FIFO, one every overflow of its timer
+type directSound struct {
+	fifo  [fifoSize]int8
+	read  int
+	count int
+	// Sample being played
+	sample int8
+}
+
+// push adds a sample written into the FIFO, which is dropped when full
+func (sound *directSound) push(sample int8) {
+	if sound.count == fifoSize {
+		return
+	}
+	sound.fifo[(sound.read+sound.count)%fifoSize] = sample
+	sound.count++
+}
+
+// pop moves to the next sample, the last one keeps playing when the FIFO is empty
+func (sound *directSound) pop() {
+	if sound.count == 0 {
+		return
+	}
+	sound.sample = sound.fifo[sound.read]
+	sound.read = (sound.read + 1) % fifoSize
+	sound.count--
+}
+
+func (sound *directSound) reset() {
+	sound.read, sound.count = 0, 0
+}
+
+// timerOverflowed plays the next sample of the channels driven by the timer, asking the DMA for more once
+// their FIFO is half empty
+func (apu *apu) timerOverflowed(index int) {
+	if !apu.enabled() {
+		return
+	}
+	control := apu.memory.readIO16(ioSOUNDCNTH)
+	for i := range apu.directSounds {
+		timer := 0
+		if control&(directSoundTimer<<uint(4*i)) != 0 {
+			timer = 1
+		}
+		if timer != index {
+			continue
+		}
+		sound := &apu.directSounds[i]
+		sound.pop()
+		if sound.count <= fifoSize/2 {
+			apu.memory.dma.soundFIFORequest(ioFIFOA + uint32(i)*4)
+		}
+	}
+}
+
+// directSoundLevel is the output of a Direct Sound channel on a side, in the 10 bits range of the DAC
+func (apu *apu) directSoundLevel(index int, side int) int {
+	control := apu.memory.readIO16(ioSOUNDCNTH)
+	if control>>uint(4*index+side)&directSoundRight == 0 {
+		return 0
+	}
+	level := int(apu.directSounds[index].sample) * 2
+	if control>>uint(index)&directSoundFullVolume != 0 {
+		level *= 2
+	}
+	return level
+}
@@ -0,0 +1,188 @@
+package gba
+
+// Constants for defining when a DMA channel starts its transfer, bits 12-13 of DMAxCNT_H
+const (
+	dmaImmediately uint16 = iota
+	dmaVBlank
+	dmaHBlank
+	dmaSpecial
+)
+
+// Constants for defining how the addresses move after every unit, bits 5-6 and 7-8 of DMAxCNT_H
+const (
+	dmaIncrement uint16 = iota
+	dmaDecrement
+	dmaFixed
+	dmaIncrementReload
+)
+
+// Bits of DMAxCNT_H
+const (
+	dmaRepeat  = 1 << 9
+	dmaWord    = 1 << 10
+	dmaIRQ     = 1 << 14
+	dmaEnabled = 1 << 15
+)
+
+// dmaChannel holds the internal registers of a channel, latched from the I/O registers when it gets enabled
+type dmaChannel struct {
+	index       int
+	source      uint32
+	destination uint32
+	count       uint32
+	control     uint16
+}
+
+func (channel *dmaChannel) timing() uint16 {
+	return (channel.control >> 12) & 0x3
+}
+
+// soundFIFO tells whether the channel refills a sound FIFO, which DMA1 and DMA2 do with the special timing
+func (channel *dmaChannel) soundFIFO() bool {
+	return (channel.index == 1 || channel.index == 2) && channel.timing() == dmaSpecial
+}
+
+func (channel *dmaChannel) destinationControl() uint16 {
+	return (channel.control >> 5) & 0x3
+}
+
+func (channel *dmaChannel) sourceControl() uint16 {
+	return (channel.control >> 7) & 0x3
+}
+
+// Masks of the addresses each channel is able to reach, only DMA3 can write into the Game Pak
+var (
+	dmaSourceMasks      = [4]uint32{0x07FFFFFF, 0x0FFFFFFF, 0x0FFFFFFF, 0x0FFFFFFF}
+	dmaDestinationMasks = [4]uint32{0x07FFFFFF, 0x07FFFFFF, 0x07FFFFFF, 0x0FFFFFFF}
+)
+
+// dmaController moves data around the bus on behalf of the CPU through its four channels
+type dmaController struct {
+	memory   *Memory
+	channels [4]dmaChannel
+}
+
+func newDMAController(memory *Memory) *dmaController {
+	controller := &dmaController{memory: memory}
+	for i := range controller.channels {
+		controller.channels[i].index = i
+	}
+	return controller
+}
+
+func (controller *dmaController) registers(index int) uint32 {
+	return ioDMA0SAD + uint32(index)*ioDMAChannelSize
+}
+
+// loadCount reads the word count register, where zero means the biggest transfer the channel supports
+func (controller *dmaController) loadCount(channel *dmaChannel) {
+	count := uint32(controller.memory.readIO16(controller.registers(channel.index) + 8))
+	if channel.index == 3 {
+		count &= 0xFFFF
+		if count == 0 {
+			count = 0x10000
+		}
+	} else {
+		count &= 0x3FFF
+		if count == 0 {
+			count = 0x4000
+		}
+	}
+	channel.count = count
+}
+
+// controlWritten latches the channel registers when its enable bit goes from 0 to 1
+func (controller *dmaController) controlWritten(index int) {
+	channel := &controller.channels[index]
+	registers := controller.registers(index)
+	control := controller.memory.readIO16(registers + 10)
+
+	wasEnabled := channel.control&dmaEnabled != 0
+	channel.control = control
+	if control&dmaEnabled == 0 || wasEnabled {
+		return
+	}
+
+	channel.source = controller.memory.readIO32(registers) & dmaSourceMasks[index]
+	channel.destination = controller.memory.readIO32(registers+4) & dmaDestinationMasks[index]
+	controller.loadCount(channel)
+
+	if channel.timing() == dmaImmediately {
+		controller.transfer(channel)
+	}
+}
+
+// trigger starts every enabled channel waiting for the given timing
+func (controller *dmaController) trigger(timing uint16) {
+	for i := range controller.channels {
+		channel := &controller.channels[i]
+		if channel.control&dmaEnabled != 0 && channel.timing() == timing {
+			controller.transfer(channel)
+		}
+	}
+}
+
+// soundFIFORequest starts the channels refilling the sound FIFO at the given I/O offset
+func (controller *dmaController) soundFIFORequest(offset uint32) {
+	for i := range controller.channels {
+		channel := &controller.channels[i]
+		if channel.control&dmaEnabled != 0 && channel.soundFIFO() && channel.destination == 0x04000000+offset {
+			controller.transfer(channel)
+		}
+	}
+}
+
+func addressStep(control uint16, unitSize uint32) uint32 {
+	switch control {
+	case dmaDecrement:
+		return -unitSize
+	case dmaFixed:
+		return 0
+	}
+	return unitSize
+}
+
+func (controller *dmaController) transfer(channel *dmaChannel) {
+	memory := controller.memory
+	unitSize := uint32(2)
+	if channel.control&dmaWord != 0 {
+		unitSize = 4
+	}
+	count := channel.count
+	sourceStep := addressStep(channel.sourceControl(), unitSize)
+	destinationStep := addressStep(channel.destinationControl(), unitSize)
+	// Sound FIFOs always get four words into the same address, whatever the registers say
+	if channel.soundFIFO() {
+		count, unitSize, sourceStep, destinationStep = 4, 4, addressStep(channel.sourceControl(), 4), 0
+	}
+
+	// The EEPROM figures out its address width from the length of the requests sent to it
+	if channel.destination>>24 == regionROM2Mirror {
+		memory.cartridge.eepromTransferStarted(channel.count)
+	}
+
+	for i := uint32(0); i < count; i++ {
+		if unitSize == 4 {
+			memory.Write32(channel.destination&^0x3, memory.Read32(channel.source&^0x3))
+		} else {
+			memory.Write16(channel.destination&^0x1, memory.Read16(channel.source&^0x1))
+		}
+		channel.source += sourceStep
+		channel.destination += destinationStep
+	}
+
+	if channel.control&dmaIRQ != 0 {
+		memory.requestInterrupt(irqDMA0 + uint(channel.index))
+	}
+
+	if channel.control&dmaRepeat != 0 && channel.timing() != dmaImmediately {
+		controller.loadCount(channel)
+		if channel.destinationControl() == dmaIncrementReload && !channel.soundFIFO() {
+			channel.destination = memory.readIO32(controller.registers(channel.index)+4) & dmaDestinationMasks[channel.index]
+		}
+		return
+	}
+
+	channel.control &^= dmaEnabled
+	memory.storeIO16(controller.registers(channel.index)+10, channel.control)
+}
@@ -0,0 +1,160 @@
+package gba
+
+import "log"
+
+// Sizes of the EEPROM chips, they're read and written in blocks of 64 bits
+const (
+	eeprom512Size   = 0x200
+	eeprom8KSize    = 0x2000
+	eepromBlockBits = 64
+)
+
+// Lengths in bits of the requests DMA sends, which reveal the width of the addresses the game uses
+const (
+	eeprom512ReadRequest  = 2 + 6 + 1
+	eeprom512WriteRequest = 2 + 6 + 64 + 1
+	eeprom8KReadRequest   = 2 + 14 + 1
+	eeprom8KWriteRequest  = 2 + 14 + 64 + 1
+)
+
+// Constants for defining the two bit requests that start every transfer
+const (
+	eepromRequestWrite = 0x2
+	eepromRequestRead  = 0x3
+)
+
+// Bits answered before the 64 data bits of a read request, the first 4 are meaningless
+const eepromReadBits = 4 + eepromBlockBits
+
+// Cycles the chip stays busy after programming a block, around 6.8ms
+const eepromWriteCycles = 115000
+
+// eeprom emulates the serial EEPROM chips, that get one bit at a time through bit 0 of halfword accesses
+// into the top of the ROM address space, almost always through DMA3.
+type eeprom struct {
+	storage *backupStorage
+	// Width of the addresses, 6 bits for 512 bytes chips and 14 bits for 8KB ones, 0 until it's known
+	addressBits int
+	// Bits received so far of the request being sent
+	request     uint32
+	requestBits int
+	address     uint32
+	addressRead int
+	data        uint64
+	dataBits    int
+	// Block and bits left to be answered of the last read request
+	readAddress       uint32
+	readBitsRemaining int
+	// Cycles left until the last write completes
+	busyCycles int
+}
+
+func newEEPROM(storage *backupStorage) *eeprom {
+	eeprom := &eeprom{storage: storage}
+	if storage.len() == eeprom512Size {
+		eeprom.addressBits = 6
+	}
+	return eeprom
+}
+
+// transferStarted detects the chip size from the length of the first DMA request sent to it
+func (eeprom *eeprom) transferStarted(length uint32) {
+	if eeprom.addressBits != 0 {
+		return
+	}
+	switch length {
+	case eeprom512ReadRequest, eeprom512WriteRequest:
+		log.Println("EEPROM size: 512 bytes")
+		eeprom.addressBits = 6
+		eeprom.storage.resize(eeprom512Size)
+	case eeprom8KReadRequest, eeprom8KWriteRequest:
+		log.Println("EEPROM size: 8 KB")
+		eeprom.addressBits = 14
+		eeprom.storage.resize(eeprom8KSize)
+	}
+}
+
+func (eeprom *eeprom) width() int {
+	if eeprom.addressBits == 0 {
+		// Nothing was detected yet, guess from the size of the storage
+		if eeprom.storage.len() == eeprom512Size {
+			return 6
+		}
+		return 14
+	}
+	return eeprom.addressBits
+}
+
+func (eeprom *eeprom) read16() uint16 {
+	if eeprom.readBitsRemaining == 0 {
+		// Bit 0 reports whether the chip is ready for a new request
+		if eeprom.busyCycles > 0 {
+			return 0x0
+		}
+		return 0x1
+	}
+
+	eeprom.readBitsRemaining--
+	if eeprom.readBitsRemaining >= eepromBlockBits {
+		return 0x0
+	}
+	bit := uint32(eepromBlockBits - 1 - eeprom.readBitsRemaining)
+	return uint16(eeprom.bit(eeprom.readAddress*eepromBlockBits + bit))
+}
+
+func (eeprom *eeprom) write16(value uint16) {
+	bit := uint32(value & 0x1)
+
+	if eeprom.requestBits < 2 {
+		eeprom.request = eeprom.request<<1 | bit
+		eeprom.requestBits++
+		if eeprom.requestBits == 2 && eeprom.request != eepromRequestRead && eeprom.request != eepromRequestWrite {
+			log.Printf("Unknown EEPROM request %02b\n", eeprom.request)
+			eeprom.resetRequest()
+		}
+		return
+	}
+
+	if eeprom.addressRead < eeprom.width() {
+		eeprom.address = eeprom.address<<1 | bit
+		eeprom.addressRead++
+		return
+	}
+
+	if eeprom.request == eepromRequestWrite && eeprom.dataBits < eepromBlockBits {
+		eeprom.data = eeprom.data<<1 | uint64(bit)
+		eeprom.dataBits++
+		return
+	}
+
+	// Every request finishes with a 0 bit
+	block := eeprom.address & (uint32(eeprom.storage.len()/8) - 1)
+	switch eeprom.request {
+	case eepromRequestRead:
+		eeprom.readAddress = block
+		eeprom.readBitsRemaining = eepromReadBits
+	case eepromRequestWrite:
+		for i := 0; i < 8; i++ {
+			eeprom.storage.set(int(block)*8+i, byte(eeprom.data>>uint(56-8*i)))
+		}
+		eeprom.busyCycles = eepromWriteCycles
+	}
+	eeprom.resetRequest()
+}
+
+func (eeprom *eeprom) resetRequest() {
+	eeprom.request, eeprom.requestBits = 0, 0
+	eeprom.address, eeprom.addressRead = 0, 0
+	eeprom.data, eeprom.dataBits = 0, 0
+}
+
+// bit returns a single bit of the contents, the most significant bit of every byte goes first
+func (eeprom *eeprom) bit(index uint32) byte {
+	return (eeprom.storage.get(int(index/8)) >> (7 - index%8)) & 0x1
+}
+
+func (eeprom *eeprom) tick(cycles int) {
+	if eeprom.busyCycles > 0 {
+		eeprom.busyCycles -= cycles
+	}
+}
@@ -0,0 +1,171 @@
+package gba
+
+// Bits of DISPCNT enabling the windows
+const (
+	dispcntWIN0   = 1 << 13
+	dispcntWIN1   = 1 << 14
+	dispcntOBJWIN = 1 << 15
+)
+
+// Offsets of the windows, mosaic and blending registers
+const (
+	ioWIN0H    = 0x040
+	ioWIN0V    = 0x044
+	ioWININ    = 0x048
+	ioWINOUT   = 0x04A
+	ioMOSAIC   = 0x04C
+	ioBLDCNT   = 0x050
+	ioBLDALPHA = 0x052
+	ioBLDY     = 0x054
+)
+
+// Layers the windows and blending registers refer to, with one bit each
+const (
+	layerOBJ      = 4
+	layerBackdrop = 5
+	// The windows enable or disable the special effects with the bit after the layers
+	windowEffects = 1 << 6
+	allLayers     = windowEffects<<1 - 1
+)
+
+// Color special effects, from bits 6 and 7 of BLDCNT
+const (
+	effectNone = iota
+	effectAlpha
+	effectBrighten
+	effectDarken
+)
+
+// Bit of BGxCNT and of the first attribute of the sprites enabling the mosaic
+const (
+	bgcntMosaic = 1 << 6
+	objMosaic   = 1 << 12
+)
+
+// insideWindow tells whether a coordinate is between the edges of a window, the right or bottom edge
+// being excluded. Windows whose first edge is past the second one wrap around the screen.
+func insideWindow(position int, edges uint16, limit int) bool {
+	start, end := int(edges>>8), int(edges&0xFF)
+	if end > limit {
+		end = limit
+	}
+	if start <= end {
+		return position >= start && position < end
+	}
+	return position >= start || position < end
+}
+
+// windowMasks returns the layers shown on every pixel of the current line, along with the effects bit
+func (renderer *renderer) windowMasks(dispcnt uint16) *[ScreenWidth]uint16 {
+	masks := &renderer.windowMask
+	if dispcnt&(dispcntWIN0|dispcntWIN1|dispcntOBJWIN) == 0 {
+		for x := range masks {
+			masks[x] = allLayers
+		}
+		return masks
+	}
+
+	winin := renderer.io16(ioWININ)
+	winout := renderer.io16(ioWINOUT)
+	var windows [2]bool
+	for window := range windows {
+		enabled := dispcnt&(dispcntWIN0<<uint(window)) != 0
+		edges := renderer.io16(ioWIN0V + uint32(window)*2)
+		windows[window] = enabled && insideWindow(renderer.line, edges, ScreenHeight)
+	}
+
+	for x := range masks {
+		switch {
+		case windows[0] && insideWindow(x, renderer.io16(ioWIN0H), ScreenWidth):
+			masks[x] = winin & 0x3F
+		case windows[1] && insideWindow(x, renderer.io16(ioWIN0H+2), ScreenWidth):
+			masks[x] = winin >> 8 & 0x3F
+		case dispcnt&dispcntOBJWIN != 0 && renderer.objWindow[x]:
+			masks[x] = winout >> 8 & 0x3F
+		default:
+			masks[x] = winout & 0x3F
+		}
+		// The backdrop is always shown, the bit of the backdrop in the registers enables the effects
+		masks[x] = masks[x]&0x1F | 1<<layerBackdrop | masks[x]&0x20<<1
+	}
+	return masks
+}
+
+// blend applies the color special effects to the top layer of a pixel, given the one below it.
+// Semi-transparent sprites blend with the layer below whatever the selected effect.
+func (renderer *renderer) blend(top uint16, topLayer int, below uint16, belowLayer int, semiTransparent bool) uint16 {
+	bldcnt := renderer.io16(ioBLDCNT)
+	secondTarget := bldcnt&(1<<(8+uint(belowLayer))) != 0
+	if semiTransparent && secondTarget {
+		return renderer.alphaBlend(top, below)
+	}
+	if bldcnt&(1<<uint(topLayer)) == 0 {
+		return top
+	}
+
+	switch bldcnt >> 6 & 0x3 {
+	case effectAlpha:
+		if secondTarget {
+			return renderer.alphaBlend(top, below)
+		}
+	case effectBrighten:
+		evy := coefficient(renderer.io16(ioBLDY))
+		return mapChannels(top, func(channel uint16) uint16 {
+			return channel + (31-channel)*evy/16
+		})
+	case effectDarken:
+		evy := coefficient(renderer.io16(ioBLDY))
+		return mapChannels(top, func(channel uint16) uint16 {
+			return channel - channel*evy/16
+		})
+	}
+	return top
+}
+
+// alphaBlend mixes two colors with the coefficients of BLDALPHA, saturating every channel
+func (renderer *renderer) alphaBlend(first uint16, second uint16) uint16 {
+	bldalpha := renderer.io16(ioBLDALPHA)
+	eva, evb := coefficient(bldalpha), coefficient(bldalpha>>8)
+	var color uint16
+	for shift := uint(0); shift < 15; shift += 5 {
+		channel := (first>>shift&0x1F*eva + second>>shift&0x1F*evb) / 16
+		if channel > 0x1F {
+			channel = 0x1F
+		}
+		color |= channel << shift
+	}
+	return color
+}
+
+// coefficient returns a blending coefficient in sixteenths, which stops at 16
+func coefficient(value uint16) uint16 {
+	if value&0x1F > 16 {
+		return 16
+	}
+	return value & 0x1F
+}
+
+// mapChannels applies a function to the red, green and blue channels of a color
+func mapChannels(color uint16, function func(uint16) uint16) uint16 {
+	var result uint16
+	for shift := uint(0); shift < 15; shift += 5 {
+		result |= function(color>>shift&0x1F) << shift
+	}
+	return result
+}
+
+// mosaicSizes returns the width and height of the mosaic blocks of the backgrounds, or of the sprites
+func (renderer *renderer) mosaicSizes(objects bool) (int, int) {
+	mosaic := renderer.io16(ioMOSAIC)
+	if objects {
+		mosaic >>= 8
+	}
+	return int(mosaic&0xF) + 1, int(mosaic>>4&0xF) + 1
+}
+
+// applyMosaic stretches the first pixel of every block over the whole block
+func applyMosaic(layer *[ScreenWidth]uint16, width int) {
+	for x := range layer {
+		layer[x] = layer[x-x%width]
+	}
+}
@@ -0,0 +1,189 @@
+package gba
+
+import "log"
+
+// Sizes of the flash chips and their banks
+const (
+	flashBankSize   = 0x10000
+	flashSectorSize = 0x1000
+	flash64KSize    = flashBankSize
+	flash128KSize   = 2 * flashBankSize
+)
+
+// flashChip identifies a flash chip model through the IDs it answers in chip identification mode
+type flashChip struct {
+	name         string
+	manufacturer byte
+	device       byte
+	size         int
+}
+
+// Games check the IDs to pick the right driver and refuse to save when they don't know the chip
+var flashChips = []flashChip{
+	{"panasonic", 0x32, 0x1B, flash64KSize},
+	{"sst", 0xBF, 0xD4, flash64KSize},
+	{"macronix", 0xC2, 0x1C, flash64KSize},
+	{"macronix128", 0xC2, 0x09, flash128KSize},
+	{"sanyo", 0x62, 0x13, flash128KSize},
+}
+
+// findFlashChip looks a chip up by name, returning false when it's unknown
+func findFlashChip(name string) (flashChip, bool) {
+	for _, chip := range flashChips {
+		if chip.name == name {
+			return chip, true
+		}
+	}
+	return flashChip{}, false
+}
+
+// defaultFlashChip returns the chip used when none is configured for the given size
+func defaultFlashChip(size int) flashChip {
+	if size == flash128KSize {
+		chip, _ := findFlashChip("sanyo")
+		return chip
+	}
+	chip, _ := findFlashChip("panasonic")
+	return chip
+}
+
+// Addresses where the unlock sequence of every command is written
+const (
+	flashCommandAddress1 = 0x5555
+	flashCommandAddress2 = 0x2AAA
+)
+
+// Commands written into flashCommandAddress1 after the unlock sequence
+const (
+	flashCommandEraseSector  = 0x30
+	flashCommandEraseChip    = 0x10
+	flashCommandPrepareErase = 0x80
+	flashCommandEnterID      = 0x90
+	flashCommandWriteByte    = 0xA0
+	flashCommandSwitchBank   = 0xB0
+	flashCommandExitID       = 0xF0
+)
+
+// Constants for defining the steps of the command unlock sequence
+const (
+	flashReady int = iota
+	flashUnlocking
+	flashUnlocked
+)
+
+// flash emulates the 64KB and 128KB flash chips, driven by command sequences written into the backup region.
+// 128KB chips are split into two 64KB banks, only one of them is visible at a time.
+type flash struct {
+	storage *backupStorage
+	chip    flashChip
+	// Step of the AAh -> 5555h, 55h -> 2AAAh unlock sequence
+	state int
+	// Chip identification mode, where the first two bytes answer the chip IDs instead of the contents
+	identifying bool
+	// An erase command was prepared and the next unlocked command selects what gets erased
+	erasing bool
+	// The next write programs a byte instead of being a command
+	programming bool
+	// The next write into 0000h selects the bank
+	switchingBank bool
+	bank          int
+}
+
+func newFlash(storage *backupStorage, chip flashChip) *flash {
+	return &flash{storage: storage, chip: chip}
+}
+
+func (flash *flash) offset(address uint32) int {
+	return flash.bank*flashBankSize + int(address&(flashBankSize-1))
+}
+
+func (flash *flash) read8(address uint32) byte {
+	if flash.identifying {
+		switch address & (flashBankSize - 1) {
+		case 0x0:
+			return flash.chip.manufacturer
+		case 0x1:
+			return flash.chip.device
+		}
+	}
+	return flash.storage.get(flash.offset(address))
+}
+
+func (flash *flash) write8(address uint32, value byte) {
+	address &= flashBankSize - 1
+
+	if flash.programming {
+		flash.programming = false
+		flash.storage.set(flash.offset(address), value)
+		return
+	}
+	if flash.switchingBank {
+		flash.switchingBank = false
+		if address == 0x0 {
+			flash.bank = int(value & 0x1)
+		}
+		return
+	}
+
+	switch flash.state {
+	case flashReady:
+		if address == flashCommandAddress1 && value == 0xAA {
+			flash.state = flashUnlocking
+		} else if value == flashCommandExitID {
+			// Some drivers leave the identification mode without the unlock sequence
+			flash.identifying = false
+		}
+	case flashUnlocking:
+		if address == flashCommandAddress2 && value == 0x55 {
+			flash.state = flashUnlocked
+		} else {
+			flash.state = flashReady
+		}
+	case flashUnlocked:
+		flash.state = flashReady
+		flash.command(address, value)
+	}
+}
+
+// command executes a command written after the unlock sequence
+func (flash *flash) command(address uint32, value byte) {
+	if flash.erasing {
+		flash.erasing = false
+		switch {
+		case address == flashCommandAddress1 && value == flashCommandEraseChip:
+			for offset := 0; offset < flash.chip.size; offset++ {
+				flash.storage.set(offset, 0xFF)
+			}
+		case value == flashCommandEraseSector:
+			sector := flash.offset(address &^ (flashSectorSize - 1))
+			for offset := sector; offset < sector+flashSectorSize; offset++ {
+				flash.storage.set(offset, 0xFF)
+			}
+		default:
+			log.Printf("Unknown flash erase command %02X at %04X\n", value, address)
+		}
+		return
+	}
+
+	if address != flashCommandAddress1 {
+		log.Printf("Unknown flash command %02X at %04X\n", value, address)
+		return
+	}
+
+	switch value {
+	case flashCommandEnterID:
+		flash.identifying = true
+	case flashCommandExitID:
+		flash.identifying = false
+	case flashCommandPrepareErase:
+		flash.erasing = true
+	case flashCommandWriteByte:
+		flash.programming = true
+	case flashCommandSwitchBank:
+		if flash.chip.size == flash128KSize {
+			flash.switchingBank = true
+		}
+	default:
+		log.Printf("Unknown flash command %02X\n", value)
+	}
+}
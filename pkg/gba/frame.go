@@ -0,0 +1,74 @@
+package gba
+
+import (
+	"image"
+	"image/color"
+)
+
+// Size of the screen in pixels
+const (
+	ScreenWidth  = 240
+	ScreenHeight = visibleLines
+)
+
+// BGR555 is a color of the console, with 5 bits per channel and red in the lowest bits
+type BGR555 uint16
+
+// RGBA implements color.Color, scaling the channels to 16 bits
+func (c BGR555) RGBA() (r, g, b, a uint32) {
+	expand := func(channel uint16) uint32 {
+		channel &= 0x1F
+		value := uint32(channel<<3 | channel>>2)
+		return value | value<<8
+	}
+	return expand(uint16(c)), expand(uint16(c) >> 5), expand(uint16(c) >> 10), 0xFFFF
+}
+
+// BGR555Model converts any color into the colors of the console
+var BGR555Model = color.ModelFunc(func(c color.Color) color.Color {
+	if c, ok := c.(BGR555); ok {
+		return c
+	}
+	r, g, b, _ := c.RGBA()
+	return BGR555(r>>11 | g>>11<<5 | b>>11<<10)
+})
+
+// Frame holds a picture of the screen, with colors in the BGR555 format of the console.
+// It implements image.Image, so it can be encoded like any other image.
+type Frame struct {
+	Pixels [ScreenHeight][ScreenWidth]uint16
+}
+
+// ColorModel implements image.Image
+func (frame *Frame) ColorModel() color.Model {
+	return BGR555Model
+}
+
+// Bounds implements image.Image
+func (frame *Frame) Bounds() image.Rectangle {
+	return image.Rect(0, 0, ScreenWidth, ScreenHeight)
+}
+
+// At implements image.Image
+func (frame *Frame) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(frame.Bounds())) {
+		return BGR555(0)
+	}
+	return BGR555(frame.Pixels[y][x])
+}
+
+// RGBA converts the frame into an image with 8 bits per channel
+func (frame *Frame) RGBA() *image.RGBA {
+	rgba := image.NewRGBA(frame.Bounds())
+	for y := range frame.Pixels {
+		for x, pixel := range frame.Pixels[y] {
+			r, g, b, _ := BGR555(pixel).RGBA()
+			offset := rgba.PixOffset(x, y)
+			rgba.Pix[offset] = byte(r >> 8)
+			rgba.Pix[offset+1] = byte(g >> 8)
+			rgba.Pix[offset+2] = byte(b >> 8)
+			rgba.Pix[offset+3] = 0xFF
+		}
+	}
+	return rgba
+}
@@ -0,0 +1,189 @@
+package gba
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Constants for defining the extra hardware a cartridge may come with
+const (
+	hardwareRTC = 1 << iota
+	hardwareSolarSensor
+	hardwareGyroSensor
+	hardwareTiltSensor
+	hardwareRumble
+)
+
+var hardwareNames = map[string]int{
+	"rtc":    hardwareRTC,
+	"solar":  hardwareSolarSensor,
+	"gyro":   hardwareGyroSensor,
+	"tilt":   hardwareTiltSensor,
+	"rumble": hardwareRumble,
+}
+
+var saveTypeNames = map[string]backupType{
+	"none":      backupNone,
+	"sram":      backupSRAM,
+	"flash64k":  backupFlash64K,
+	"flash128k": backupFlash128K,
+	"eeprom":    backupEEPROM,
+	"eeprom512": backupEEPROM512,
+	"eeprom8k":  backupEEPROM8K,
+}
+
+// gameOverride holds the settings of a game that can't be detected from its contents.
+// It's matched either by the CRC32 or SHA-1 of the ROM, or by its game code, where a 3 characters code
+// matches every region of the game.
+type gameOverride struct {
+	GameCode string `json:"gameCode,omitempty"`
+	CRC32    string `json:"crc32,omitempty"`
+	SHA1     string `json:"sha1,omitempty"`
+	// One of none, sram, flash64k, flash128k, eeprom, eeprom512 or eeprom8k
+	SaveType string `json:"saveType,omitempty"`
+	// Any of rtc, solar, gyro, tilt and rumble
+	Hardware []string `json:"hardware,omitempty"`
+	// Address of the loop the game spins in while waiting for an interrupt
+	IdleLoop uint32 `json:"idleLoop,omitempty"`
+}
+
+// Games whose hardware is either impossible or unreliable to detect
+var gameDatabase = []gameOverride{
+	// Boktai: The Sun Is in Your Hand
+	{GameCode: "U3I", SaveType: "eeprom", Hardware: []string{"rtc", "solar"}},
+	// Boktai 2: Solar Boy Django
+	{GameCode: "U32", SaveType: "eeprom", Hardware: []string{"rtc", "solar"}},
+	// Shin Bokura no Taiyou: Gyakushuu no Sabata
+	{GameCode: "U33", SaveType: "eeprom", Hardware: []string{"rtc", "solar"}},
+	// Dragon Ball Z: The Legacy of Goku II
+	{GameCode: "ALF", SaveType: "eeprom"},
+	// Dragon Ball Z: Taiketsu
+	{GameCode: "BDB", SaveType: "eeprom"},
+	// Drill Dozer
+	{GameCode: "V49", SaveType: "sram", Hardware: []string{"rumble"}},
+	// F-Zero: Climax
+	{GameCode: "BFT", SaveType: "flash128k"},
+	// Koro Koro Puzzle: Happy Panechu!
+	{GameCode: "KHP", SaveType: "eeprom", Hardware: []string{"tilt"}},
+	// Pokémon Ruby
+	{GameCode: "AXV", SaveType: "flash128k", Hardware: []string{"rtc"}},
+	// Pokémon Sapphire
+	{GameCode: "AXP", SaveType: "flash128k", Hardware: []string{"rtc"}},
+	// Pokémon Emerald
+	{GameCode: "BPE", SaveType: "flash128k", Hardware: []string{"rtc"}},
+	// Pokémon FireRed
+	{GameCode: "BPR", SaveType: "flash128k"},
+	// Pokémon LeafGreen
+	{GameCode: "BPG", SaveType: "flash128k"},
+	// Pokémon Mystery Dungeon: Red Rescue Team
+	{GameCode: "B24", SaveType: "flash128k"},
+	// Rockman EXE 4.5: Real Operation
+	{GameCode: "BR4", SaveType: "flash64k", Hardware: []string{"rtc"}},
+	// Sennen Kazoku
+	{GameCode: "BKA", SaveType: "flash128k", Hardware: []string{"rtc"}},
+	// Super Mario Advance 4: Super Mario Bros. 3
+	{GameCode: "AX4", SaveType: "flash128k"},
+	// Top Gun: Combat Zones
+	{GameCode: "A2Y", SaveType: "none"},
+	// WarioWare: Twisted!
+	{GameCode: "RZW", SaveType: "sram", Hardware: []string{"gyro", "rumble"}},
+	// Yoshi Topsy-Turvy
+	{GameCode: "KYG", SaveType: "eeprom", Hardware: []string{"tilt"}},
+}
+
+// defaultOverridesPath is where the user override file is looked for when none is configured
+func defaultOverridesPath() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "gomu", "overrides.json")
+}
+
+// loadOverrides reads a JSON file holding a list of overrides, a missing file is only an error when it was asked for
+func loadOverrides(path string, required bool) []gameOverride {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var overrides []gameOverride
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		log.Fatal("Unable to read ", path, ": ", err)
+	}
+	for _, override := range overrides {
+		if err := override.validate(); err != nil {
+			log.Fatal("Invalid override in ", path, ": ", err)
+		}
+	}
+	log.Println(len(overrides), "overrides loaded from", path)
+	return overrides
+}
+
+func (override gameOverride) validate() error {
+	if override.GameCode == "" && override.CRC32 == "" && override.SHA1 == "" {
+		return fmt.Errorf("an override needs a game code or a hash")
+	}
+	if _, found := saveTypeNames[override.SaveType]; override.SaveType != "" && !found {
+		return fmt.Errorf("unknown save type %s", override.SaveType)
+	}
+	for _, name := range override.Hardware {
+		if _, found := hardwareNames[name]; !found {
+			return fmt.Errorf("unknown hardware %s", name)
+		}
+	}
+	return nil
+}
+
+func (override gameOverride) saveType() (backupType, bool) {
+	backupType, found := saveTypeNames[override.SaveType]
+	return backupType, found
+}
+
+func (override gameOverride) hardware() int {
+	hardware := 0
+	for _, name := range override.Hardware {
+		hardware |= hardwareNames[name]
+	}
+	return hardware
+}
+
+// findOverride looks up the override of a ROM, the user overrides are checked before the built in database.
+// An override matching the hashes of the ROM wins over one matching its game code, which lets ROM hacks
+// and prototypes be configured separately from the games they share the code with.
+func findOverride(romData []byte, gameCode []byte, userOverrides []gameOverride) (gameOverride, bool) {
+	overrides := append(append([]gameOverride(nil), userOverrides...), gameDatabase...)
+
+	crc := fmt.Sprintf("%08x", crc32.ChecksumIEEE(romData))
+	sha := sha1.Sum(romData)
+	shaHex := hex.EncodeToString(sha[:])
+	for _, override := range overrides {
+		if (override.CRC32 != "" && strings.EqualFold(override.CRC32, crc)) || (override.SHA1 != "" && strings.EqualFold(override.SHA1, shaHex)) {
+			return override, true
+		}
+	}
+
+	code := string(gameCode)
+	for _, override := range overrides {
+		if override.GameCode == code {
+			return override, true
+		}
+	}
+	for _, override := range overrides {
+		if len(override.GameCode) == 3 && strings.HasPrefix(code, override.GameCode) {
+			return override, true
+		}
+	}
+	return gameOverride{}, false
+}
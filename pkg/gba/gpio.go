@@ -0,0 +1,72 @@
+package gba
+
+// Offsets of the GPIO registers inside the ROM address space
+const (
+	gpioData      = 0xC4
+	gpioDirection = 0xC6
+	gpioControl   = 0xC8
+)
+
+// gpioDevice is a chip wired to the 4 general purpose pins of a cartridge
+type gpioDevice interface {
+	// pinsWritten is called every time the game changes the state of the pins
+	pinsWritten(gpio *gpio)
+}
+
+// gpio is the 4 bit general purpose port some cartridges expose through 0x080000C4-0x080000C9,
+// used to talk to the extra hardware on them, like real time clocks or sensors.
+type gpio struct {
+	// State of the 4 pins
+	pins byte
+	// Pins driven by the console are set to 1, the rest are driven by the devices
+	direction byte
+	// The registers can be read back only after enabling it, otherwise the ROM contents are seen
+	readable bool
+	devices  []gpioDevice
+}
+
+func newGPIO() *gpio {
+	return new(gpio)
+}
+
+func (gpio *gpio) attach(device gpioDevice) {
+	gpio.devices = append(gpio.devices, device)
+}
+
+// isGPIORegister tells whether a ROM offset belongs to the GPIO registers
+func isGPIORegister(offset uint32) bool {
+	return offset >= gpioData && offset < gpioControl+2
+}
+
+func (gpio *gpio) read16(offset uint32) uint16 {
+	switch offset &^ 0x1 {
+	case gpioData:
+		return uint16(gpio.pins)
+	case gpioDirection:
+		return uint16(gpio.direction)
+	case gpioControl:
+		if gpio.readable {
+			return 0x1
+		}
+	}
+	return 0x0
+}
+
+func (gpio *gpio) write16(offset uint32, value uint16) {
+	switch offset &^ 0x1 {
+	case gpioData:
+		gpio.pins = (gpio.pins &^ gpio.direction) | (byte(value) & gpio.direction & 0xF)
+		for _, device := range gpio.devices {
+			device.pinsWritten(gpio)
+		}
+	case gpioDirection:
+		gpio.direction = byte(value) & 0xF
+	case gpioControl:
+		gpio.readable = value&0x1 != 0
+	}
+}
+
+// output lets a device drive the pins that aren't driven by the console
+func (gpio *gpio) output(pins byte) {
+	gpio.pins = (gpio.pins & gpio.direction) | (pins &^ gpio.direction & 0xF)
+}
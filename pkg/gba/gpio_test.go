@@ -0,0 +1,150 @@
+package gba
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type GPIOTestSuite struct {
+	suite.Suite
+	memory *Memory
+}
+
+func (suite *GPIOTestSuite) SetupTest() {
+	rom := make([]byte, 0x200)
+	rom[gpioData] = 0xAB
+	suite.memory = newMemory(newCartridge(rom))
+}
+
+func TestGPIOTestSuite(t *testing.T) {
+	suite.Run(t, new(GPIOTestSuite))
+}
+
+func (suite *GPIOTestSuite) writePins(pins uint16) {
+	suite.memory.Write16(0x080000C4, pins)
+}
+
+func (suite *GPIOTestSuite) sendRTCByte(value byte) {
+	for i := uint(0); i < 8; i++ {
+		bit := uint16(value>>i) & 0x1
+		suite.writePins(rtcCS | bit<<1)
+		suite.writePins(rtcCS | rtcSCK | bit<<1)
+	}
+}
+
+func (suite *GPIOTestSuite) receiveRTCByte() byte {
+	var value byte
+	for i := uint(0); i < 8; i++ {
+		suite.writePins(rtcCS)
+		suite.writePins(rtcCS | rtcSCK)
+		value |= byte(suite.memory.Read16(0x080000C4)&rtcSIO) >> 1 << i
+	}
+	return value
+}
+
+// readRTC sends a read command and returns the bytes answered by the clock
+func (suite *GPIOTestSuite) readRTC(command byte, length int) []byte {
+	suite.memory.Write16(0x080000C8, 0x1)
+	suite.memory.Write16(0x080000C6, 0x7)
+	suite.writePins(rtcSCK)
+	suite.writePins(rtcCS | rtcSCK)
+	suite.sendRTCByte(0x86 | command<<4)
+
+	suite.memory.Write16(0x080000C6, 0x5)
+	answer := make([]byte, length)
+	for i := range answer {
+		answer[i] = suite.receiveRTCByte()
+	}
+	suite.writePins(rtcSCK)
+	return answer
+}
+
+func (suite *GPIOTestSuite) TestRegistersAreHiddenUntilReadable() {
+	suite.memory.cartridge.attachGPIODevice(newRTC(nil))
+
+	assert.Equal(suite.T(), uint16(0xAB), suite.memory.Read16(0x080000C4))
+	suite.memory.Write16(0x080000C8, 0x1)
+	suite.memory.Write16(0x080000C6, 0x7)
+	assert.Equal(suite.T(), uint16(0x7), suite.memory.Read16(0x080000C6))
+	assert.Equal(suite.T(), uint16(0x1), suite.memory.Read16(0x080000C8))
+}
+
+func (suite *GPIOTestSuite) TestRTCAnswersDateAndTime() {
+	clock := FixedClock{time.Date(2004, time.March, 18, 21, 45, 9, 0, time.UTC)}
+	suite.memory.cartridge.attachGPIODevice(newRTC(clock))
+
+	assert.Equal(suite.T(), []byte{0x04, 0x03, 0x18, 0x04, 0xA1, 0x45, 0x09}, suite.readRTC(rtcDateTime, 7))
+	assert.Equal(suite.T(), []byte{0xA1, 0x45, 0x09}, suite.readRTC(rtcTime, 3))
+	assert.Equal(suite.T(), []byte{rtcStatus24Hours}, suite.readRTC(rtcStatus, 1))
+}
+
+func (suite *GPIOTestSuite) TestRTCDetection() {
+	assert.True(suite.T(), detectRTC([]byte("xxSIIRTC_V001")))
+	assert.False(suite.T(), detectRTC([]byte("xxSRAM_V113")))
+}
+
+// readSolarSensor clocks the sensor until it raises its flag, like Boktai does
+func (suite *GPIOTestSuite) readSolarSensor() int {
+	suite.memory.Write16(0x080000C8, 0x1)
+	suite.memory.Write16(0x080000C6, 0x7)
+	suite.writePins(solarReset)
+	suite.writePins(0)
+	for counter := 0; counter < 0x100; counter++ {
+		if suite.memory.Read16(0x080000C4)&solarFlag != 0 {
+			return counter
+		}
+		suite.writePins(solarClock)
+		suite.writePins(0)
+	}
+	return 0x100
+}
+
+func (suite *GPIOTestSuite) TestSolarSensorFollowsLightLevel() {
+	sensors := new(Sensors)
+	suite.memory.cartridge.attachHardware(hardwareSolarSensor, nil, sensors, nil, nil)
+
+	sensors.SetLightLevel(0)
+	dark := suite.readSolarSensor()
+	sensors.SetLightLevel(200)
+	bright := suite.readSolarSensor()
+
+	assert.Equal(suite.T(), 0xFF, dark)
+	assert.Equal(suite.T(), 0xFF-200, bright)
+}
+
+func (suite *GPIOTestSuite) TestGyroSensorAndRumble() {
+	sensors := new(Sensors)
+	suite.memory.cartridge.attachHardware(hardwareGyroSensor|hardwareRumble, nil, nil, sensors, sensors)
+	suite.memory.Write16(0x080000C8, 0x1)
+	suite.memory.Write16(0x080000C6, 0xB)
+
+	suite.writePins(gyroSample | rumblePin)
+	assert.True(suite.T(), sensors.Rumbling())
+
+	var sample uint16
+	for i := 0; i < 16; i++ {
+		suite.writePins(gyroClock)
+		suite.writePins(0)
+		sample = sample<<1 | (suite.memory.Read16(0x080000C4)&gyroData)>>2
+	}
+	assert.Equal(suite.T(), uint16(0x6C0), sample)
+	assert.False(suite.T(), sensors.Rumbling())
+}
+
+func (suite *GPIOTestSuite) TestTiltSensorInBackupRegion() {
+	sensors := new(Sensors)
+	sensors.SetTilt(1, -1)
+	suite.memory.cartridge.attachHardware(hardwareTiltSensor, nil, nil, sensors, nil)
+
+	suite.memory.Write8(0x0E008000, 0x55)
+	suite.memory.Write8(0x0E008100, 0xAA)
+	x := uint16(suite.memory.Read8(0x0E008200)) | uint16(suite.memory.Read8(0x0E008300)&0xF)<<8
+	y := uint16(suite.memory.Read8(0x0E008400)) | uint16(suite.memory.Read8(0x0E008500)&0xF)<<8
+
+	assert.Equal(suite.T(), byte(0x80), suite.memory.Read8(0x0E008300)&0x80)
+	assert.Equal(suite.T(), uint16(0x3A0+0x1FF), x)
+	assert.Equal(suite.T(), uint16(0x3A0-0x200), y)
+}
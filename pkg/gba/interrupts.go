@@ -0,0 +1,30 @@
+package gba
+
+// Constants for defining the interrupt sources, in the order of their bits in IE and IF
+const (
+	irqVBlank uint = iota
+	irqHBlank
+	irqVCounter
+	irqTimer0
+	irqTimer1
+	irqTimer2
+	irqTimer3
+	irqSerial
+	irqDMA0
+	irqDMA1
+	irqDMA2
+	irqDMA3
+	irqKeypad
+	irqGamePak
+)
+
+// requestInterrupt raises the flag of an interrupt source in IF, which wakes up the CPU when the source is enabled in IE
+func (memory *Memory) requestInterrupt(irq uint) {
+	memory.storeIO16(ioIF, memory.readIO16(ioIF)|1<<irq)
+	if memory.readIO16(ioIE)&(1<<irq) == 0 {
+		return
+	}
+	if !memory.stopped || irq == irqKeypad || irq == irqGamePak || irq == irqSerial {
+		memory.halted, memory.stopped = false, false
+	}
+}
@@ -0,0 +1,82 @@
+package gba
+
+// Offsets of the I/O registers from 0x04000000
+const (
+	ioDISPCNT  = 0x000
+	ioDISPSTAT = 0x004
+	ioVCOUNT   = 0x006
+	ioDMA0SAD  = 0x0B0
+	ioDMA0CNTH = 0x0BA
+	ioDMA3CNTH = 0x0DE
+	ioIE       = 0x200
+	ioIF       = 0x202
+	ioIME      = 0x208
+	ioHALTCNT  = 0x301
+)
+
+// Size in bytes of the register block of each DMA channel
+const ioDMAChannelSize = 0x0C
+
+func (memory *Memory) readIO8(offset uint32) byte {
+	if offset >= ioSOUND1CNTL && offset < ioSoundEnd && memory.apu != nil {
+		return memory.apu.read8(offset)
+	}
+	if offset >= ioTM0CNTL && offset < ioTimersEnd {
+		return memory.timers.read8(offset)
+	}
+	return memory.io[offset]
+}
+
+// writeIO8 stores a byte into the I/O registers, applying the side effects of the register it belongs to
+func (memory *Memory) writeIO8(offset uint32, value byte) {
+	switch offset {
+	case ioIF, ioIF + 1:
+		// Writing a 1 into an interrupt flag acknowledges it
+		memory.io[offset] &^= value
+		return
+	case ioDISPSTAT:
+		// The status flags are read only
+		memory.io[offset] = memory.io[offset]&dispstatFlags | value&^dispstatFlags
+		return
+	case ioVCOUNT, ioVCOUNT + 1:
+		return
+	case ioHALTCNT:
+		memory.halted = true
+		memory.stopped = value&0x80 != 0
+		return
+	}
+	if offset >= ioSOUND1CNTL && offset < ioSoundEnd && memory.apu != nil {
+		memory.apu.write8(offset, value)
+		return
+	}
+	memory.io[offset] = value
+
+	switch {
+	case offset == ioDISPSTAT+1 && memory.ppu != nil:
+		memory.ppu.vcountSettingWritten()
+	case isReferenceRegister(offset) && memory.ppu != nil:
+		memory.ppu.referenceWritten(offset)
+	case offset >= ioTM0CNTL && offset < ioTimersEnd:
+		if (offset-ioTM0CNTL)%ioTimerSize == 2 {
+			memory.timers.controlWritten(int((offset - ioTM0CNTL) / ioTimerSize))
+		}
+	case offset >= ioDMA0SAD && offset <= ioDMA3CNTH+1:
+		channel := (offset - ioDMA0SAD) / ioDMAChannelSize
+		if offset == ioDMA0CNTH+1+channel*ioDMAChannelSize {
+			memory.dma.controlWritten(int(channel))
+		}
+	}
+}
+
+func (memory *Memory) readIO16(offset uint32) uint16 {
+	return uint16(memory.io[offset]) | uint16(memory.io[offset+1])<<8
+}
+
+func (memory *Memory) readIO32(offset uint32) uint32 {
+	return uint32(memory.readIO16(offset)) | uint32(memory.readIO16(offset+2))<<16
+}
+
+func (memory *Memory) storeIO16(offset uint32, value uint16) {
+	memory.io[offset] = byte(value)
+	memory.io[offset+1] = byte(value >> 8)
+}
@@ -0,0 +1,182 @@
+package gba
+
+// Sizes of every memory region of the system
+const (
+	biosSize    = 0x4000
+	ewramSize   = 0x40000
+	iwramSize   = 0x8000
+	ioSize      = 0x400
+	paletteSize = 0x400
+	vramSize    = 0x18000
+	oamSize     = 0x400
+)
+
+// Regions of the memory map, selected by the top 8 bits of an address
+const (
+	regionBIOS uint32 = iota
+	regionUnused
+	regionEWRAM
+	regionIWRAM
+	regionIO
+	regionPalette
+	regionVRAM
+	regionOAM
+	regionROM0
+	regionROM0Mirror
+	regionROM1
+	regionROM1Mirror
+	regionROM2
+	regionROM2Mirror
+	regionSRAM
+	regionSRAMMirror
+)
+
+// Memory is the system bus, it decodes every address into the component that owns it
+type Memory struct {
+	bios      [biosSize]byte
+	ewram     [ewramSize]byte
+	iwram     [iwramSize]byte
+	io        [ioSize]byte
+	palette   [paletteSize]byte
+	vram      [vramSize]byte
+	oam       [oamSize]byte
+	cartridge *cartridge
+	dma       *dmaController
+	timers    *timerController
+	ppu       *ppu
+	apu       *apu
+	// Last word read from inside the BIOS, and the address being executed which tells whether it's protected
+	biosLatch      uint32
+	programCounter func() uint32
+	// Set by HALTCNT, the CPU waits for an interrupt while halted, and for a keypad, cartridge or serial one while stopped
+	halted  bool
+	stopped bool
+}
+
+func newMemory(cartridge *cartridge) *Memory {
+	memory := &Memory{cartridge: cartridge}
+	memory.dma = newDMAController(memory)
+	memory.timers = newTimerController(memory)
+	return memory
+}
+
+// vramOffset folds the 128KB VRAM mirror into the 96KB that actually exist
+func vramOffset(address uint32) uint32 {
+	offset := address & 0x1FFFF
+	if offset >= vramSize {
+		offset -= 0x8000
+	}
+	return offset
+}
+
+// videoMemoryWritten lets the display draw the lines pending before the memory they use changes
+func (memory *Memory) videoMemoryWritten() {
+	if memory.ppu != nil {
+		memory.ppu.videoMemoryWritten()
+	}
+}
+
+// Read8 reads a single byte from the bus
+func (memory *Memory) Read8(address uint32) byte {
+	switch address >> 24 {
+	case regionBIOS:
+		if address < biosSize {
+			return byte(memory.readBIOS32(address) >> (8 * (address & 0x3)))
+		}
+	case regionEWRAM:
+		return memory.ewram[address&(ewramSize-1)]
+	case regionIWRAM:
+		return memory.iwram[address&(iwramSize-1)]
+	case regionIO:
+		if address&0xFFFFFF < ioSize {
+			return memory.readIO8(address & (ioSize - 1))
+		}
+	case regionPalette:
+		return memory.palette[address&(paletteSize-1)]
+	case regionVRAM:
+		return memory.vram[vramOffset(address)]
+	case regionOAM:
+		return memory.oam[address&(oamSize-1)]
+	case regionROM0, regionROM0Mirror, regionROM1, regionROM1Mirror, regionROM2, regionROM2Mirror:
+		return memory.cartridge.readROM8(address)
+	case regionSRAM, regionSRAMMirror:
+		return memory.cartridge.readBackup8(address)
+	}
+	return 0x0
+}
+
+// Read16 reads a halfword from the bus, unaligned addresses are forced into alignment
+func (memory *Memory) Read16(address uint32) uint16 {
+	switch address >> 24 {
+	case regionROM0, regionROM0Mirror, regionROM1, regionROM1Mirror, regionROM2, regionROM2Mirror:
+		return memory.cartridge.readROM16(address)
+	case regionSRAM, regionSRAMMirror:
+		// The backup bus is 8 bits wide, the same byte is seen on every lane
+		return uint16(memory.cartridge.readBackup8(address)) * 0x0101
+	}
+	address &^= 0x1
+	return uint16(memory.Read8(address)) | uint16(memory.Read8(address+1))<<8
+}
+
+// Read32 reads a word from the bus, unaligned addresses are forced into alignment
+func (memory *Memory) Read32(address uint32) uint32 {
+	switch address >> 24 {
+	case regionSRAM, regionSRAMMirror:
+		return uint32(memory.cartridge.readBackup8(address)) * 0x01010101
+	}
+	address &^= 0x3
+	return uint32(memory.Read16(address)) | uint32(memory.Read16(address+2))<<16
+}
+
+// Write8 writes a single byte into the bus
+func (memory *Memory) Write8(address uint32, value byte) {
+	switch address >> 24 {
+	case regionEWRAM:
+		memory.ewram[address&(ewramSize-1)] = value
+	case regionIWRAM:
+		memory.iwram[address&(iwramSize-1)] = value
+	case regionIO:
+		if address&0xFFFFFF < ioSize {
+			memory.writeIO8(address&(ioSize-1), value)
+		}
+	case regionPalette:
+		memory.videoMemoryWritten()
+		memory.palette[address&(paletteSize-1)] = value
+	case regionVRAM:
+		memory.videoMemoryWritten()
+		memory.vram[vramOffset(address)] = value
+	case regionOAM:
+		memory.videoMemoryWritten()
+		memory.oam[address&(oamSize-1)] = value
+	case regionSRAM, regionSRAMMirror:
+		memory.cartridge.writeBackup8(address, value)
+	}
+}
+
+// Write16 writes a halfword into the bus, unaligned addresses are forced into alignment
+func (memory *Memory) Write16(address uint32, value uint16) {
+	switch address >> 24 {
+	case regionROM0, regionROM0Mirror, regionROM1, regionROM1Mirror, regionROM2, regionROM2Mirror:
+		memory.cartridge.writeROM16(address, value)
+		return
+	case regionSRAM, regionSRAMMirror:
+		// Only the byte lane selected by the address reaches the backup chip
+		memory.cartridge.writeBackup8(address, byte(value>>(8*(address&0x1))))
+		return
+	}
+	address &^= 0x1
+	memory.Write8(address, byte(value))
+	memory.Write8(address+1, byte(value>>8))
+}
+
+// Write32 writes a word into the bus, unaligned addresses are forced into alignment
+func (memory *Memory) Write32(address uint32, value uint32) {
+	switch address >> 24 {
+	case regionSRAM, regionSRAMMirror:
+		memory.cartridge.writeBackup8(address, byte(value>>(8*(address&0x3))))
+		return
+	}
+	address &^= 0x3
+	memory.Write16(address, uint16(value))
+	memory.Write16(address+2, uint16(value>>16))
+}
@@ -0,0 +1,46 @@
+package gba
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// Multiboot images are executed from EWRAM, starting at the RAM entry point of their header
+const (
+	multibootBase       = 0x02000000
+	multibootEntryPoint = multibootBase + 0x0C0
+)
+
+// Constants for defining the transfer modes the BIOS writes into the boot mode of the header
+const (
+	bootModeJoybus byte = iota + 1
+	bootModeNormal
+	bootModeMultiplay
+)
+
+// isMultibootPath tells whether a file is a multiboot image by its extension
+func isMultibootPath(romPath string) bool {
+	return strings.EqualFold(filepath.Ext(romPath), ".mb")
+}
+
+// emptyCartridge stands for an empty cartridge slot
+func emptyCartridge() *cartridge {
+	return &cartridge{header: new(cartridgeHeader)}
+}
+
+// loadMultiboot copies a multiboot image into EWRAM the way the BIOS leaves it after receiving it through
+// the link cable, with the boot mode and the slave ID written into the header. The console is treated as
+// the first slave of a multiplay session, the mode used to download games from another console.
+func (memory *Memory) loadMultiboot(image []byte) {
+	if len(image) < 0x0E4 {
+		log.Fatal("The multiboot image is too small to hold a header")
+	}
+	if len(image) > ewramSize {
+		log.Fatal("The multiboot image doesn't fit into the ", ewramSize/1024, "KB of EWRAM")
+	}
+
+	copy(memory.ewram[:], image)
+	memory.ewram[0x0C4] = bootModeMultiplay
+	memory.ewram[0x0C5] = 0x01
+}
@@ -0,0 +1,284 @@
+package gba
+
+// Bits of DISPCNT about the sprites
+const (
+	dispcntHBlankFree = 1 << 5
+	dispcntOBJ1D      = 1 << 6
+	dispcntOBJ        = 1 << 12
+)
+
+// Number of sprites in OAM, and the size of their attributes
+const (
+	objCount     = 128
+	objEntrySize = 8
+)
+
+// Modes of the sprites, from bits 8 and 9 of the first attribute
+const (
+	objNormal = iota
+	objAffine
+	objDisabled
+	objAffineDouble
+)
+
+// Graphics modes of the sprites, from bits 10 and 11 of the first attribute
+const (
+	objGraphicsNormal = iota
+	objGraphicsSemiTransparent
+	objGraphicsWindow
+)
+
+// Cycles available to draw the sprites of a line, fewer when the OAM is left free during the horizontal blank
+const (
+	objCyclesPerLine    = 1210
+	objCyclesHBlankFree = 954
+)
+
+// Sprite tiles start after the backgrounds, which take a part of them in the bitmap modes
+const (
+	objVRAMBase   = 0x10000
+	objVRAMBitmap = 0x14000
+	objVRAMMask   = 0x7FFF
+)
+
+// Width and height of the sprites, by shape and size
+var objSizes = [3][4][2]int{
+	{{8, 8}, {16, 16}, {32, 32}, {64, 64}},
+	{{16, 8}, {32, 8}, {32, 16}, {64, 32}},
+	{{8, 16}, {8, 32}, {16, 32}, {32, 64}},
+}
+
+// objPixel is a pixel of the sprites layer
+type objPixel struct {
+	color           uint16
+	priority        uint16
+	semiTransparent bool
+}
+
+// object holds the attributes of a sprite
+type object struct {
+	x, y          int
+	width, height int
+	mode          uint16
+	graphics      uint16
+	colors256     bool
+	flipX, flipY  bool
+	affineIndex   uint32
+	tile          uint32
+	priority      uint16
+	paletteBank   uint32
+	// Width of the mosaic blocks, 1 without the mosaic
+	mosaicWidth int
+}
+
+func (memory *Memory) oam16(offset uint32) uint16 {
+	return uint16(memory.oam[offset]) | uint16(memory.oam[offset+1])<<8
+}
+
+// readObject decodes the attributes of a sprite, returning false for the prohibited shape
+func (memory *Memory) readObject(index int) (object, bool) {
+	attributes := [3]uint16{}
+	for i := range attributes {
+		attributes[i] = memory.oam16(uint32(index*objEntrySize + i*2))
+	}
+
+	shape := attributes[0] >> 14
+	if shape == 3 {
+		return object{}, false
+	}
+	size := objSizes[shape][attributes[1]>>14]
+	obj := object{
+		x:           int(attributes[1] & 0x1FF),
+		y:           int(attributes[0] & 0xFF),
+		width:       size[0],
+		height:      size[1],
+		mode:        attributes[0] >> 8 & 0x3,
+		graphics:    attributes[0] >> 10 & 0x3,
+		colors256:   attributes[0]&(1<<13) != 0,
+		flipX:       attributes[1]&(1<<12) != 0,
+		flipY:       attributes[1]&(1<<13) != 0,
+		affineIndex: uint32(attributes[1] >> 9 & 0x1F),
+		tile:        uint32(attributes[2] & 0x3FF),
+		priority:    attributes[2] >> 10 & 0x3,
+		paletteBank: uint32(attributes[2] >> 12),
+		mosaicWidth: 1,
+	}
+	// X is a 9 bits signed number
+	if obj.x >= 256 {
+		obj.x -= 512
+	}
+	return obj, true
+}
+
+// bounds returns the size of the area covered by a sprite, which double size affine sprites double
+func (obj *object) bounds() (int, int) {
+	if obj.mode == objAffineDouble {
+		return obj.width * 2, obj.height * 2
+	}
+	return obj.width, obj.height
+}
+
+// cycles returns the time taken to draw a line of a sprite
+func (obj *object) cycles() int {
+	if obj.mode == objNormal {
+		return obj.width
+	}
+	width, _ := obj.bounds()
+	return 10 + width*2
+}
+
+// renderObjects draws the sprites on the current line, in OAM order until the cycles of the line run out.
+// Sprites in front come first in OAM, and otherwise the ones with a lower priority win.
+func (renderer *renderer) renderObjects(dispcnt uint16) {
+	for x := range renderer.objects {
+		renderer.objects[x] = objPixel{color: transparent}
+		renderer.objWindow[x] = false
+	}
+	if dispcnt&dispcntOBJ == 0 {
+		return
+	}
+
+	budget := objCyclesPerLine
+	if dispcnt&dispcntHBlankFree != 0 {
+		budget = objCyclesHBlankFree
+	}
+	tileBase := uint32(objVRAMBase)
+	if dispcnt&dispcntModeMask >= 3 {
+		tileBase = objVRAMBitmap
+	}
+
+	for index := 0; index < objCount; index++ {
+		obj, valid := renderer.memory.readObject(index)
+		if !valid || obj.mode == objDisabled {
+			continue
+		}
+		width, height := obj.bounds()
+		// Y wraps around, so sprites near the bottom also show at the top
+		y := (renderer.line - obj.y) & 0xFF
+		if y >= height {
+			continue
+		}
+		if renderer.memory.oam16(uint32(index*objEntrySize))&objMosaic != 0 {
+			// The mosaic blocks are aligned on the screen, so the first one may start above the sprite
+			mosaicWidth, mosaicHeight := renderer.mosaicSizes(true)
+			obj.mosaicWidth = mosaicWidth
+			if y -= renderer.line % mosaicHeight; y < 0 {
+				y = 0
+			}
+		}
+
+		budget -= obj.cycles()
+		if budget < 0 {
+			return
+		}
+		if obj.mode == objNormal {
+			renderer.renderNormalObject(&obj, y, dispcnt, tileBase)
+		} else {
+			renderer.renderAffineObject(&obj, y, width, height, dispcnt, tileBase)
+		}
+	}
+}
+
+// mosaicX returns the horizontal position within the sprite area of the pixel shown on a column of the screen
+func (obj *object) mosaicX(screenX int) int {
+	x := screenX - screenX%obj.mosaicWidth - obj.x
+	if x < 0 {
+		return 0
+	}
+	return x
+}
+
+// renderNormalObject draws a line of a sprite that can only be flipped
+func (renderer *renderer) renderNormalObject(obj *object, y int, dispcnt uint16, tileBase uint32) {
+	if obj.flipY {
+		y = obj.height - 1 - y
+	}
+	for spriteX := 0; spriteX < obj.width; spriteX++ {
+		screenX := obj.x + spriteX
+		if screenX < 0 || screenX >= ScreenWidth {
+			continue
+		}
+		x := obj.mosaicX(screenX)
+		if obj.flipX {
+			x = obj.width - 1 - x
+		}
+		renderer.plotObject(obj, screenX, renderer.objectColor(obj, x, y, dispcnt, tileBase))
+	}
+}
+
+// renderAffineObject draws a line of a rotated and scaled sprite, whose matrix maps the screen around the
+// center of the sprite into its texture
+func (renderer *renderer) renderAffineObject(obj *object, y int, width int, height int, dispcnt uint16, tileBase uint32) {
+	parameters := obj.affineIndex * 4 * objEntrySize
+	pa := int(int16(renderer.memory.oam16(parameters + 6)))
+	pb := int(int16(renderer.memory.oam16(parameters + objEntrySize + 6)))
+	pc := int(int16(renderer.memory.oam16(parameters + objEntrySize*2 + 6)))
+	pd := int(int16(renderer.memory.oam16(parameters + objEntrySize*3 + 6)))
+
+	dy := y - height/2
+	for boundsX := 0; boundsX < width; boundsX++ {
+		screenX := obj.x + boundsX
+		if screenX < 0 || screenX >= ScreenWidth {
+			continue
+		}
+		dx := obj.mosaicX(screenX) - width/2
+		x := (pa*dx+pb*dy)>>8 + obj.width/2
+		y := (pc*dx+pd*dy)>>8 + obj.height/2
+		if x < 0 || y < 0 || x >= obj.width || y >= obj.height {
+			continue
+		}
+		renderer.plotObject(obj, screenX, renderer.objectColor(obj, x, y, dispcnt, tileBase))
+	}
+}
+
+// objectColor returns the color of a pixel of a sprite. The tiles of a sprite follow each other with the
+// one dimensional mapping, and are laid out in a 32x32 tiles matrix otherwise.
+func (renderer *renderer) objectColor(obj *object, x int, y int, dispcnt uint16, tileBase uint32) uint16 {
+	// Tile numbers count 32 bytes, so the tiles of 256 colors take two of them
+	tileUnits := uint32(1)
+	if obj.colors256 {
+		tileUnits = 2
+	}
+	first := obj.tile
+	rowTiles := uint32(32)
+	if dispcnt&dispcntOBJ1D != 0 {
+		rowTiles = uint32(obj.width/8) * tileUnits
+	} else if obj.colors256 {
+		// The 2D mapping ignores bit 0 of the tile number for 256 colors
+		first &^= 1
+	}
+	tile := (first + uint32(y/8)*rowTiles + uint32(x/8)*tileUnits) & 0x3FF
+	if objVRAMBase+tile*32 < tileBase {
+		return transparent
+	}
+
+	// The last tiles of 256 colors run past the end of the sprite VRAM, wrapping to its start
+	tileX, tileY := uint32(x&7), uint32(y&7)
+	if obj.colors256 {
+		index := uint32(renderer.memory.vram[objVRAMBase+(tile*32+tileY*8+tileX)&objVRAMMask])
+		if index == 0 {
+			return transparent
+		}
+		return renderer.memory.paletteColor(256 + index)
+	}
+	index := uint32(renderer.memory.vram[objVRAMBase+(tile*32+tileY*4+tileX/2)&objVRAMMask]>>(4*(tileX&1))) & 0xF
+	if index == 0 {
+		return transparent
+	}
+	return renderer.memory.paletteColor(256 + obj.paletteBank*16 + index)
+}
+
+// plotObject puts a pixel of a sprite on the sprites layer, or on the OBJ window for the sprites that define it
+func (renderer *renderer) plotObject(obj *object, x int, color uint16) {
+	if color == transparent {
+		return
+	}
+	if obj.graphics == objGraphicsWindow {
+		renderer.objWindow[x] = true
+		return
+	}
+	pixel := &renderer.objects[x]
+	if pixel.color == transparent || obj.priority < pixel.priority {
+		*pixel = objPixel{color: color, priority: obj.priority, semiTransparent: obj.graphics == objGraphicsSemiTransparent}
+	}
+}
@@ -0,0 +1,145 @@
+package gba
+
+// Timing of the LCD, every scanline is drawn during its first 1006 cycles and followed by the horizontal blank.
+// The 160 visible lines are followed by 68 lines of vertical blank.
+const (
+	cyclesPerLine  = 1232
+	hblankStart    = 1006
+	visibleLines   = 160
+	linesPerFrame  = 228
+	cyclesPerFrame = cyclesPerLine * linesPerFrame
+)
+
+// Bits of DISPSTAT, the lowest 3 are status flags that can't be written, and the highest 8 hold the line
+// compared against VCOUNT
+const (
+	dispstatVBlank      = 1 << 0
+	dispstatHBlank      = 1 << 1
+	dispstatVCounter    = 1 << 2
+	dispstatVBlankIRQ   = 1 << 3
+	dispstatHBlankIRQ   = 1 << 4
+	dispstatVCounterIRQ = 1 << 5
+	dispstatFlags       = dispstatVBlank | dispstatHBlank | dispstatVCounter
+)
+
+// ppu is the picture processing unit, which walks through the scanlines of every frame updating
+// the status registers and raising the interrupts and DMA transfers timed by the display
+type ppu struct {
+	memory *Memory
+	// Cycle within the current line
+	cycle int
+	line  int
+	// The frame being drawn, and the last one completed
+	frame     *Frame
+	completed *Frame
+	// Internal reference points of BG2 and BG3, X and Y
+	references [2][2]int32
+	// Registers of every line of the frame, the lines recorded and the ones drawn so far
+	snapshots   [ScreenHeight]lineState
+	snapshotted int
+	rendered    int
+	// Whether the rest of the frame is drawn line by line, because of raster effects
+	serial    bool
+	renderers []*renderer
+}
+
+func newPPU(memory *Memory) *ppu {
+	ppu := &ppu{memory: memory, frame: new(Frame), completed: new(Frame)}
+	memory.ppu = ppu
+	ppu.setWorkers(0)
+	ppu.updateVCounter()
+	return ppu
+}
+
+// tick moves the display forward, stopping at the start of every horizontal blank and every line
+func (ppu *ppu) tick(cycles int) {
+	for cycles > 0 {
+		next := hblankStart
+		if ppu.cycle >= hblankStart {
+			next = cyclesPerLine
+		}
+		step := next - ppu.cycle
+		if step > cycles {
+			step = cycles
+		}
+		ppu.cycle += step
+		cycles -= step
+
+		switch ppu.cycle {
+		case hblankStart:
+			ppu.enterHBlank()
+		case cyclesPerLine:
+			ppu.cycle = 0
+			ppu.nextLine()
+		}
+	}
+}
+
+func (ppu *ppu) dispstat() uint16 {
+	return ppu.memory.readIO16(ioDISPSTAT)
+}
+
+func (ppu *ppu) setFlag(flag uint16, set bool) {
+	dispstat := ppu.dispstat() &^ flag
+	if set {
+		dispstat |= flag
+	}
+	ppu.memory.storeIO16(ioDISPSTAT, dispstat)
+}
+
+// enterHBlank raises the horizontal blank, which is when visible lines are drawn.
+// HBlank DMA transfers only run during the visible lines.
+func (ppu *ppu) enterHBlank() {
+	if ppu.line < visibleLines {
+		ppu.snapshotLine()
+		ppu.advanceReferences()
+	}
+	ppu.setFlag(dispstatHBlank, true)
+	if ppu.dispstat()&dispstatHBlankIRQ != 0 {
+		ppu.memory.requestInterrupt(irqHBlank)
+	}
+	if ppu.line < visibleLines {
+		ppu.memory.dma.trigger(dmaHBlank)
+	}
+}
+
+func (ppu *ppu) nextLine() {
+	ppu.setFlag(dispstatHBlank, false)
+	ppu.line = (ppu.line + 1) % linesPerFrame
+	ppu.memory.storeIO16(ioVCOUNT, uint16(ppu.line))
+
+	switch ppu.line {
+	case visibleLines:
+		ppu.finishFrame()
+		ppu.latchReferences()
+		ppu.setFlag(dispstatVBlank, true)
+		if ppu.dispstat()&dispstatVBlankIRQ != 0 {
+			ppu.memory.requestInterrupt(irqVBlank)
+		}
+		ppu.memory.dma.trigger(dmaVBlank)
+	case linesPerFrame - 1:
+		// The flag goes down during the last line of the vertical blank
+		ppu.setFlag(dispstatVBlank, false)
+	}
+	ppu.updateVCounter()
+}
+
+// updateVCounter compares the current line against the one selected in DISPSTAT
+func (ppu *ppu) updateVCounter() {
+	match := int(ppu.dispstat()>>8) == ppu.line
+	ppu.setFlag(dispstatVCounter, match)
+	if match && ppu.dispstat()&dispstatVCounterIRQ != 0 {
+		ppu.memory.requestInterrupt(irqVCounter)
+	}
+}
+
+// vcountSettingWritten compares the current line against a new setting of DISPSTAT, the interrupt being
+// requested when the line starts matching
+func (ppu *ppu) vcountSettingWritten() {
+	matched := ppu.dispstat()&dispstatVCounter != 0
+	match := int(ppu.dispstat()>>8) == ppu.line
+	ppu.setFlag(dispstatVCounter, match)
+	if match && !matched && ppu.dispstat()&dispstatVCounterIRQ != 0 {
+		ppu.memory.requestInterrupt(irqVCounter)
+	}
+}
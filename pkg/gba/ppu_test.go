@@ -0,0 +1,112 @@
+package gba
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type PPUTestSuite struct {
+	suite.Suite
+	memory *Memory
+	ppu    *ppu
+}
+
+func (suite *PPUTestSuite) SetupTest() {
+	suite.memory = newMemory(emptyCartridge())
+	suite.ppu = newPPU(suite.memory)
+}
+
+func TestPPUTestSuite(t *testing.T) {
+	suite.Run(t, new(PPUTestSuite))
+}
+
+func (suite *PPUTestSuite) TestHBlank() {
+	suite.memory.Write16(0x04000200, 1<<irqHBlank)
+	suite.memory.Write16(0x04000004, dispstatHBlankIRQ)
+
+	suite.ppu.tick(hblankStart - 1)
+	assert.Equal(suite.T(), uint16(0), suite.memory.Read16(0x04000004)&dispstatHBlank)
+	suite.ppu.tick(1)
+	assert.Equal(suite.T(), uint16(dispstatHBlank), suite.memory.Read16(0x04000004)&dispstatHBlank)
+	assert.Equal(suite.T(), uint16(1<<irqHBlank), suite.memory.Read16(0x04000202))
+
+	suite.ppu.tick(cyclesPerLine - hblankStart)
+	assert.Equal(suite.T(), uint16(0), suite.memory.Read16(0x04000004)&dispstatHBlank)
+	assert.Equal(suite.T(), uint16(1), suite.memory.Read16(0x04000006))
+}
+
+func (suite *PPUTestSuite) TestVBlank() {
+	suite.memory.Write16(0x04000004, dispstatVBlankIRQ)
+	// A VBlank DMA copying a halfword into IWRAM
+	suite.memory.Write16(0x02000000, 0xBEEF)
+	suite.memory.Write32(0x040000D4, 0x02000000)
+	suite.memory.Write32(0x040000D8, 0x03000000)
+	suite.memory.Write16(0x040000DC, 1)
+	suite.memory.Write16(0x040000DE, dmaEnabled|dmaVBlank<<12)
+
+	suite.ppu.tick(cyclesPerLine*visibleLines - 1)
+	assert.Equal(suite.T(), uint16(0x0), suite.memory.Read16(0x03000000))
+	suite.ppu.tick(1)
+	assert.Equal(suite.T(), uint16(visibleLines), suite.memory.Read16(0x04000006))
+	assert.Equal(suite.T(), uint16(dispstatVBlank), suite.memory.Read16(0x04000004)&dispstatVBlank)
+	assert.Equal(suite.T(), uint16(1<<irqVBlank), suite.memory.Read16(0x04000202))
+	assert.Equal(suite.T(), uint16(0xBEEF), suite.memory.Read16(0x03000000))
+
+	// The flag stays up until the last line
+	suite.ppu.tick(cyclesPerLine * (linesPerFrame - 1 - visibleLines))
+	assert.Equal(suite.T(), uint16(0), suite.memory.Read16(0x04000004)&dispstatVBlank)
+	suite.ppu.tick(cyclesPerLine)
+	assert.Equal(suite.T(), uint16(0), suite.memory.Read16(0x04000006))
+}
+
+func (suite *PPUTestSuite) TestHBlankDMAOnlyDuringVisibleLines() {
+	suite.memory.Write32(0x040000D4, 0x02000000)
+	suite.memory.Write32(0x040000D8, 0x03000000)
+	suite.memory.Write16(0x040000DC, 1)
+	suite.memory.Write16(0x040000DE, dmaEnabled|dmaRepeat|dmaHBlank<<12|dmaFixed<<7)
+
+	suite.ppu.tick(cyclesPerFrame)
+	assert.Equal(suite.T(), uint32(0x03000000+visibleLines*2), suite.memory.dma.channels[3].destination)
+}
+
+func (suite *PPUTestSuite) TestVCounterMatch() {
+	suite.memory.Write16(0x04000200, 1<<irqVCounter)
+	suite.memory.Write16(0x04000004, 100<<8|dispstatVCounterIRQ)
+
+	suite.ppu.tick(cyclesPerLine * 99)
+	assert.Equal(suite.T(), uint16(0), suite.memory.Read16(0x04000004)&dispstatVCounter)
+	assert.Equal(suite.T(), uint16(0), suite.memory.Read16(0x04000202))
+	suite.ppu.tick(cyclesPerLine)
+	assert.Equal(suite.T(), uint16(dispstatVCounter), suite.memory.Read16(0x04000004)&dispstatVCounter)
+	assert.Equal(suite.T(), uint16(1<<irqVCounter), suite.memory.Read16(0x04000202))
+	suite.ppu.tick(cyclesPerLine)
+	assert.Equal(suite.T(), uint16(0), suite.memory.Read16(0x04000004)&dispstatVCounter)
+}
+
+func (suite *PPUTestSuite) TestVCounterSettingWrittenOnTheLine() {
+	suite.memory.Write16(0x04000200, 1<<irqVCounter)
+	suite.ppu.tick(cyclesPerLine * 50)
+
+	suite.memory.Write16(0x04000004, 50<<8|dispstatVCounterIRQ)
+	assert.Equal(suite.T(), uint16(dispstatVCounter), suite.memory.Read16(0x04000004)&dispstatVCounter)
+	assert.Equal(suite.T(), uint16(1<<irqVCounter), suite.memory.Read16(0x04000202))
+
+	// Writing the same setting again doesn't request another interrupt
+	suite.memory.Write16(0x04000202, 1<<irqVCounter)
+	suite.memory.Write16(0x04000004, 50<<8|dispstatVCounterIRQ)
+	assert.Equal(suite.T(), uint16(0), suite.memory.Read16(0x04000202))
+
+	suite.memory.Write16(0x04000004, 51<<8|dispstatVCounterIRQ)
+	assert.Equal(suite.T(), uint16(0), suite.memory.Read16(0x04000004)&dispstatVCounter)
+}
+
+func (suite *PPUTestSuite) TestStatusRegistersAreReadOnly() {
+	suite.ppu.tick(cyclesPerLine*visibleLines + hblankStart)
+	suite.memory.Write16(0x04000004, 0x0000)
+	suite.memory.Write16(0x04000006, 0x0042)
+
+	assert.Equal(suite.T(), uint16(dispstatVBlank|dispstatHBlank), suite.memory.Read16(0x04000004))
+	assert.Equal(suite.T(), uint16(visibleLines), suite.memory.Read16(0x04000006))
+}
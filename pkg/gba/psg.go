@@ -0,0 +1,334 @@
+package gba
+
+// Bits of SOUND3CNT_L
+const (
+	waveDimension = 1 << 5
+	waveBank      = 1 << 6
+	wavePlayback  = 1 << 7
+)
+
+// Bits of the high byte of the registers holding the frequency, which start the channels
+const (
+	soundLengthEnabled = 1 << 6
+	soundRestart       = 1 << 7
+)
+
+// Duty cycles of the square channels, one bit per step: 12.5%, 25%, 50% and 75%
+var dutyPatterns = [4]byte{0x01, 0x81, 0x87, 0x7E}
+
+// Dividers of the noise channel, in cycles before the shift of SOUND4CNT_H
+var noiseDividers = [8]int{32, 64, 128, 192, 256, 320, 384, 448}
+
+// lengthCounter stops a channel once it reaches 0, when enabled
+type lengthCounter struct {
+	counter int
+	enabled bool
+}
+
+func (length *lengthCounter) load(max int, value int) {
+	length.counter = max - value
+}
+
+// trigger reloads the counter when the channel restarts after it expired
+func (length *lengthCounter) trigger(max int) {
+	if length.counter == 0 {
+		length.counter = max
+	}
+}
+
+func (length *lengthCounter) step(channel *bool) {
+	if length.enabled && length.counter > 0 {
+		length.counter--
+		if length.counter == 0 {
+			*channel = false
+		}
+	}
+}
+
+// envelope changes the volume of a channel one step at a time
+type envelope struct {
+	volume   int
+	increase bool
+	period   int
+	timer    int
+}
+
+// trigger loads the envelope from the high byte of its register: the period in bits 0-2, the direction
+// in bit 3 and the initial volume in bits 4-7
+func (envelope *envelope) trigger(register byte) {
+	envelope.volume = int(register >> 4)
+	envelope.increase = register&0x08 != 0
+	envelope.period = int(register & 0x07)
+	envelope.timer = envelope.period
+}
+
+func (envelope *envelope) step() {
+	if envelope.period == 0 {
+		return
+	}
+	if envelope.timer--; envelope.timer > 0 {
+		return
+	}
+	envelope.timer = envelope.period
+	if envelope.increase && envelope.volume < 15 {
+		envelope.volume++
+	} else if !envelope.increase && envelope.volume > 0 {
+		envelope.volume--
+	}
+}
+
+// dacEnabled tells whether the envelope register leaves the channel any sound, without it the channel stops
+func dacEnabled(register byte) bool {
+	return register&0xF8 != 0
+}
+
+// squareChannel produces a square wave, with a frequency sweep for the first channel
+type squareChannel struct {
+	memory            *Memory
+	enabled           bool
+	envelopeRegister  uint32
+	frequencyRegister uint32
+	length            lengthCounter
+	envelope          envelope
+	timer             int
+	dutyStep          int
+	// The sweep works on a copy of the frequency
+	sweep        bool
+	sweepEnabled bool
+	sweepTimer   int
+	shadow       int
+}
+
+func (square *squareChannel) frequency() int {
+	return int(square.memory.readIO16(square.frequencyRegister) & 0x7FF)
+}
+
+// period is the number of cycles of every step of the duty cycle
+func (square *squareChannel) period() int {
+	return (2048 - square.frequency()) * 16
+}
+
+func (square *squareChannel) tick(cycles int) {
+	if !square.enabled {
+		return
+	}
+	for square.timer -= cycles; square.timer <= 0; square.timer += square.period() {
+		square.dutyStep = (square.dutyStep + 1) % 8
+	}
+}
+
+// output is the level of the channel between -15 and 15
+func (square *squareChannel) output() int {
+	if !square.enabled {
+		return 0
+	}
+	duty := square.memory.io[square.envelopeRegister-1] >> 6
+	if dutyPatterns[duty]&(0x80>>uint(square.dutyStep)) != 0 {
+		return square.envelope.volume
+	}
+	return -square.envelope.volume
+}
+
+func (square *squareChannel) envelopeWritten(value byte) {
+	if !dacEnabled(value) {
+		square.enabled = false
+	}
+}
+
+// controlWritten handles the high byte of the frequency register, which restarts the channel
+func (square *squareChannel) controlWritten(value byte) {
+	square.length.enabled = value&soundLengthEnabled != 0
+	if value&soundRestart == 0 {
+		return
+	}
+	register := square.memory.io[square.envelopeRegister]
+	square.enabled = dacEnabled(register)
+	square.length.trigger(64)
+	square.envelope.trigger(register)
+	square.timer = square.period()
+
+	if square.sweep {
+		sweep := square.memory.io[ioSOUND1CNTL]
+		square.shadow = square.frequency()
+		square.sweepTimer = sweepPeriod(sweep)
+		square.sweepEnabled = sweep&0x77 != 0
+		if sweep&0x07 != 0 {
+			square.nextFrequency(sweep)
+		}
+	}
+}
+
+// sweepPeriod is the number of sweep clocks between frequency changes, a period of 0 acting as 8
+func sweepPeriod(sweep byte) int {
+	if period := int(sweep >> 4 & 0x07); period != 0 {
+		return period
+	}
+	return 8
+}
+
+// nextFrequency computes the frequency after a sweep, stopping the channel when it overflows
+func (square *squareChannel) nextFrequency(sweep byte) int {
+	delta := square.shadow >> (sweep & 0x07)
+	if sweep&0x08 != 0 {
+		return square.shadow - delta
+	}
+	if next := square.shadow + delta; next <= 0x7FF {
+		return next
+	}
+	square.enabled = false
+	return 0x800
+}
+
+func (square *squareChannel) stepSweep() {
+	if square.sweepTimer--; square.sweepTimer > 0 {
+		return
+	}
+	sweep := square.memory.io[ioSOUND1CNTL]
+	square.sweepTimer = sweepPeriod(sweep)
+	if !square.enabled || !square.sweepEnabled || sweep>>4&0x07 == 0 {
+		return
+	}
+
+	next := square.nextFrequency(sweep)
+	if next <= 0x7FF && sweep&0x07 != 0 {
+		square.shadow = next
+		control := square.memory.readIO16(square.frequencyRegister)
+		square.memory.storeIO16(square.frequencyRegister, control&^0x7FF|uint16(next))
+		square.nextFrequency(sweep)
+	}
+}
+
+// waveChannel plays the 4 bits samples of the wave RAM, from one bank or from both one after the other
+type waveChannel struct {
+	memory   *Memory
+	enabled  bool
+	length   lengthCounter
+	timer    int
+	position int
+}
+
+// bank is the bank selected for playback
+func (wave *waveChannel) bank() int {
+	return int(wave.memory.io[ioSOUND3CNTL] >> 6 & 1)
+}
+
+func (wave *waveChannel) samples() int {
+	if wave.memory.io[ioSOUND3CNTL]&waveDimension != 0 {
+		return 64
+	}
+	return 32
+}
+
+// period is the number of cycles every sample plays
+func (wave *waveChannel) period() int {
+	return (2048 - int(wave.memory.readIO16(ioSOUND3CNTX)&0x7FF)) * 8
+}
+
+func (wave *waveChannel) tick(cycles int) {
+	if !wave.enabled {
+		return
+	}
+	for wave.timer -= cycles; wave.timer <= 0; wave.timer += wave.period() {
+		wave.position = (wave.position + 1) % wave.samples()
+	}
+}
+
+// output is the level of the channel between -15 and 15, after the volume of SOUND3CNT_H
+func (wave *waveChannel) output() int {
+	if !wave.enabled {
+		return 0
+	}
+	bank := (wave.bank() + wave.position/32) % 2
+	index := wave.position % 32
+	sample := wave.memory.apu.waveRAM[bank][index/2]
+	if index%2 == 0 {
+		sample >>= 4
+	}
+	level := int(sample&0x0F)*2 - 15
+
+	control := wave.memory.readIO16(ioSOUND3CNTH)
+	switch {
+	case control&0x8000 != 0:
+		return level * 3 / 4
+	case control>>13&0x03 == 0:
+		return 0
+	default:
+		return level >> (control>>13&0x03 - 1)
+	}
+}
+
+func (wave *waveChannel) controlWritten(value byte) {
+	wave.length.enabled = value&soundLengthEnabled != 0
+	if value&soundRestart == 0 {
+		return
+	}
+	wave.enabled = wave.memory.io[ioSOUND3CNTL]&wavePlayback != 0
+	wave.length.trigger(256)
+	wave.timer = wave.period()
+	wave.position = 0
+}
+
+// noiseChannel produces noise from a 15 or 7 bits linear feedback shift register
+type noiseChannel struct {
+	memory   *Memory
+	enabled  bool
+	length   lengthCounter
+	envelope envelope
+	timer    int
+	lfsr     uint16
+}
+
+// period is the number of cycles between shifts of the register, 0 when the shift stops it
+func (noise *noiseChannel) period() int {
+	control := noise.memory.io[ioSOUND4CNTH]
+	shift := uint(control >> 4)
+	if shift >= 14 {
+		return 0
+	}
+	return noiseDividers[control&0x07] << shift
+}
+
+func (noise *noiseChannel) tick(cycles int) {
+	period := noise.period()
+	if !noise.enabled || period == 0 {
+		return
+	}
+	narrow := noise.memory.io[ioSOUND4CNTH]&0x08 != 0
+	for noise.timer -= cycles; noise.timer <= 0; noise.timer += period {
+		feedback := (noise.lfsr ^ noise.lfsr>>1) & 1
+		noise.lfsr = noise.lfsr>>1 | feedback<<14
+		if narrow {
+			noise.lfsr = noise.lfsr&^(1<<6) | feedback<<6
+		}
+	}
+}
+
+// output is the level of the channel between -15 and 15
+func (noise *noiseChannel) output() int {
+	if !noise.enabled {
+		return 0
+	}
+	if noise.lfsr&1 == 0 {
+		return noise.envelope.volume
+	}
+	return -noise.envelope.volume
+}
+
+func (noise *noiseChannel) envelopeWritten(value byte) {
+	if !dacEnabled(value) {
+		noise.enabled = false
+	}
+}
+
+func (noise *noiseChannel) controlWritten(value byte) {
+	noise.length.enabled = value&soundLengthEnabled != 0
+	if value&soundRestart == 0 {
+		return
+	}
+	register := noise.memory.io[ioSOUND4CNTL+1]
+	noise.enabled = dacEnabled(register)
+	noise.length.trigger(64)
+	noise.envelope.trigger(register)
+	noise.timer = noise.period()
+	noise.lfsr = 0x7FFF
+}
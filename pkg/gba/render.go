@@ -0,0 +1,354 @@
+package gba
+
+import "sort"
+
+// Bits of DISPCNT
+const (
+	dispcntModeMask    = 0x7
+	dispcntFrameSelect = 1 << 4
+	dispcntForcedBlank = 1 << 7
+	dispcntBG0         = 1 << 8
+)
+
+// Offsets of the background registers, repeated for every background
+const (
+	ioBG0CNT  = 0x008
+	ioBG0HOFS = 0x010
+	ioBG0VOFS = 0x012
+	ioBG2PA   = 0x020
+	ioBG2PB   = 0x022
+	ioBG2PC   = 0x024
+	ioBG2PD   = 0x026
+	ioBG2X    = 0x028
+	ioBG2Y    = 0x02C
+	// Distance between the registers of BG2 and the ones of BG3
+	ioAffineSize = 0x10
+)
+
+// Bits of BGxCNT
+const (
+	bgcntPriorityMask = 0x3
+	bgcnt256Colors    = 1 << 7
+	bgcntWraparound   = 1 << 13
+)
+
+// Colors only take 15 bits, the highest one marks the pixels of a layer that let the layers below show through
+const transparent = 0x8000
+
+// White, shown while the display is in forced blank
+const white = 0x7FFF
+
+// Size of the part of VRAM holding the backgrounds in the tiled modes
+const bgVRAMSize = 0x10000
+
+// Kinds of background
+const (
+	bgNone = iota
+	bgText
+	bgAffine
+	bgBitmap
+)
+
+// Kind of every background in every mode, the bitmap modes only have BG2
+var modeBackgrounds = [6][4]int{
+	{bgText, bgText, bgText, bgText},
+	{bgText, bgText, bgAffine, bgNone},
+	{bgNone, bgNone, bgAffine, bgAffine},
+	{bgNone, bgNone, bgBitmap, bgNone},
+	{bgNone, bgNone, bgBitmap, bgNone},
+	{bgNone, bgNone, bgBitmap, bgNone},
+}
+
+// Layout of the bitmap modes, the ones with two frames select the one displayed with DISPCNT
+type bitmapMode struct {
+	width, height int32
+	paletted      bool
+	frames        bool
+}
+
+// Offset in VRAM of the second frame of the bitmap modes
+const bitmapFrameOffset = 0xA000
+
+var bitmapModes = map[int]bitmapMode{
+	3: {width: ScreenWidth, height: ScreenHeight},
+	4: {width: ScreenWidth, height: ScreenHeight, paletted: true, frames: true},
+	5: {width: 160, height: 128, frames: true},
+}
+
+// paletteColor returns a color of the palette, the backgrounds use the first 256 and the sprites the last 256
+func (memory *Memory) paletteColor(index uint32) uint16 {
+	return (uint16(memory.palette[index*2]) | uint16(memory.palette[index*2+1])<<8) & white
+}
+
+func (memory *Memory) vram16(offset uint32) uint16 {
+	return uint16(memory.vram[offset]) | uint16(memory.vram[offset+1])<<8
+}
+
+// renderLine draws a line of the frame
+func (renderer *renderer) renderLine(output *[ScreenWidth]uint16) {
+	dispcnt := renderer.io16(ioDISPCNT)
+	if dispcnt&dispcntForcedBlank != 0 {
+		for x := range output {
+			output[x] = white
+		}
+		return
+	}
+
+	backgrounds := renderer.renderBackgrounds(dispcnt)
+	renderer.renderObjects(dispcnt)
+	masks := renderer.windowMasks(dispcnt)
+	var priorities [4]uint16
+	for _, bg := range backgrounds {
+		priorities[bg] = renderer.bgPriority(bg)
+	}
+
+	backdrop := renderer.memory.paletteColor(0)
+	for x := range output {
+		// The two front layers, which the color special effects mix
+		colors := [2]uint16{backdrop, backdrop}
+		layers := [2]int{layerBackdrop, layerBackdrop}
+		found := 0
+		add := func(color uint16, layer int) {
+			if found < 2 {
+				colors[found], layers[found] = color, layer
+				found++
+			}
+		}
+
+		// Sprites go in front of the backgrounds with the same priority
+		obj := renderer.objects[x]
+		objShown := obj.color != transparent && masks[x]&(1<<layerOBJ) != 0
+		for _, bg := range backgrounds {
+			if objShown && obj.priority <= priorities[bg] {
+				add(obj.color, layerOBJ)
+				objShown = false
+			}
+			if color := renderer.layers[bg][x]; color != transparent && masks[x]&(1<<uint(bg)) != 0 {
+				add(color, bg)
+			}
+		}
+		if objShown {
+			add(obj.color, layerOBJ)
+		}
+
+		output[x] = colors[0]
+		if masks[x]&windowEffects != 0 {
+			semiTransparent := layers[0] == layerOBJ && obj.semiTransparent
+			output[x] = renderer.blend(colors[0], layers[0], colors[1], layers[1], semiTransparent)
+		}
+	}
+}
+
+// renderBackgrounds draws the line of every enabled background, returning them from the front to the back
+func (renderer *renderer) renderBackgrounds(dispcnt uint16) []int {
+	mode := int(dispcnt & dispcntModeMask)
+	if mode >= len(modeBackgrounds) {
+		return nil
+	}
+
+	var backgrounds []int
+	for bg, kind := range modeBackgrounds[mode] {
+		if kind == bgNone || dispcnt&(dispcntBG0<<uint(bg)) == 0 {
+			continue
+		}
+		switch kind {
+		case bgText:
+			renderer.renderTextBackground(bg)
+		case bgAffine:
+			renderer.renderAffineBackground(bg)
+		case bgBitmap:
+			renderer.renderBitmapBackground(bitmapModes[mode], dispcnt&dispcntFrameSelect != 0)
+		}
+		if renderer.bgControl(bg)&bgcntMosaic != 0 {
+			width, _ := renderer.mosaicSizes(false)
+			applyMosaic(&renderer.layers[bg], width)
+		}
+		backgrounds = append(backgrounds, bg)
+	}
+
+	// Lower priorities are drawn in front, and between equal ones the lower background
+	sort.SliceStable(backgrounds, func(i, j int) bool {
+		return renderer.bgPriority(backgrounds[i]) < renderer.bgPriority(backgrounds[j])
+	})
+	return backgrounds
+}
+
+func (renderer *renderer) bgControl(bg int) uint16 {
+	return renderer.io16(ioBG0CNT + uint32(bg)*2)
+}
+
+func (renderer *renderer) bgPriority(bg int) uint16 {
+	return renderer.bgControl(bg) & bgcntPriorityMask
+}
+
+// bgLine returns the line of a background to draw, which is the first line of the mosaic block with the mosaic
+func (renderer *renderer) bgLine(control uint16) int {
+	if control&bgcntMosaic == 0 {
+		return renderer.line
+	}
+	_, height := renderer.mosaicSizes(false)
+	return renderer.line - renderer.line%height
+}
+
+// affineReference returns the internal reference point of an affine background for the current line.
+// With the mosaic it moves back to the first line of the block.
+func (renderer *renderer) affineReference(affine int, control uint16) [2]int32 {
+	reference := renderer.state.references[affine]
+	if lines := int32(renderer.line - renderer.bgLine(control)); lines > 0 {
+		registers := uint32(affine) * ioAffineSize
+		reference[0] -= lines * int32(int16(renderer.io16(ioBG2PB+registers)))
+		reference[1] -= lines * int32(int16(renderer.io16(ioBG2PD+registers)))
+	}
+	return reference
+}
+
+// tileColor returns the color of a pixel of a tile, in tiles of 16 colors the palette bank selects the colors used
+func (renderer *renderer) tileColor(address uint32, x uint32, colors256 bool, paletteBank uint32) uint16 {
+	if colors256 {
+		address += x
+		if address >= bgVRAMSize || renderer.memory.vram[address] == 0 {
+			return transparent
+		}
+		return renderer.memory.paletteColor(uint32(renderer.memory.vram[address]))
+	}
+
+	address += x / 2
+	if address >= bgVRAMSize {
+		return transparent
+	}
+	index := uint32(renderer.memory.vram[address]>>(4*(x&1))) & 0xF
+	if index == 0 {
+		return transparent
+	}
+	return renderer.memory.paletteColor(paletteBank*16 + index)
+}
+
+// renderTextBackground draws a line of a scrolling background, made of 32x32 tiles screen blocks. Backgrounds
+// 512 pixels wide or tall put the screen blocks side by side, from left to right and then from top to bottom.
+func (renderer *renderer) renderTextBackground(bg int) {
+	control := renderer.bgControl(bg)
+	charBase := uint32(control>>2&0x3) * 0x4000
+	screenBase := uint32(control>>8&0x1F) * 0x800
+	colors256 := control&bgcnt256Colors != 0
+	width := uint32(256) << (control >> 14 & 0x1)
+	height := uint32(256) << (control >> 15 & 0x1)
+	scrollX := uint32(renderer.io16(ioBG0HOFS+uint32(bg)*4) & 0x1FF)
+	scrollY := uint32(renderer.io16(ioBG0VOFS+uint32(bg)*4) & 0x1FF)
+
+	tileSize := uint32(32)
+	if colors256 {
+		tileSize = 64
+	}
+	y := (uint32(renderer.bgLine(control)) + scrollY) & (height - 1)
+	output := &renderer.layers[bg]
+	for screenX := range output {
+		x := (uint32(screenX) + scrollX) & (width - 1)
+		block := x/256 + y/256*(width/256)
+		entry := renderer.memory.vram16(screenBase + block*0x800 + ((y%256)/8*32+(x%256)/8)*2)
+
+		tileX, tileY := x&7, y&7
+		if entry&(1<<10) != 0 {
+			tileX = 7 - tileX
+		}
+		if entry&(1<<11) != 0 {
+			tileY = 7 - tileY
+		}
+		address := charBase + uint32(entry&0x3FF)*tileSize + tileY*tileSize/8
+		output[screenX] = renderer.tileColor(address, tileX, colors256, uint32(entry>>12))
+	}
+}
+
+// renderAffineBackground draws a line of a rotated and scaled background, whose map is a square of 8 bits
+// tile numbers. The pixels are walked from the internal reference point along the first column of the matrix.
+func (renderer *renderer) renderAffineBackground(bg int) {
+	control := renderer.bgControl(bg)
+	charBase := uint32(control>>2&0x3) * 0x4000
+	screenBase := uint32(control>>8&0x1F) * 0x800
+	size := int32(128) << (control >> 14)
+	registers := uint32(bg-2) * ioAffineSize
+	pa := int32(int16(renderer.io16(ioBG2PA + registers)))
+	pc := int32(int16(renderer.io16(ioBG2PC + registers)))
+
+	reference := renderer.affineReference(bg-2, control)
+	output := &renderer.layers[bg]
+	for screenX := range output {
+		x := (reference[0] + pa*int32(screenX)) >> 8
+		y := (reference[1] + pc*int32(screenX)) >> 8
+		if control&bgcntWraparound != 0 {
+			x, y = x&(size-1), y&(size-1)
+		} else if x < 0 || y < 0 || x >= size || y >= size {
+			output[screenX] = transparent
+			continue
+		}
+
+		tile := uint32(renderer.memory.vram[screenBase+uint32(y/8*(size/8)+x/8)])
+		output[screenX] = renderer.tileColor(charBase+tile*64+uint32(y&7)*8, uint32(x&7), true, 0)
+	}
+}
+
+// renderBitmapBackground draws a line of BG2 in the bitmap modes, which goes through the affine transform
+// like in the tiled modes but never wraps around
+func (renderer *renderer) renderBitmapBackground(mode bitmapMode, secondFrame bool) {
+	pa := int32(int16(renderer.io16(ioBG2PA)))
+	pc := int32(int16(renderer.io16(ioBG2PC)))
+	base := uint32(0)
+	if mode.frames && secondFrame {
+		base = bitmapFrameOffset
+	}
+
+	reference := renderer.affineReference(0, renderer.bgControl(2))
+	output := &renderer.layers[2]
+	for screenX := range output {
+		x := (reference[0] + pa*int32(screenX)) >> 8
+		y := (reference[1] + pc*int32(screenX)) >> 8
+		if x < 0 || y < 0 || x >= mode.width || y >= mode.height {
+			output[screenX] = transparent
+			continue
+		}
+
+		pixel := uint32(y*mode.width + x)
+		if !mode.paletted {
+			output[screenX] = renderer.memory.vram16(base+pixel*2) & white
+		} else if index := renderer.memory.vram[base+pixel]; index != 0 {
+			output[screenX] = renderer.memory.paletteColor(uint32(index))
+		} else {
+			output[screenX] = transparent
+		}
+	}
+}
+
+// latchReference copies a reference point register of an affine background into its internal register,
+// which happens at the start of the vertical blank and whenever the register is written
+func (ppu *ppu) latchReference(affine int, coordinate int) {
+	value := ppu.memory.readIO32(ioBG2X + uint32(affine)*ioAffineSize + uint32(coordinate)*4)
+	// The registers hold signed 20.8 fixed point numbers in 28 bits
+	ppu.references[affine][coordinate] = int32(value<<4) >> 4
+}
+
+// latchReferences reloads both coordinates of every internal reference point
+func (ppu *ppu) latchReferences() {
+	for affine := range ppu.references {
+		ppu.latchReference(affine, 0)
+		ppu.latchReference(affine, 1)
+	}
+}
+
+// advanceReferences moves the internal reference points to the next line, along the second column of the matrix
+func (ppu *ppu) advanceReferences() {
+	for affine := range ppu.references {
+		registers := uint32(affine) * ioAffineSize
+		ppu.references[affine][0] += int32(int16(ppu.memory.readIO16(ioBG2PB + registers)))
+		ppu.references[affine][1] += int32(int16(ppu.memory.readIO16(ioBG2PD + registers)))
+	}
+}
+
+// referenceWritten relatches the internal reference point whose register was written
+func (ppu *ppu) referenceWritten(offset uint32) {
+	offset -= ioBG2X
+	ppu.latchReference(int(offset/ioAffineSize), int(offset%ioAffineSize/4))
+}
+
+// isReferenceRegister tells whether an I/O offset belongs to the reference point registers of BG2 or BG3
+func isReferenceRegister(offset uint32) bool {
+	return (offset >= ioBG2X && offset < ioBG2X+8) || (offset >= ioBG2X+ioAffineSize && offset < ioBG2X+ioAffineSize+8)
+}
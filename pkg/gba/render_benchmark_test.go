@@ -0,0 +1,45 @@
+package gba
+
+import (
+	"testing"
+)
+
+// busyScene fills the four backgrounds and the sprites with tiles of every color
+func busyScene() *Memory {
+	memory := newMemory(emptyCartridge())
+	for offset := uint32(0); offset < 0x18000; offset += 2 {
+		memory.Write16(0x06000000+offset, uint16(offset*0x9E37>>3))
+	}
+	for offset := uint32(0); offset < paletteSize; offset += 2 {
+		memory.Write16(0x05000000+offset, uint16(offset*0x1234))
+	}
+	for i := uint32(0); i < objCount; i++ {
+		memory.Write16(0x07000000+i*objEntrySize, uint16(i%160)|1<<14)
+		memory.Write16(0x07000000+i*objEntrySize+2, uint16(i*7%240)|2<<14)
+		memory.Write16(0x07000000+i*objEntrySize+4, uint16(i*8))
+	}
+	for bg := uint32(0); bg < 4; bg++ {
+		memory.Write16(0x04000008+bg*2, uint16(bg|bg<<2|(24+bg*2)<<8|1<<14))
+	}
+	memory.Write16(0x04000050, 1|effectAlpha<<6|0x3E<<8)
+	memory.Write16(0x04000052, 8|8<<8)
+	memory.Write16(0x04000000, 0xF<<8|dispcntOBJ|dispcntOBJ1D)
+	return memory
+}
+
+func benchmarkFrame(b *testing.B, workers int) {
+	ppu := newPPU(busyScene())
+	ppu.setWorkers(workers)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ppu.tick(cyclesPerFrame)
+	}
+}
+
+func BenchmarkFrameSerial(b *testing.B) {
+	benchmarkFrame(b, 1)
+}
+
+func BenchmarkFrameParallel(b *testing.B) {
+	benchmarkFrame(b, 0)
+}
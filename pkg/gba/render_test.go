@@ -0,0 +1,581 @@
+package gba
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RenderTestSuite struct {
+	suite.Suite
+	memory *Memory
+	ppu    *ppu
+}
+
+func (suite *RenderTestSuite) SetupTest() {
+	suite.memory = newMemory(emptyCartridge())
+	suite.ppu = newPPU(suite.memory)
+	suite.ppu.setWorkers(1)
+	suite.memory.Write16(0x05000000, 0x1111)
+}
+
+func TestRenderTestSuite(t *testing.T) {
+	suite.Run(t, new(RenderTestSuite))
+}
+
+// renderLines draws lines from the top of the frame, returning the frame being drawn
+func (suite *RenderTestSuite) renderLines(lines int) *Frame {
+	suite.ppu.line, suite.ppu.cycle = 0, 0
+	suite.ppu.rendered, suite.ppu.snapshotted = 0, 0
+	suite.ppu.latchReferences()
+	suite.ppu.tick(cyclesPerLine*(lines-1) + hblankStart)
+	return suite.ppu.frame
+}
+
+func (suite *RenderTestSuite) TestBackdropAndForcedBlank() {
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][0])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][ScreenWidth-1])
+
+	suite.memory.Write16(0x04000000, dispcntForcedBlank)
+	frame = suite.renderLines(2)
+	assert.Equal(suite.T(), uint16(white), frame.Pixels[1][0])
+}
+
+func (suite *RenderTestSuite) TestTextBackground16Colors() {
+	// Tile 1 has its first row colored 1 to 8
+	suite.memory.Write32(0x06000020, 0x87654321)
+	// Palette bank 2
+	suite.memory.Write16(0x05000000+(2*16+1)*2, 0x001F)
+	suite.memory.Write16(0x05000000+(2*16+8)*2, 0x03E0)
+	// Screen block 1 holds the map: the first tile and the one after it, flipped horizontally
+	suite.memory.Write16(0x06000800, 0x2001)
+	suite.memory.Write16(0x06000802, 0x2401)
+	suite.memory.Write16(0x04000008, 1<<8)
+	suite.memory.Write16(0x04000000, dispcntBG0)
+
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x001F), frame.Pixels[0][0])
+	assert.Equal(suite.T(), uint16(0x03E0), frame.Pixels[0][7])
+	assert.Equal(suite.T(), uint16(0x03E0), frame.Pixels[0][8])
+	assert.Equal(suite.T(), uint16(0x001F), frame.Pixels[0][15])
+	// Color 0 is transparent and the lines below are empty
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][16])
+	frame = suite.renderLines(2)
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[1][0])
+}
+
+func (suite *RenderTestSuite) TestTextBackgroundScrolling() {
+	// A 256 colors tile whose second row has color 5 on its last pixel
+	suite.memory.Write16(0x06004000+64+8+6, 0x0500)
+	suite.memory.Write16(0x05000000+5*2, 0x7C00)
+	// A 512x512 background, with the tile on the first entry of the last screen block
+	suite.memory.Write16(0x06000000+3*0x800, 1)
+	suite.memory.Write16(0x0400000A, 1<<2|bgcnt256Colors|3<<14)
+	suite.memory.Write16(0x04000014, 256)
+	suite.memory.Write16(0x04000016, 256+1)
+	suite.memory.Write16(0x04000000, 1|dispcntBG0<<1)
+
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x7C00), frame.Pixels[0][7])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][6])
+
+	// The background wraps around
+	suite.memory.Write16(0x04000014, 256+512)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x7C00), frame.Pixels[0][7])
+}
+
+func (suite *RenderTestSuite) TestBackgroundPriority() {
+	// Two solid tiles of different colors, tile 1 on BG0 and tile 2 on BG1
+	for offset := uint32(0); offset < 32; offset += 4 {
+		suite.memory.Write32(0x06000020+offset, 0x11111111)
+		suite.memory.Write32(0x06000040+offset, 0x22222222)
+	}
+	suite.memory.Write16(0x05000002, 0x0001)
+	suite.memory.Write16(0x05000004, 0x0002)
+	suite.memory.Write16(0x06000800, 1)
+	suite.memory.Write16(0x06001000, 2)
+	suite.memory.Write16(0x04000008, 1<<8|1)
+	suite.memory.Write16(0x0400000A, 2<<8|1)
+	suite.memory.Write16(0x04000000, dispcntBG0|dispcntBG0<<1)
+
+	// Between equal priorities the lower background is in front
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0001), frame.Pixels[0][0])
+
+	suite.memory.Write16(0x0400000A, 2<<8)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0002), frame.Pixels[0][0])
+}
+
+func (suite *RenderTestSuite) TestAffineBackground() {
+	// A 128x128 map whose tile 1 has color 3 on its pixel (2, 1)
+	suite.memory.Write8(0x06000040+8+2, 3)
+	suite.memory.Write16(0x05000006, 0x0123)
+	suite.memory.Write16(0x06000800, 0x0100)
+	suite.memory.Write16(0x0400000C, 1<<8)
+	suite.memory.Write16(0x04000000, 2|dispcntBG0<<2)
+	// Twice the size horizontally, and moving down a pixel per line
+	suite.memory.Write16(0x04000020, 0x80)
+	suite.memory.Write16(0x04000026, 0x100)
+	suite.memory.Write32(0x04000028, 0)
+
+	frame := suite.renderLines(2)
+	assert.Equal(suite.T(), uint16(0x0123), frame.Pixels[1][2*(8+2)])
+	assert.Equal(suite.T(), uint16(0x0123), frame.Pixels[1][2*(8+2)+1])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[1][2*(8+2)+2])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][2*(8+2)])
+}
+
+func (suite *RenderTestSuite) TestAffineWraparound() {
+	suite.memory.Write8(0x06000040, 1)
+	suite.memory.Write16(0x05000002, 0x0456)
+	suite.memory.Write16(0x06000800, 0x0100)
+	suite.memory.Write16(0x0400000E, 1<<8)
+	suite.memory.Write16(0x04000000, 2|dispcntBG0<<3)
+	suite.memory.Write16(0x04000030, 0x100)
+	// A negative reference point
+	suite.memory.Write32(0x04000038, 0x0FFFFF00)
+
+	// Past the edges of the map it's transparent, unless it wraps around
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0456), frame.Pixels[0][1+8])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][1+8+128])
+
+	suite.memory.Write16(0x0400000E, 1<<8|bgcntWraparound)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0456), frame.Pixels[0][1+8])
+	assert.Equal(suite.T(), uint16(0x0456), frame.Pixels[0][1+8+128])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][8])
+}
+
+// identityMatrix sets up BG2 to show its pixels unchanged
+func (suite *RenderTestSuite) identityMatrix() {
+	suite.memory.Write16(0x04000020, 0x100)
+	suite.memory.Write16(0x04000026, 0x100)
+}
+
+func (suite *RenderTestSuite) TestDirectColorBitmap() {
+	suite.identityMatrix()
+	suite.memory.Write16(0x06000000+(ScreenWidth+3)*2, 0xFFFF)
+	suite.memory.Write16(0x04000000, 3|dispcntBG0<<2)
+
+	frame := suite.renderLines(2)
+	assert.Equal(suite.T(), uint16(0x7FFF), frame.Pixels[1][3])
+	assert.Equal(suite.T(), uint16(0x0000), frame.Pixels[1][4])
+}
+
+func (suite *RenderTestSuite) TestPalettedBitmapPages() {
+	suite.identityMatrix()
+	suite.memory.Write16(0x05000000+7*2, 0x0077)
+	suite.memory.Write16(0x06000000, 0x0700)
+	suite.memory.Write16(0x06000000+bitmapFrameOffset, 0x0007)
+	suite.memory.Write16(0x04000000, 4|dispcntBG0<<2)
+
+	// Color 0 is transparent
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][0])
+	assert.Equal(suite.T(), uint16(0x0077), frame.Pixels[0][1])
+
+	suite.memory.Write16(0x04000000, 4|dispcntBG0<<2|dispcntFrameSelect)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0077), frame.Pixels[0][0])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][1])
+}
+
+func (suite *RenderTestSuite) TestSmallBitmapScaled() {
+	// Half the size, so the 160 pixels wide frame fills 80 pixels of the screen
+	suite.memory.Write16(0x04000020, 0x200)
+	suite.memory.Write16(0x04000026, 0x100)
+	suite.memory.Write16(0x06000000+bitmapFrameOffset+158*2, 0x1234)
+	suite.memory.Write16(0x04000000, 5|dispcntBG0<<2|dispcntFrameSelect)
+
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x1234), frame.Pixels[0][79])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][80])
+}
+
+func (suite *RenderTestSuite) TestReferencePointLatching() {
+	suite.memory.Write16(0x04000022, 0x10)
+	suite.memory.Write16(0x04000026, 0x100)
+	suite.memory.Write32(0x04000028, 0x1000)
+	assert.Equal(suite.T(), [2]int32{0x1000, 0}, suite.ppu.references[0])
+
+	// The internal registers move on every line, until the registers are latched again at the vertical blank
+	suite.renderLines(3)
+	assert.Equal(suite.T(), [2]int32{0x1030, 0x300}, suite.ppu.references[0])
+	suite.ppu.tick(cyclesPerLine*(visibleLines-2) - hblankStart)
+	assert.Equal(suite.T(), [2]int32{0x1000, 0}, suite.ppu.references[0])
+
+	// Writing a register only reloads its own coordinate
+	suite.ppu.references[0] = [2]int32{1, 2}
+	suite.memory.Write16(0x0400002E, 0x0800)
+	assert.Equal(suite.T(), [2]int32{1, -0x8000000}, suite.ppu.references[0])
+}
+
+func (suite *RenderTestSuite) TestCompletedFrame() {
+	suite.memory.Write16(0x05000000, 0x2222)
+	suite.ppu.tick(cyclesPerLine * visibleLines)
+	assert.Equal(suite.T(), uint16(0x2222), suite.ppu.completed.Pixels[ScreenHeight-1][0])
+}
+
+// setObject writes the attributes of a sprite, and hides every sprite after it
+func (suite *RenderTestSuite) setObject(index int, attributes ...uint16) {
+	for i, attribute := range attributes {
+		suite.memory.Write16(0x07000000+uint32(index*objEntrySize+i*2), attribute)
+	}
+	for i := index + 1; i < objCount; i++ {
+		suite.memory.Write16(0x07000000+uint32(i*objEntrySize), objDisabled<<8)
+	}
+}
+
+func (suite *RenderTestSuite) TestObject() {
+	// Tile 1 has a single pixel at (1, 0), of color 2 in the palette bank 3
+	suite.memory.Write8(0x06010020, 0x20)
+	suite.memory.Write16(0x05000200+(3*16+2)*2, 0x0042)
+	suite.setObject(0, 0, 100, 3<<12|1)
+	suite.memory.Write16(0x04000000, dispcntOBJ)
+
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0042), frame.Pixels[0][101])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][100])
+
+	// Flipped, and partly off the left of the screen
+	suite.setObject(0, 7, 0x1FC|1<<12|1<<13, 3<<12|1)
+	frame = suite.renderLines(15)
+	assert.Equal(suite.T(), uint16(0x0042), frame.Pixels[14][2])
+
+	// Y wraps around, showing the second row on the first line
+	suite.memory.Write8(0x06010024, 0x20)
+	suite.setObject(0, 255, 100, 3<<12|1)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0042), frame.Pixels[0][101])
+}
+
+func (suite *RenderTestSuite) TestObjectTileMapping() {
+	// A 16x16 sprite of 256 colors, whose bottom left tile is the 3rd one in 1D mapping and the 33rd in 2D
+	suite.memory.Write8(0x06010000+2*64, 1)
+	suite.memory.Write8(0x06010000+32*32, 2)
+	suite.memory.Write16(0x05000202, 0x0001)
+	suite.memory.Write16(0x05000204, 0x0002)
+	suite.setObject(0, 1<<13, 1<<14, 0)
+
+	suite.memory.Write16(0x04000000, dispcntOBJ|dispcntOBJ1D)
+	frame := suite.renderLines(9)
+	assert.Equal(suite.T(), uint16(0x0001), frame.Pixels[8][0])
+
+	suite.memory.Write16(0x04000000, dispcntOBJ)
+	frame = suite.renderLines(9)
+	assert.Equal(suite.T(), uint16(0x0002), frame.Pixels[8][0])
+
+	// In the bitmap modes the first half of the sprite tiles belongs to the frames
+	suite.memory.Write16(0x04000000, 3|dispcntOBJ)
+	frame = suite.renderLines(9)
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[8][0])
+}
+
+func (suite *RenderTestSuite) TestObjectLastTile() {
+	suite.memory.Write16(0x05000202, 0x0001)
+	suite.memory.Write16(0x05000204, 0x0002)
+	suite.memory.Write16(0x05000206, 0x0003)
+
+	// Of 16 colors, tile 0x3FF is the last one of the sprite VRAM
+	suite.memory.Write8(0x06017FE0, 0x20)
+	suite.setObject(0, 0, 0, 0x3FF)
+	suite.memory.Write16(0x04000000, dispcntOBJ|dispcntOBJ1D)
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0002), frame.Pixels[0][1])
+
+	// Of 256 colors in 1D mapping, its bottom half wraps to the start of the sprite VRAM
+	suite.memory.Write8(0x06010008, 1)
+	suite.setObject(0, 1<<13, 0, 0x3FF)
+	frame = suite.renderLines(6)
+	assert.Equal(suite.T(), uint16(0x0001), frame.Pixels[5][0])
+
+	// In 2D mapping bit 0 of the tile number is ignored
+	suite.memory.Write8(0x06017FC0, 3)
+	suite.memory.Write16(0x04000000, dispcntOBJ)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0003), frame.Pixels[0][0])
+}
+
+func (suite *RenderTestSuite) TestAffineObject() {
+	// An 8x8 sprite with a pixel on its top left corner, shown twice its size in a double size area
+	suite.memory.Write8(0x06010000, 1)
+	suite.memory.Write16(0x05000202, 0x0007)
+	suite.memory.Write16(0x07000006, 0x80)
+	suite.memory.Write16(0x0700001E, 0x80)
+	suite.setObject(0, objAffineDouble<<8, 50, 0)
+	suite.memory.Write16(0x04000000, dispcntOBJ)
+
+	frame := suite.renderLines(2)
+	assert.Equal(suite.T(), uint16(0x0007), frame.Pixels[0][50])
+	assert.Equal(suite.T(), uint16(0x0007), frame.Pixels[1][51])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[1][52])
+}
+
+func (suite *RenderTestSuite) TestObjectPriority() {
+	// A solid background with priority 1, and solid sprites of another color
+	suite.memory.Write16(0x05000002, 0x0001)
+	suite.memory.Write16(0x05000202, 0x0002)
+	suite.memory.Write16(0x05000204, 0x0003)
+	for offset := uint32(0); offset < 32; offset += 4 {
+		suite.memory.Write32(0x06000000+offset, 0x11111111)
+		suite.memory.Write32(0x06010000+offset, 0x11111111)
+		suite.memory.Write32(0x06010020+offset, 0x22222222)
+	}
+	suite.memory.Write16(0x04000008, 1<<8|1)
+	suite.memory.Write16(0x04000000, dispcntBG0|dispcntOBJ)
+
+	suite.setObject(0, 0, 0, 2<<10)
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0001), frame.Pixels[0][0])
+	suite.setObject(0, 0, 0, 1<<10)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0002), frame.Pixels[0][0])
+
+	// The first sprite in OAM is in front, unless the other one has a lower priority
+	suite.setObject(1, 0, 4, 1<<10|1)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0002), frame.Pixels[0][4])
+	suite.setObject(1, 0, 4, 1)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0003), frame.Pixels[0][4])
+	assert.Equal(suite.T(), uint16(0x0002), frame.Pixels[0][0])
+}
+
+func (suite *RenderTestSuite) TestObjectWindowAndSemiTransparency() {
+	suite.memory.Write8(0x06010000, 1)
+	suite.memory.Write16(0x05000202, 0x0002)
+	suite.setObject(0, objGraphicsWindow<<10, 0, 0)
+	suite.setObject(1, objGraphicsSemiTransparent<<10, 8, 0)
+	suite.memory.Write16(0x04000000, dispcntOBJ)
+
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][0])
+	assert.True(suite.T(), suite.ppu.renderers[0].objWindow[0])
+	assert.Equal(suite.T(), uint16(0x0002), frame.Pixels[0][8])
+	assert.True(suite.T(), suite.ppu.renderers[0].objects[8].semiTransparent)
+}
+
+func (suite *RenderTestSuite) TestObjectCycles() {
+	// Overlapping 64x64 sprites, each one 8 pixels further right
+	for offset := uint32(0); offset < 0x2000; offset += 4 {
+		suite.memory.Write32(0x06010000+offset, 0x11111111)
+	}
+	suite.memory.Write16(0x05000202, 0x0002)
+	for i := 0; i < 25; i++ {
+		suite.setObject(i, 0, uint16(i*8)|3<<14, 0)
+	}
+
+	// A line has time for 18 of them, and 14 when the OAM is free during the horizontal blank
+	suite.memory.Write16(0x04000000, dispcntOBJ)
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0002), frame.Pixels[0][17*8+63])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][18*8+63])
+
+	suite.memory.Write16(0x04000000, dispcntOBJ|dispcntHBlankFree)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0002), frame.Pixels[0][13*8+63])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][14*8+63])
+}
+
+// solidBackgrounds fills BG0 and BG1 with single colors, BG0 being in front
+func (suite *RenderTestSuite) solidBackgrounds() {
+	for offset := uint32(0); offset < 32; offset += 4 {
+		suite.memory.Write32(0x06000020+offset, 0x11111111)
+		suite.memory.Write32(0x06000040+offset, 0x22222222)
+	}
+	for offset := uint32(0); offset < 0x800; offset += 2 {
+		suite.memory.Write16(0x06000800+offset, 1)
+		suite.memory.Write16(0x06001000+offset, 2)
+	}
+	suite.memory.Write16(0x05000002, 0x001F)
+	suite.memory.Write16(0x05000004, 0x03E0)
+	suite.memory.Write16(0x04000008, 1<<8)
+	suite.memory.Write16(0x0400000A, 2<<8|1)
+	suite.memory.Write16(0x04000000, dispcntBG0|dispcntBG0<<1)
+}
+
+func (suite *RenderTestSuite) TestWindows() {
+	suite.solidBackgrounds()
+	// WIN0 covers 10 <= x < 20 on lines 1 and 2, showing BG1 only, and WIN1 wraps around the screen
+	suite.memory.Write16(0x04000040, 10<<8|20)
+	suite.memory.Write16(0x04000044, 1<<8|3)
+	suite.memory.Write16(0x04000042, 230<<8|5)
+	suite.memory.Write16(0x04000046, 0<<8|160)
+	suite.memory.Write16(0x04000048, 1<<1|1<<8)
+	suite.memory.Write16(0x0400004A, 0)
+	suite.memory.Write16(0x04000000, dispcntBG0|dispcntBG0<<1|dispcntWIN0|dispcntWIN1)
+
+	frame := suite.renderLines(2)
+	assert.Equal(suite.T(), uint16(0x03E0), frame.Pixels[1][10])
+	assert.Equal(suite.T(), uint16(0x03E0), frame.Pixels[1][19])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[1][20])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[0][10])
+	assert.Equal(suite.T(), uint16(0x001F), frame.Pixels[1][4])
+	assert.Equal(suite.T(), uint16(0x001F), frame.Pixels[1][235])
+	assert.Equal(suite.T(), uint16(0x1111), frame.Pixels[1][5])
+}
+
+func (suite *RenderTestSuite) TestObjectWindow() {
+	suite.solidBackgrounds()
+	suite.memory.Write8(0x06010000, 1)
+	suite.setObject(0, objGraphicsWindow<<10, 3, 0)
+	suite.memory.Write16(0x0400004A, 1|1<<9)
+	suite.memory.Write16(0x04000000, dispcntBG0|dispcntBG0<<1|dispcntOBJ|dispcntOBJWIN)
+
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x03E0), frame.Pixels[0][3])
+	assert.Equal(suite.T(), uint16(0x001F), frame.Pixels[0][4])
+}
+
+func (suite *RenderTestSuite) TestBlending() {
+	suite.solidBackgrounds()
+	suite.memory.Write16(0x04000052, 8|8<<8)
+	suite.memory.Write16(0x04000054, 8)
+
+	suite.memory.Write16(0x04000050, 1|effectAlpha<<6|1<<9)
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x01EF), frame.Pixels[0][0])
+
+	// The channels saturate
+	suite.memory.Write16(0x04000052, 16|16<<8)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x03FF), frame.Pixels[0][0])
+
+	// Only the layer right below is blended with
+	suite.memory.Write16(0x04000050, 1|effectAlpha<<6|1<<13)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x001F), frame.Pixels[0][0])
+
+	suite.memory.Write16(0x04000050, 1|effectBrighten<<6)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x3DFF), frame.Pixels[0][0])
+
+	suite.memory.Write16(0x04000050, 1|effectDarken<<6)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x0010), frame.Pixels[0][0])
+
+	// The windows can disable the effects
+	suite.memory.Write16(0x0400004A, 1)
+	suite.memory.Write16(0x04000000, dispcntBG0|dispcntBG0<<1|dispcntWIN0)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x001F), frame.Pixels[0][0])
+}
+
+func (suite *RenderTestSuite) TestSemiTransparentObject() {
+	suite.solidBackgrounds()
+	suite.memory.Write8(0x06010000, 1)
+	suite.memory.Write16(0x05000202, 0x7C00)
+	suite.setObject(0, objGraphicsSemiTransparent<<10, 0, 0)
+	suite.memory.Write16(0x04000052, 16|16<<8)
+	suite.memory.Write16(0x04000000, dispcntBG0|dispcntOBJ)
+
+	// Blended with the second target even without the sprites as first target nor the alpha effect
+	suite.memory.Write16(0x04000050, effectDarken<<6|1<<8)
+	frame := suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x7C1F), frame.Pixels[0][0])
+
+	suite.memory.Write16(0x04000050, effectNone<<6)
+	frame = suite.renderLines(1)
+	assert.Equal(suite.T(), uint16(0x7C00), frame.Pixels[0][0])
+}
+
+func (suite *RenderTestSuite) TestMosaic() {
+	// A 256 colors tile with a different color on every pixel
+	for i := uint32(0); i < 64; i++ {
+		suite.memory.Write8(0x06000040+i, byte(i+1))
+		suite.memory.Write8(0x06010000+i, byte(i+1))
+		suite.memory.Write16(0x05000000+(i+1)*2, uint16(i+1))
+		suite.memory.Write16(0x05000200+(i+1)*2, uint16(0x100+i+1))
+	}
+	suite.memory.Write16(0x06000800, 1)
+	suite.memory.Write16(0x04000008, 1<<8|bgcnt256Colors|bgcntMosaic)
+	suite.memory.Write16(0x0400004C, 3|2<<4)
+	suite.memory.Write16(0x04000000, dispcntBG0)
+
+	// Blocks of 4x3 pixels showing their top left pixel
+	frame := suite.renderLines(6)
+	assert.Equal(suite.T(), uint16(1), frame.Pixels[2][2])
+	assert.Equal(suite.T(), uint16(3*8+1), frame.Pixels[3][3])
+	assert.Equal(suite.T(), uint16(3*8+4+1), frame.Pixels[5][7])
+
+	// The same with a sprite
+	suite.memory.Write16(0x0400004C, 3<<8|2<<12)
+	suite.setObject(0, objMosaic|1<<13, 0, 0)
+	suite.memory.Write16(0x04000000, dispcntOBJ)
+	frame = suite.renderLines(6)
+	assert.Equal(suite.T(), uint16(0x100+1), frame.Pixels[2][2])
+	assert.Equal(suite.T(), uint16(0x100+3*8+1), frame.Pixels[3][3])
+	assert.Equal(suite.T(), uint16(0x100+3*8+4+1), frame.Pixels[5][7])
+}
+
+// scrollingFrame sets up a background with a pixel every 8 on every line, scrolled a pixel further on every line
+func (suite *RenderTestSuite) scrollingFrame() {
+	for row := uint32(0); row < 8; row++ {
+		suite.memory.Write8(0x06000020+row*4, 1)
+	}
+	suite.memory.Write16(0x05000002, 0x7FFF)
+	for offset := uint32(0); offset < 0x800; offset += 2 {
+		suite.memory.Write16(0x06000800+offset, 1)
+	}
+	suite.memory.Write16(0x04000008, 1<<8)
+	suite.memory.Write16(0x04000000, dispcntBG0)
+}
+
+// runFrame emulates a frame, scrolling the background during every horizontal blank
+func (suite *RenderTestSuite) runFrame() *Frame {
+	for line := 0; line < linesPerFrame; line++ {
+		suite.ppu.tick(hblankStart)
+		suite.memory.Write16(0x04000010, uint16(-((line + 1) % linesPerFrame)))
+		suite.ppu.tick(cyclesPerLine - hblankStart)
+	}
+	return suite.ppu.completed
+}
+
+func (suite *RenderTestSuite) TestParallelRendering() {
+	suite.scrollingFrame()
+	serial := *suite.runFrame()
+
+	suite.ppu.setWorkers(4)
+	assert.Equal(suite.T(), serial, *suite.runFrame())
+	assert.Equal(suite.T(), uint16(0x7FFF), serial.Pixels[3][3])
+	assert.Equal(suite.T(), uint16(0x1111), serial.Pixels[3][4])
+}
+
+func (suite *RenderTestSuite) TestRasterEffectsFallBackToSerial() {
+	suite.scrollingFrame()
+	suite.ppu.setWorkers(4)
+	suite.runFrame()
+
+	// The color changes in the middle of the frame
+	suite.ppu.tick(cyclesPerLine * 80)
+	assert.Equal(suite.T(), 0, suite.ppu.rendered)
+	suite.memory.Write16(0x05000002, 0x001F)
+	assert.Equal(suite.T(), 80, suite.ppu.rendered)
+	assert.True(suite.T(), suite.ppu.serial)
+	suite.ppu.tick(cyclesPerLine)
+	assert.Equal(suite.T(), 81, suite.ppu.rendered)
+
+	suite.ppu.tick(cyclesPerFrame - cyclesPerLine*81)
+	frame := suite.ppu.completed
+	assert.Equal(suite.T(), uint16(0x7FFF), frame.Pixels[0][0])
+	assert.Equal(suite.T(), uint16(0x7FFF), frame.Pixels[79][0])
+	assert.Equal(suite.T(), uint16(0x001F), frame.Pixels[80][0])
+	assert.False(suite.T(), suite.ppu.serial)
+}
+
+func (suite *RenderTestSuite) TestFrameImage() {
+	frame := new(Frame)
+	frame.Pixels[1][2] = 0x7C1F
+	assert.Equal(suite.T(), image.Rect(0, 0, 240, 160), frame.Bounds())
+	assert.Equal(suite.T(), BGR555(0x7C1F), frame.At(2, 1))
+	assert.Equal(suite.T(), BGR555(0), frame.At(-1, 1))
+	assert.Equal(suite.T(), color.RGBA{0xFF, 0x00, 0xFF, 0xFF}, frame.RGBA().At(2, 1))
+	assert.Equal(suite.T(), BGR555(0x7C1F), BGR555Model.Convert(color.RGBA{0xFF, 0x00, 0xFF, 0xFF}))
+}
@@ -0,0 +1,76 @@
+package gba
+
+import (
+	"encoding/binary"
+
+	"../arm7"
+)
+
+// replacementBIOS is a minimal BIOS for games that need one to run, like the ones that install their interrupt
+// handler at 0x03FFFFFC or jump into the exception vectors. It boots straight into the cartridge, dispatches
+// interrupts like the real BIOS does, and hands its calls over to the emulator through the trap instruction.
+var replacementBIOS = []uint32{
+	// Exception vectors
+	0xEA000006, // 0x00 b reset
+	0xEA000016, // 0x04 b undefined
+	0xEA00001D, // 0x08 b swi
+	0xE25EF004, // 0x0C subs pc, lr, #4
+	0xE25EF008, // 0x10 subs pc, lr, #8
+	0xEA000013, // 0x14 b reserved
+	0xEA000013, // 0x18 b irq
+	0xE25EF004, // 0x1C subs pc, lr, #4
+
+	// reset: set up the stacks of the IRQ, SVC and system modes, then start the cartridge in system mode
+	0xE3A000D2, // 0x20 mov r0, #0xD2
+	0xE121F000, // 0x24 msr cpsr_c, r0
+	0xE3A0D403, // 0x28 mov sp, #0x03000000
+	0xE28DDC7F, // 0x2C add sp, sp, #0x7F00
+	0xE28DD0A0, // 0x30 add sp, sp, #0xA0
+	0xE3A000D3, // 0x34 mov r0, #0xD3
+	0xE121F000, // 0x38 msr cpsr_c, r0
+	0xE3A0D403, // 0x3C mov sp, #0x03000000
+	0xE28DDC7F, // 0x40 add sp, sp, #0x7F00
+	0xE28DD0E0, // 0x44 add sp, sp, #0xE0
+	0xE3A0001F, // 0x48 mov r0, #0x1F
+	0xE121F000, // 0x4C msr cpsr_c, r0
+	0xE3A0D403, // 0x50 mov sp, #0x03000000
+	0xE28DDC7F, // 0x54 add sp, sp, #0x7F00
+	0xE3A00000, // 0x58 mov r0, #0
+	0xE3A0E302, // 0x5C mov lr, #0x08000000
+	0xE12FFF1E, // 0x60 bx lr
+
+	// undefined: skip the instruction
+	0xE1B0F00E, // 0x64 movs pc, lr
+
+	// reserved: never taken
+	0xEAFFFFFE, // 0x68 b reserved
+
+	// irq: call the handler of the game, whose address is kept at the end of IWRAM
+	0xE92D500F, // 0x6C stmfd sp!, {r0-r3, r12, lr}
+	0xE3A00301, // 0x70 mov r0, #0x04000000
+	0xE28FE000, // 0x74 add lr, pc, #0
+	0xE510F004, // 0x78 ldr pc, [r0, #-4]
+	0xE8BD500F, // 0x7C ldmfd sp!, {r0-r3, r12, lr}
+	0xE25EF004, // 0x80 subs pc, lr, #4
+
+	// swi: the emulator returns from the exception and runs the call
+	arm7.TrapInstruction, // 0x84
+}
+
+// replacementBIOSImage lays out the replacement BIOS as it's mapped into memory
+func replacementBIOSImage() []byte {
+	image := make([]byte, biosSize)
+	for i, instruction := range replacementBIOS {
+		binary.LittleEndian.PutUint32(image[i*4:], instruction)
+	}
+	return image
+}
+
+// serviceTrap runs the call the replacement BIOS hands over. The exception is returned from first, so the call
+// sees the registers of the game. The number is in the lowest byte of THUMB SWI instructions, and in the third
+// byte of ARM ones, which in both cases is 2 bytes before the return address.
+func (core *Core) serviceTrap() {
+	returnAddress := core.CPU.Register(14)
+	core.CPU.ReturnFromException()
+	core.serviceSWI(core.Memory.Read8(returnAddress - 2))
+}
@@ -0,0 +1,226 @@
+package gba
+
+import (
+	"bytes"
+	"log"
+	"time"
+)
+
+// Clock is the source of the date and time answered by the real time clock of a cartridge
+type Clock interface {
+	Now() time.Time
+}
+
+// HostClock answers the date and time of the computer running the emulator
+type HostClock struct{}
+
+// Now returns the current local time
+func (HostClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock always answers the same date and time, which keeps test runs deterministic
+type FixedClock struct {
+	Time time.Time
+}
+
+// Now returns the fixed time
+func (clock FixedClock) Now() time.Time {
+	return clock.Time
+}
+
+// OffsetClock answers the date and time of the computer shifted by an offset
+type OffsetClock struct {
+	Offset time.Duration
+}
+
+// Now returns the current local time plus the offset
+func (clock OffsetClock) Now() time.Time {
+	return time.Now().Add(clock.Offset)
+}
+
+// Pins of the GPIO port the RTC is wired to
+const (
+	rtcSCK = 1 << 0
+	rtcSIO = 1 << 1
+	rtcCS  = 1 << 2
+)
+
+// Constants for defining the commands of the RTC, bits 4-6 of the command byte
+const (
+	rtcReset    = 0
+	rtcDateTime = 2
+	rtcForceIRQ = 3
+	rtcStatus   = 4
+	rtcTime     = 6
+)
+
+// Bytes transferred after every command byte
+var rtcCommandLengths = [8]int{0, 0, 7, 0, 1, 0, 3, 0}
+
+// Flag of the status register selecting the 24 hours mode
+const rtcStatus24Hours = 0x40
+
+// Constants for defining the steps of the serial transfer start
+const (
+	rtcIdle int = iota
+	rtcSelecting
+	rtcTransferring
+)
+
+// rtc emulates the Seiko S-3511 real time clock, which talks through a serial interface over the GPIO pins.
+// Every transfer starts with a command byte, followed by the bytes read or written by the command,
+// all of them sent with the least significant bit first.
+type rtc struct {
+	clock Clock
+	step  int
+	// Bits of the byte being transferred
+	bits     byte
+	bitsRead uint
+	// Command being executed, with the number of bytes still to be transferred
+	command        byte
+	commandActive  bool
+	bytesRemaining int
+	status         byte
+	// Date and time in BCD: year, month, day, day of week, hour, minute and second
+	time [7]byte
+}
+
+func newRTC(clock Clock) *rtc {
+	if clock == nil {
+		clock = HostClock{}
+	}
+	return &rtc{clock: clock, status: rtcStatus24Hours}
+}
+
+func (rtc *rtc) isReading() bool {
+	return rtc.command&0x80 != 0
+}
+
+func (rtc *rtc) pinsWritten(gpio *gpio) {
+	pins := gpio.pins
+	switch rtc.step {
+	case rtcIdle:
+		if pins&(rtcCS|rtcSCK) == rtcSCK {
+			rtc.step = rtcSelecting
+		}
+	case rtcSelecting:
+		if pins&(rtcCS|rtcSCK) == rtcCS|rtcSCK {
+			rtc.step = rtcTransferring
+		} else if pins&(rtcCS|rtcSCK) != rtcSCK {
+			rtc.step = rtcIdle
+		}
+	case rtcTransferring:
+		if pins&rtcSCK == 0 {
+			// Data is latched while the clock is low
+			rtc.bits &^= 1 << rtc.bitsRead
+			rtc.bits |= ((pins & rtcSIO) >> 1) << rtc.bitsRead
+			return
+		}
+		if pins&rtcCS == 0 {
+			// Deselecting the chip aborts anything in progress
+			rtc.bits, rtc.bitsRead = 0, 0
+			rtc.command, rtc.commandActive, rtc.bytesRemaining = 0, false, 0
+			rtc.step = rtcIdle
+			if pins&rtcSCK != 0 {
+				rtc.step = rtcSelecting
+			}
+			gpio.output(rtcSCK)
+			return
+		}
+
+		if !rtc.commandActive || !rtc.isReading() {
+			rtc.bitsRead++
+			if rtc.bitsRead == 8 {
+				rtc.processByte()
+			}
+			return
+		}
+
+		gpio.output(rtcCS | rtcSCK | rtc.outputBit()<<1)
+		rtc.bitsRead++
+		if rtc.bitsRead == 8 {
+			rtc.bitsRead = 0
+			rtc.bytesRemaining--
+			if rtc.bytesRemaining <= 0 {
+				rtc.command, rtc.commandActive = 0, false
+			}
+		}
+	}
+}
+
+// processByte handles a byte written by the game, either a command or the data of a command
+func (rtc *rtc) processByte() {
+	if !rtc.commandActive {
+		command := rtc.bits
+		// Commands start with the fixed 0110 pattern
+		if command&0xF == 0x6 {
+			rtc.command = command
+			rtc.bytesRemaining = rtcCommandLengths[(command>>4)&0x7]
+			rtc.commandActive = rtc.bytesRemaining > 0
+			switch (command >> 4) & 0x7 {
+			case rtcReset:
+				rtc.status = 0
+			case rtcDateTime, rtcTime:
+				rtc.updateTime()
+			}
+		} else {
+			log.Printf("Invalid RTC command %02X\n", command)
+		}
+	} else {
+		rtc.bytesRemaining--
+		switch (rtc.command >> 4) & 0x7 {
+		case rtcStatus:
+			rtc.status = rtc.bits
+		}
+	}
+
+	rtc.bits, rtc.bitsRead = 0, 0
+	if rtc.bytesRemaining <= 0 {
+		rtc.command, rtc.commandActive = 0, false
+	}
+}
+
+// outputBit returns the next bit answered to the game
+func (rtc *rtc) outputBit() byte {
+	if rtc.bytesRemaining <= 0 {
+		return 0
+	}
+	var output byte
+	switch (rtc.command >> 4) & 0x7 {
+	case rtcStatus:
+		output = rtc.status
+	case rtcDateTime, rtcTime:
+		output = rtc.time[len(rtc.time)-rtc.bytesRemaining]
+	}
+	return (output >> rtc.bitsRead) & 0x1
+}
+
+func toBCD(value int) byte {
+	return byte((value/10)<<4 | value%10)
+}
+
+// updateTime latches the current date and time from the clock
+func (rtc *rtc) updateTime() {
+	now := rtc.clock.Now()
+	rtc.time[0] = toBCD(now.Year() % 100)
+	rtc.time[1] = toBCD(int(now.Month()))
+	rtc.time[2] = toBCD(now.Day())
+	rtc.time[3] = toBCD(int(now.Weekday()))
+	if rtc.status&rtcStatus24Hours != 0 {
+		rtc.time[4] = toBCD(now.Hour())
+	} else {
+		rtc.time[4] = toBCD(now.Hour() % 12)
+	}
+	// The PM flag is reported in both modes
+	if now.Hour() >= 12 {
+		rtc.time[4] |= 0x80
+	}
+	rtc.time[5] = toBCD(now.Minute())
+	rtc.time[6] = toBCD(now.Second())
+}
+
+// detectRTC looks for the signature of the library that drives the clock
+func detectRTC(romData []byte) bool {
+	return bytes.Contains(romData, []byte("SIIRTC_V"))
+}
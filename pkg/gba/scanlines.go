@@ -0,0 +1,109 @@
+package gba
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Size of the display registers copied for every line, from DISPCNT to BLDY
+const ioVideoSize = 0x56
+
+// Below this number of pending lines it's faster to draw them than to start the workers
+const minParallelLines = 8
+
+// lineState holds what the display registers were when a line was drawn
+type lineState struct {
+	io         [ioVideoSize]byte
+	references [2][2]int32
+}
+
+// renderer draws lines out of their register snapshots, using its own buffers so that renderers can
+// run side by side
+type renderer struct {
+	memory *Memory
+	state  *lineState
+	line   int
+	// Line being drawn of every background and of the sprites, with the pixels covered by the OBJ window
+	layers    [4][ScreenWidth]uint16
+	objects   [ScreenWidth]objPixel
+	objWindow [ScreenWidth]bool
+	// Layers shown on every pixel of the line, according to the windows
+	windowMask [ScreenWidth]uint16
+}
+
+func (renderer *renderer) io16(offset uint32) uint16 {
+	return uint16(renderer.state.io[offset]) | uint16(renderer.state.io[offset+1])<<8
+}
+
+// draw renders a line of a frame
+func (renderer *renderer) draw(frame *Frame, state *lineState, line int) {
+	renderer.state, renderer.line = state, line
+	renderer.renderLine(&frame.Pixels[line])
+}
+
+// setWorkers selects how many goroutines draw the lines of a frame, 1 draws every line when it ends
+func (ppu *ppu) setWorkers(workers int) {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	ppu.renderers = make([]*renderer, workers)
+	for i := range ppu.renderers {
+		ppu.renderers[i] = &renderer{memory: ppu.memory}
+	}
+}
+
+// snapshotLine records the registers used to draw the current line. Lines are drawn right away while
+// rendering serially, and otherwise all at once when the frame ends.
+func (ppu *ppu) snapshotLine() {
+	state := &ppu.snapshots[ppu.line]
+	copy(state.io[:], ppu.memory.io[:ioVideoSize])
+	state.references = ppu.references
+	ppu.snapshotted = ppu.line + 1
+	if ppu.serial || len(ppu.renderers) == 1 {
+		ppu.flush()
+	}
+}
+
+// flush draws the lines recorded but not drawn yet, sharing them between the workers
+func (ppu *ppu) flush() {
+	first, last := ppu.rendered, ppu.snapshotted
+	ppu.rendered = last
+	if last-first < minParallelLines || len(ppu.renderers) == 1 {
+		for line := first; line < last; line++ {
+			ppu.renderers[0].draw(ppu.frame, &ppu.snapshots[line], line)
+		}
+		return
+	}
+
+	var group sync.WaitGroup
+	for _, worker := range ppu.renderers {
+		group.Add(1)
+		go func(worker *renderer, start int) {
+			defer group.Done()
+			for line := start; line < last; line += len(ppu.renderers) {
+				worker.draw(ppu.frame, &ppu.snapshots[line], line)
+			}
+		}(worker, first)
+		first++
+	}
+	group.Wait()
+}
+
+// videoMemoryWritten is called before palette, VRAM or OAM change. Changes in the middle of a frame are
+// raster effects, so the lines before them are drawn with the previous contents and the rest of the
+// frame is drawn line by line.
+func (ppu *ppu) videoMemoryWritten() {
+	if ppu.line >= visibleLines || ppu.serial {
+		return
+	}
+	ppu.flush()
+	ppu.serial = true
+}
+
+// finishFrame draws the lines left when the vertical blank starts, and makes the frame the completed one
+func (ppu *ppu) finishFrame() {
+	ppu.flush()
+	ppu.frame, ppu.completed = ppu.completed, ppu.frame
+	ppu.rendered, ppu.snapshotted = 0, 0
+	ppu.serial = false
+}
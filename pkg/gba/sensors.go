@@ -0,0 +1,238 @@
+package gba
+
+import (
+	"math"
+	"sync"
+)
+
+// LightSource provides the amount of light hitting the solar sensor, from 0 (darkness) to 255 (direct sunlight)
+type LightSource interface {
+	LightLevel() byte
+}
+
+// RotationSource provides the readings of the tilt and gyro sensors, using the whole int32 range
+type RotationSource interface {
+	TiltX() int32
+	TiltY() int32
+	GyroZ() int32
+}
+
+// Rumble receives the state of the rumble motor
+type Rumble interface {
+	SetRumble(enabled bool)
+}
+
+// Sensors is a ready to use source for every cartridge sensor, meant to be driven by frontends and scripts.
+// It's safe to drive it from a different goroutine than the one running the emulation.
+type Sensors struct {
+	mutex      sync.Mutex
+	lightLevel byte
+	tiltX      int32
+	tiltY      int32
+	gyroZ      int32
+	rumbling   bool
+}
+
+func toSensorRange(value float64) int32 {
+	return int32(math.Max(-1, math.Min(1, value)) * math.MaxInt32)
+}
+
+// SetLightLevel changes the amount of light hitting the solar sensor, from 0 (darkness) to 255 (direct sunlight)
+func (sensors *Sensors) SetLightLevel(level byte) {
+	sensors.mutex.Lock()
+	defer sensors.mutex.Unlock()
+	sensors.lightLevel = level
+}
+
+// SetTilt changes the tilt of the console on both axes, from -1 (fully tilted left/up) to 1 (fully tilted right/down)
+func (sensors *Sensors) SetTilt(x float64, y float64) {
+	sensors.mutex.Lock()
+	defer sensors.mutex.Unlock()
+	sensors.tiltX, sensors.tiltY = toSensorRange(x), toSensorRange(y)
+}
+
+// SetRotationRate changes how fast the console rotates around the Z axis, from -1 to 1
+func (sensors *Sensors) SetRotationRate(rate float64) {
+	sensors.mutex.Lock()
+	defer sensors.mutex.Unlock()
+	sensors.gyroZ = toSensorRange(rate)
+}
+
+// Rumbling tells whether the game has the rumble motor running
+func (sensors *Sensors) Rumbling() bool {
+	sensors.mutex.Lock()
+	defer sensors.mutex.Unlock()
+	return sensors.rumbling
+}
+
+// LightLevel returns the amount of light hitting the solar sensor
+func (sensors *Sensors) LightLevel() byte {
+	sensors.mutex.Lock()
+	defer sensors.mutex.Unlock()
+	return sensors.lightLevel
+}
+
+// TiltX returns the tilt on the X axis
+func (sensors *Sensors) TiltX() int32 {
+	sensors.mutex.Lock()
+	defer sensors.mutex.Unlock()
+	return sensors.tiltX
+}
+
+// TiltY returns the tilt on the Y axis
+func (sensors *Sensors) TiltY() int32 {
+	sensors.mutex.Lock()
+	defer sensors.mutex.Unlock()
+	return sensors.tiltY
+}
+
+// GyroZ returns the rotation rate around the Z axis
+func (sensors *Sensors) GyroZ() int32 {
+	sensors.mutex.Lock()
+	defer sensors.mutex.Unlock()
+	return sensors.gyroZ
+}
+
+// SetRumble records the state of the rumble motor
+func (sensors *Sensors) SetRumble(enabled bool) {
+	sensors.mutex.Lock()
+	defer sensors.mutex.Unlock()
+	sensors.rumbling = enabled
+}
+
+// Pins of the GPIO port the solar sensor is wired to
+const (
+	solarClock  = 1 << 0
+	solarReset  = 1 << 1
+	solarSelect = 1 << 2
+	solarFlag   = 1 << 3
+)
+
+// solarSensor emulates the photodiode of the Boktai cartridges. The game resets a counter and keeps clocking it,
+// the sensor raises the flag pin once the counter reaches a value which gets lower the more light there is.
+type solarSensor struct {
+	source  LightSource
+	counter byte
+	sample  byte
+	edge    bool
+}
+
+func (sensor *solarSensor) pinsWritten(gpio *gpio) {
+	pins := gpio.pins
+	if pins&solarSelect != 0 {
+		return
+	}
+	if pins&solarReset != 0 {
+		sensor.counter = 0
+		sensor.sample = 0xFF
+		if sensor.source != nil {
+			sensor.sample = 0xFF - sensor.source.LightLevel()
+		}
+	}
+	if pins&solarClock != 0 && sensor.edge {
+		sensor.counter++
+	}
+	sensor.edge = pins&solarClock == 0
+
+	if sensor.counter >= sensor.sample {
+		gpio.output(solarFlag)
+	} else {
+		gpio.output(0)
+	}
+}
+
+// Pins of the GPIO port the gyro sensor and rumble motor are wired to
+const (
+	gyroSample = 1 << 0
+	gyroClock  = 1 << 1
+	gyroData   = 1 << 2
+	rumblePin  = 1 << 3
+)
+
+// gyroSensor emulates the gyroscope of WarioWare Twisted, that shifts a 16 bit sample out
+// one bit at a time on every falling edge of the clock pin.
+type gyroSensor struct {
+	source RotationSource
+	sample uint16
+	edge   bool
+}
+
+func (sensor *gyroSensor) pinsWritten(gpio *gpio) {
+	pins := gpio.pins
+	if pins&gyroSample != 0 && sensor.source != nil {
+		// Scale into 12 bits around the value the game sees at rest
+		sensor.sample = uint16((sensor.source.GyroZ() >> 21) + 0x6C0)
+	}
+	if sensor.edge && pins&gyroClock == 0 {
+		bit := byte(sensor.sample >> 15)
+		sensor.sample <<= 1
+		gpio.output(bit << 2)
+	}
+	sensor.edge = pins&gyroClock != 0
+}
+
+// rumbleMotor forwards the state of the rumble pin
+type rumbleMotor struct {
+	rumble Rumble
+}
+
+func (motor *rumbleMotor) pinsWritten(gpio *gpio) {
+	if motor.rumble != nil {
+		motor.rumble.SetRumble(gpio.pins&rumblePin != 0)
+	}
+}
+
+// Offsets of the tilt sensor registers inside the backup region
+const (
+	tiltStart1 = 0x8000
+	tiltStart2 = 0x8100
+	tiltXLow   = 0x8200
+	tiltXHigh  = 0x8300
+	tiltYLow   = 0x8400
+	tiltYHigh  = 0x8500
+)
+
+// tiltSensor emulates the accelerometer of Yoshi Topsy-Turvy and Koro Koro Puzzle, which lives in the backup region.
+// Writing 55h and AAh into its start registers samples both axes, that are read back as 12 bit values.
+type tiltSensor struct {
+	source  RotationSource
+	started bool
+	x       uint16
+	y       uint16
+}
+
+func isTiltRegister(address uint32) bool {
+	offset := address & 0xFFFF
+	return offset >= tiltStart1 && offset <= tiltYHigh
+}
+
+func (sensor *tiltSensor) read8(address uint32) byte {
+	switch address & 0xFFFF {
+	case tiltXLow:
+		return byte(sensor.x)
+	case tiltXHigh:
+		// Bit 7 reports that the sample is ready
+		return byte(sensor.x>>8)&0xF | 0x80
+	case tiltYLow:
+		return byte(sensor.y)
+	case tiltYHigh:
+		return byte(sensor.y>>8) & 0xF
+	}
+	return 0x0
+}
+
+func (sensor *tiltSensor) write8(address uint32, value byte) {
+	switch address & 0xFFFF {
+	case tiltStart1:
+		sensor.started = value == 0x55
+	case tiltStart2:
+		if value == 0xAA && sensor.started {
+			sensor.started = false
+			if sensor.source != nil {
+				// Scale around the value the game sees at rest, narrow enough to never go negative
+				sensor.x = uint16((sensor.source.TiltX() >> 22) + 0x3A0)
+				sensor.y = uint16((sensor.source.TiltY() >> 22) + 0x3A0)
+			}
+		}
+	}
+}
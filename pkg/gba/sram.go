@@ -0,0 +1,21 @@
+package gba
+
+const sramSize = 0x8000
+
+// sram is the battery backed 32KB static RAM, it's connected through an 8 bit data bus
+// and mirrored every 32KB over the whole 0x0E000000-0x0FFFFFFF range.
+type sram struct {
+	storage *backupStorage
+}
+
+func newSRAM(storage *backupStorage) *sram {
+	return &sram{storage: storage}
+}
+
+func (sram *sram) read8(address uint32) byte {
+	return sram.storage.get(int(address & (sramSize - 1)))
+}
+
+func (sram *sram) write8(address uint32, value byte) {
+	sram.storage.set(int(address&(sramSize-1)), value)
+}
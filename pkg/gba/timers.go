@@ -0,0 +1,119 @@
+package gba
+
+// Offsets of the timer registers, each timer has a counter and a control register
+const (
+	ioTM0CNTL      = 0x100
+	ioTimerSize    = 0x04
+	ioTimersEnd    = 0x110
+	timerOverflows = 0x10000
+)
+
+// Bits of TMxCNT_H
+const (
+	timerCountUp = 1 << 2
+	timerIRQ     = 1 << 6
+	timerEnabled = 1 << 7
+)
+
+// Shifts of the prescaler selected by bits 0-1 of TMxCNT_H: every 1, 64, 256 or 1024 cycles
+var timerPrescalerShifts = [4]uint{0, 6, 8, 10}
+
+// timer counts up from its reload value, overflowing into the next timer, the interrupts and the sound FIFOs
+type timer struct {
+	index   int
+	counter int
+	control uint16
+	// Cycles elapsed since the last increment
+	cycles int
+}
+
+func (timer *timer) enabled() bool {
+	return timer.control&timerEnabled != 0
+}
+
+// countUp tells whether the timer is incremented by the overflows of the previous one instead of the clock
+func (timer *timer) countUp() bool {
+	return timer.index > 0 && timer.control&timerCountUp != 0
+}
+
+// timerController runs the four timers
+type timerController struct {
+	memory *Memory
+	timers [4]timer
+}
+
+func newTimerController(memory *Memory) *timerController {
+	controller := &timerController{memory: memory}
+	for i := range controller.timers {
+		controller.timers[i].index = i
+	}
+	return controller
+}
+
+func (controller *timerController) registers(index int) uint32 {
+	return ioTM0CNTL + uint32(index)*ioTimerSize
+}
+
+func (controller *timerController) reload(timer *timer) int {
+	return int(controller.memory.readIO16(controller.registers(timer.index)))
+}
+
+// read8 reads the counter of a timer instead of the reload value written into its register
+func (controller *timerController) read8(offset uint32) byte {
+	timer := &controller.timers[(offset-ioTM0CNTL)/ioTimerSize]
+	if offset-controller.registers(timer.index) < 2 {
+		return byte(timer.counter >> (8 * (offset & 1)))
+	}
+	return controller.memory.io[offset]
+}
+
+// controlWritten reloads the counter when the enable bit goes from 0 to 1
+func (controller *timerController) controlWritten(index int) {
+	timer := &controller.timers[index]
+	control := controller.memory.readIO16(controller.registers(index) + 2)
+
+	wasEnabled := timer.enabled()
+	timer.control = control
+	if timer.enabled() && !wasEnabled {
+		timer.counter = controller.reload(timer)
+		timer.cycles = 0
+	}
+}
+
+// tick moves the timers counting cycles forward
+func (controller *timerController) tick(cycles int) {
+	for i := range controller.timers {
+		timer := &controller.timers[i]
+		if !timer.enabled() || timer.countUp() {
+			continue
+		}
+		shift := timerPrescalerShifts[timer.control&0x3]
+		timer.cycles += cycles
+		controller.increment(timer, timer.cycles>>shift)
+		timer.cycles &= 1<<shift - 1
+	}
+}
+
+func (controller *timerController) increment(timer *timer, ticks int) {
+	timer.counter += ticks
+	for timer.counter >= timerOverflows {
+		timer.counter -= timerOverflows - controller.reload(timer)
+		controller.overflow(timer)
+	}
+}
+
+// overflow requests the interrupt of a timer, counts up the next one and feeds the sound FIFOs
+func (controller *timerController) overflow(timer *timer) {
+	if timer.control&timerIRQ != 0 {
+		controller.memory.requestInterrupt(irqTimer0 + uint(timer.index))
+	}
+	if timer.index < len(controller.timers)-1 {
+		next := &controller.timers[timer.index+1]
+		if next.enabled() && next.countUp() {
+			controller.increment(next, 1)
+		}
+	}
+	if timer.index < 2 && controller.memory.apu != nil {
+		controller.memory.apu.timerOverflowed(timer.index)
+	}
+}
@@ -0,0 +1,49 @@
+package gba
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type TimersTestSuite struct {
+	suite.Suite
+	memory *Memory
+}
+
+func (suite *TimersTestSuite) SetupTest() {
+	suite.memory = newMemory(emptyCartridge())
+}
+
+func TestTimersTestSuite(t *testing.T) {
+	suite.Run(t, new(TimersTestSuite))
+}
+
+func (suite *TimersTestSuite) TestPrescaler() {
+	suite.memory.Write32(0x04000104, uint32(timerEnabled|1)<<16|0xFF00)
+	suite.memory.timers.tick(64*10 + 63)
+	assert.Equal(suite.T(), uint16(0xFF0A), suite.memory.Read16(0x04000104))
+	suite.memory.timers.tick(1)
+	assert.Equal(suite.T(), uint16(0xFF0B), suite.memory.Read16(0x04000104))
+
+	// Enabling it again while running doesn't reload
+	suite.memory.Write16(0x04000106, timerEnabled|1)
+	assert.Equal(suite.T(), uint16(0xFF0B), suite.memory.Read16(0x04000104))
+}
+
+func (suite *TimersTestSuite) TestOverflowCascades() {
+	suite.memory.Write32(0x04000100, uint32(timerEnabled|timerIRQ)<<16|0xFFFE)
+	suite.memory.Write32(0x04000104, uint32(timerEnabled|timerCountUp)<<16|0xFFF0)
+	suite.memory.timers.tick(5)
+
+	assert.Equal(suite.T(), uint16(0xFFFF), suite.memory.Read16(0x04000100))
+	assert.Equal(suite.T(), uint16(0xFFF2), suite.memory.Read16(0x04000104))
+	assert.Equal(suite.T(), uint16(1<<irqTimer0), suite.memory.readIO16(ioIF))
+}
+
+func (suite *TimersTestSuite) TestCountUpIgnoredOnTimer0() {
+	suite.memory.Write32(0x04000100, uint32(timerEnabled|timerCountUp)<<16)
+	suite.memory.timers.tick(100)
+	assert.Equal(suite.T(), uint16(100), suite.memory.Read16(0x04000100))
+}
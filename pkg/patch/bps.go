@@ -0,0 +1,175 @@
+package patch
+
+import (
+	"bytes"
+	"hash/crc32"
+)
+
+// Constants for defining the actions of a BPS patch, stored in the lowest 2 bits of every action
+const (
+	bpsSourceRead = iota
+	bpsTargetRead
+	bpsSourceCopy
+	bpsTargetCopy
+)
+
+// ApplyBPS applies a BPS patch, which builds the target file through a list of actions that copy bytes
+// from the source file, from the patch itself or from the already built part of the target file.
+func ApplyBPS(source []byte, patch []byte) ([]byte, error) {
+	footer, err := readFooter(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &reader{data: patch[:len(patch)-footerSize]}
+	if !bytes.Equal(reader.readBytes(4), formatMagics[BPS]) {
+		return nil, ErrUnknownFormat
+	}
+	var sizes [3]int
+	for i := range sizes {
+		if sizes[i], err = reader.readNumber(); err != nil {
+			return nil, err
+		}
+	}
+	sourceSize, targetSize, metadataSize := sizes[0], sizes[1], sizes[2]
+	reader.readBytes(metadataSize)
+	if reader.truncated {
+		return nil, ErrInvalidPatch
+	}
+
+	if len(source) != sourceSize || crc32.ChecksumIEEE(source) != footer.source {
+		return nil, ErrSourceChecksum
+	}
+	if !validTargetSize(targetSize) {
+		return nil, ErrTargetTooBig
+	}
+
+	target := make([]byte, 0, targetSize)
+	sourceOffset, targetOffset := 0, 0
+	for reader.offset < len(reader.data) {
+		action, err := reader.readNumber()
+		if err != nil {
+			return nil, err
+		}
+		length := (action >> 2) + 1
+		if len(target)+length > targetSize {
+			return nil, ErrInvalidPatch
+		}
+
+		switch action & 0x3 {
+		case bpsSourceRead:
+			if len(target)+length > len(source) {
+				return nil, ErrInvalidPatch
+			}
+			target = append(target, source[len(target):len(target)+length]...)
+		case bpsTargetRead:
+			target = append(target, reader.readBytes(length)...)
+		case bpsSourceCopy:
+			relative, err := readRelativeOffset(reader)
+			if err != nil {
+				return nil, err
+			}
+			sourceOffset += relative
+			if sourceOffset < 0 || sourceOffset+length > len(source) {
+				return nil, ErrInvalidPatch
+			}
+			target = append(target, source[sourceOffset:sourceOffset+length]...)
+			sourceOffset += length
+		case bpsTargetCopy:
+			relative, err := readRelativeOffset(reader)
+			if err != nil {
+				return nil, err
+			}
+			targetOffset += relative
+			if targetOffset < 0 || targetOffset >= len(target) {
+				return nil, ErrInvalidPatch
+			}
+			// The copy may overlap with the bytes it produces, so it's done one byte at a time
+			for i := 0; i < length; i++ {
+				target = append(target, target[targetOffset])
+				targetOffset++
+			}
+		}
+		if reader.truncated {
+			return nil, ErrInvalidPatch
+		}
+	}
+
+	if len(target) != targetSize || crc32.ChecksumIEEE(target) != footer.target {
+		return nil, ErrTargetChecksum
+	}
+	return target, nil
+}
+
+// readRelativeOffset decodes the signed offsets of the copy actions, whose lowest bit holds the sign
+func readRelativeOffset(reader *reader) (int, error) {
+	value, err := reader.readNumber()
+	if value&0x1 != 0 {
+		return -(value >> 1), err
+	}
+	return value >> 1, err
+}
+
+// CreateBPS makes a BPS patch which turns the source data into the target data. Unchanged bytes are read
+// from the source file, runs of a repeated byte are copied from the target file, and the rest is stored in the patch.
+func CreateBPS(source []byte, target []byte) []byte {
+	buffer := new(bytes.Buffer)
+	buffer.Write(formatMagics[BPS])
+	writeNumber(buffer, len(source))
+	writeNumber(buffer, len(target))
+	writeNumber(buffer, 0)
+
+	writeAction := func(action int, length int) {
+		writeNumber(buffer, (length-1)<<2|action)
+	}
+
+	targetOffset := 0
+	pending := 0
+	flushPending := func(offset int) {
+		if pending > 0 {
+			writeAction(bpsTargetRead, pending)
+			buffer.Write(target[offset-pending : offset])
+			pending = 0
+		}
+	}
+
+	for offset := 0; offset < len(target); {
+		unchanged := 0
+		for offset+unchanged < len(target) && offset+unchanged < len(source) && source[offset+unchanged] == target[offset+unchanged] {
+			unchanged++
+		}
+		if unchanged >= 4 || (unchanged > 0 && offset+unchanged == len(target)) {
+			flushPending(offset)
+			writeAction(bpsSourceRead, unchanged)
+			offset += unchanged
+			continue
+		}
+
+		repeated := 0
+		if offset > 0 {
+			for offset+repeated < len(target) && target[offset+repeated] == target[offset-1] {
+				repeated++
+			}
+		}
+		if repeated >= 4 {
+			flushPending(offset)
+			writeAction(bpsTargetCopy, repeated)
+			relative := offset - 1 - targetOffset
+			if relative < 0 {
+				writeNumber(buffer, -relative<<1|1)
+			} else {
+				writeNumber(buffer, relative<<1)
+			}
+			targetOffset = offset - 1 + repeated
+			offset += repeated
+			continue
+		}
+
+		pending++
+		offset++
+	}
+	flushPending(len(target))
+
+	writeFooter(buffer, source, target)
+	return buffer.Bytes()
+}
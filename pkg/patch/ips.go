@@ -0,0 +1,108 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+)
+
+// Limits of the IPS records, offsets are 24 bits and sizes 16 bits
+const (
+	ipsMaxOffset = 0xFFFFFF
+	ipsMaxSize   = 0xFFFF
+	// A record can't start at this offset, since it reads as the EOF marker
+	ipsEOFOffset = 0x454F46
+)
+
+var ipsEOF = []byte("EOF")
+
+// ErrIPSTooBig is returned when a file is too big to be described by an IPS patch
+var ErrIPSTooBig = errors.New("IPS patches can't address files bigger than 16MB")
+
+// ApplyIPS applies an IPS patch, made of a list of records that overwrite the bytes at an offset,
+// either with the bytes stored in the record or with a single repeated byte. The patch may end
+// with the size the output gets truncated to.
+func ApplyIPS(source []byte, patch []byte) ([]byte, error) {
+	reader := &reader{data: patch}
+	if !bytes.Equal(reader.readBytes(5), formatMagics[IPS]) {
+		return nil, ErrUnknownFormat
+	}
+
+	target := append([]byte(nil), source...)
+	write := func(offset int, data []byte) {
+		if end := offset + len(data); end > len(target) {
+			target = append(target, make([]byte, end-len(target))...)
+		}
+		copy(target[offset:], data)
+	}
+
+	for {
+		header := reader.readBytes(3)
+		if reader.truncated {
+			return nil, ErrInvalidPatch
+		}
+		if bytes.Equal(header, ipsEOF) {
+			break
+		}
+
+		offset := int(header[0])<<16 | int(header[1])<<8 | int(header[2])
+		size := int(reader.readByte())<<8 | int(reader.readByte())
+		if size != 0 {
+			write(offset, reader.readBytes(size))
+		} else {
+			// Run length encoded record
+			count := int(reader.readByte())<<8 | int(reader.readByte())
+			write(offset, bytes.Repeat([]byte{reader.readByte()}, count))
+		}
+		if reader.truncated {
+			return nil, ErrInvalidPatch
+		}
+	}
+
+	// Truncation extension
+	if len(patch)-reader.offset >= 3 {
+		size := reader.readBytes(3)
+		length := int(size[0])<<16 | int(size[1])<<8 | int(size[2])
+		if length < len(target) {
+			target = target[:length]
+		}
+	}
+	return target, nil
+}
+
+// CreateIPS makes an IPS patch with a record for every run of bytes that differ between both files
+func CreateIPS(source []byte, target []byte) ([]byte, error) {
+	if len(target) > ipsMaxOffset+1 {
+		return nil, ErrIPSTooBig
+	}
+
+	buffer := new(bytes.Buffer)
+	buffer.Write(formatMagics[IPS])
+
+	for offset := 0; offset < len(target); {
+		if offset < len(source) && source[offset] == target[offset] {
+			offset++
+			continue
+		}
+
+		start := offset
+		if start == ipsEOFOffset {
+			// Rewrite the previous byte as well, so the record doesn't look like the end of the patch
+			start--
+		}
+		end := offset
+		for end < len(target) && end-start < ipsMaxSize && (end >= len(source) || source[end] != target[end]) {
+			end++
+		}
+
+		buffer.Write([]byte{byte(start >> 16), byte(start >> 8), byte(start)})
+		buffer.Write([]byte{byte((end - start) >> 8), byte(end - start)})
+		buffer.Write(target[start:end])
+		offset = end
+	}
+
+	buffer.Write(ipsEOF)
+	if len(target) < len(source) {
+		buffer.Write([]byte{byte(len(target) >> 16), byte(len(target) >> 8), byte(len(target))})
+	}
+	return buffer.Bytes(), nil
+}
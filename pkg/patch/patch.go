@@ -0,0 +1,220 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"path/filepath"
+	"strings"
+)
+
+// Format identifies the format of a patch file
+type Format int
+
+// Constants for defining the supported patch formats
+const (
+	IPS Format = iota
+	UPS
+	BPS
+)
+
+// Formats lists every supported format, in the order they're looked for
+var Formats = []Format{IPS, UPS, BPS}
+
+var formatNames = map[Format]string{IPS: "ips", UPS: "ups", BPS: "bps"}
+
+var formatMagics = map[Format][]byte{IPS: []byte("PATCH"), UPS: []byte("UPS1"), BPS: []byte("BPS1")}
+
+// MaxTargetSize is the size of the biggest file a patch may produce, the one of the biggest GBA ROMs. The
+// sizes come from the patches, so they're checked before allocating anything.
+const MaxTargetSize = 32 << 20
+
+// Errors returned when a patch can't be applied
+var (
+	ErrUnknownFormat  = errors.New("unknown patch format")
+	ErrInvalidPatch   = errors.New("invalid or truncated patch")
+	ErrSourceChecksum = errors.New("the patch was made for a different file")
+	ErrTargetChecksum = errors.New("the patched file doesn't match the expected checksum")
+	ErrPatchChecksum  = errors.New("the patch file is corrupted")
+	ErrTargetTooBig   = errors.New("the patched file would be bigger than 32MB")
+)
+
+func (format Format) String() string {
+	return formatNames[format]
+}
+
+// Extension returns the file extension used by the format, including the dot
+func (format Format) Extension() string {
+	return "." + format.String()
+}
+
+// ParseFormat returns the format with the given name or file extension
+func ParseFormat(name string) (Format, error) {
+	name = strings.TrimPrefix(strings.ToLower(name), ".")
+	for format, formatName := range formatNames {
+		if formatName == name {
+			return format, nil
+		}
+	}
+	return 0, fmt.Errorf("%v: %s", ErrUnknownFormat, name)
+}
+
+// FormatFromPath returns the format matching the extension of a file
+func FormatFromPath(path string) (Format, error) {
+	return ParseFormat(filepath.Ext(path))
+}
+
+// DetectFormat identifies the format of a patch through its magic number
+func DetectFormat(patch []byte) (Format, error) {
+	for _, format := range Formats {
+		if bytes.HasPrefix(patch, formatMagics[format]) {
+			return format, nil
+		}
+	}
+	return 0, ErrUnknownFormat
+}
+
+// Apply patches the source data, the format is detected from the patch contents
+func Apply(source []byte, patch []byte) ([]byte, error) {
+	format, err := DetectFormat(patch)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case UPS:
+		return ApplyUPS(source, patch)
+	case BPS:
+		return ApplyBPS(source, patch)
+	}
+	return ApplyIPS(source, patch)
+}
+
+// Create makes a patch in the given format which turns the source data into the target data
+func Create(format Format, source []byte, target []byte) ([]byte, error) {
+	switch format {
+	case IPS:
+		return CreateIPS(source, target)
+	case UPS:
+		return CreateUPS(source, target), nil
+	case BPS:
+		return CreateBPS(source, target), nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// reader walks through the contents of a patch, remembering whether it went past its end
+type reader struct {
+	data      []byte
+	offset    int
+	truncated bool
+}
+
+func (reader *reader) readByte() byte {
+	if reader.offset >= len(reader.data) {
+		reader.truncated = true
+		return 0
+	}
+	value := reader.data[reader.offset]
+	reader.offset++
+	return value
+}
+
+func (reader *reader) readBytes(length int) []byte {
+	if length < 0 || reader.offset+length > len(reader.data) {
+		reader.truncated = true
+		reader.offset = len(reader.data)
+		return nil
+	}
+	value := reader.data[reader.offset : reader.offset+length]
+	reader.offset += length
+	return value
+}
+
+// Biggest number read from a patch: sizes and offsets fit in MaxTargetSize, but BPS actions and relative
+// offsets store them with two or one more bits
+const maxNumber = MaxTargetSize << 2
+
+// readNumber decodes the variable length numbers of UPS and BPS patches, 7 bits at a time with the least
+// significant group first. The top bit flags the last group, and every following group is offset by one
+// so each number has a single encoding. Numbers past maxNumber are rejected before they can overflow.
+func (reader *reader) readNumber() (int, error) {
+	number, shift := 0, 1
+	for {
+		value := reader.readByte()
+		if reader.truncated {
+			return 0, ErrInvalidPatch
+		}
+		number += int(value&0x7F) * shift
+		if number > maxNumber {
+			return 0, ErrInvalidPatch
+		}
+		if value&0x80 != 0 {
+			return number, nil
+		}
+		shift <<= 7
+		number += shift
+	}
+}
+
+// validTargetSize tells whether a size read from a patch fits in MaxTargetSize
+func validTargetSize(size int) bool {
+	return size <= MaxTargetSize
+}
+
+func writeNumber(buffer *bytes.Buffer, number int) {
+	for {
+		value := byte(number & 0x7F)
+		number >>= 7
+		if number == 0 {
+			buffer.WriteByte(0x80 | value)
+			return
+		}
+		buffer.WriteByte(value)
+		number--
+	}
+}
+
+// footer holds the checksums that end every UPS and BPS patch
+type footer struct {
+	source uint32
+	target uint32
+	patch  uint32
+}
+
+const footerSize = 12
+
+func readFooter(patch []byte) (footer, error) {
+	if len(patch) < footerSize {
+		return footer{}, ErrInvalidPatch
+	}
+	checksums := patch[len(patch)-footerSize:]
+	result := footer{
+		source: binary.LittleEndian.Uint32(checksums[0:]),
+		target: binary.LittleEndian.Uint32(checksums[4:]),
+		patch:  binary.LittleEndian.Uint32(checksums[8:]),
+	}
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != result.patch {
+		return footer{}, ErrPatchChecksum
+	}
+	return result, nil
+}
+
+func writeFooter(buffer *bytes.Buffer, source []byte, target []byte) {
+	checksum := make([]byte, 4)
+	binary.LittleEndian.PutUint32(checksum, crc32.ChecksumIEEE(source))
+	buffer.Write(checksum)
+	binary.LittleEndian.PutUint32(checksum, crc32.ChecksumIEEE(target))
+	buffer.Write(checksum)
+	binary.LittleEndian.PutUint32(checksum, crc32.ChecksumIEEE(buffer.Bytes()))
+	buffer.Write(checksum)
+}
+
+// byteAt returns a byte of the data, reading zero past its end
+func byteAt(data []byte, offset int) byte {
+	if offset < len(data) {
+		return data[offset]
+	}
+	return 0
+}
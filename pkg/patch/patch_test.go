@@ -0,0 +1,171 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type PatchTestSuite struct {
+	suite.Suite
+	source []byte
+	target []byte
+}
+
+func (suite *PatchTestSuite) SetupTest() {
+	random := rand.New(rand.NewSource(1))
+	suite.source = make([]byte, 0x4000)
+	random.Read(suite.source)
+
+	suite.target = append([]byte(nil), suite.source...)
+	for i := 0x100; i < 0x180; i++ {
+		suite.target[i] ^= 0x5A
+	}
+	for i := 0x2000; i < 0x2100; i++ {
+		suite.target[i] = 0x00
+	}
+	suite.target[0x3FFF]++
+	suite.target = append(suite.target, []byte("translated")...)
+}
+
+func TestPatchTestSuite(t *testing.T) {
+	suite.Run(t, new(PatchTestSuite))
+}
+
+func (suite *PatchTestSuite) TestRoundTrip() {
+	for _, format := range Formats {
+		patch, err := Create(format, suite.source, suite.target)
+		assert.Nil(suite.T(), err)
+
+		detected, err := DetectFormat(patch)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), format, detected)
+
+		patched, err := Apply(suite.source, patch)
+		assert.Nil(suite.T(), err, format.String())
+		assert.Equal(suite.T(), suite.target, patched, format.String())
+	}
+}
+
+func (suite *PatchTestSuite) TestRoundTripWhenShrinking() {
+	for _, format := range Formats {
+		patch, _ := Create(format, suite.target, suite.source)
+		patched, err := Apply(suite.target, patch)
+		assert.Nil(suite.T(), err, format.String())
+		assert.Equal(suite.T(), suite.source, patched, format.String())
+	}
+}
+
+func (suite *PatchTestSuite) TestApplyIPS() {
+	patch := []byte("PATCH\x00\x00\x01\x00\x02\xAA\xBB\x00\x00\x05\x00\x00\x00\x03\xCCEOF")
+	patched, err := ApplyIPS([]byte{0, 1, 2, 3}, patch)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []byte{0, 0xAA, 0xBB, 3, 0, 0xCC, 0xCC, 0xCC}, patched)
+}
+
+func (suite *PatchTestSuite) TestUPSRevertsPatchedFiles() {
+	patch := CreateUPS(suite.source, suite.target)
+	reverted, err := ApplyUPS(suite.target, patch)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), suite.source, reverted)
+}
+
+func (suite *PatchTestSuite) TestChecksumsAreValidated() {
+	for _, format := range []Format{UPS, BPS} {
+		patch, _ := Create(format, suite.source, suite.target)
+
+		wrongSource := append([]byte(nil), suite.source...)
+		wrongSource[0]++
+		_, err := Apply(wrongSource, patch)
+		assert.Equal(suite.T(), ErrSourceChecksum, err, format.String())
+
+		patch[len(patch)-20]++
+		_, err = Apply(suite.source, patch)
+		assert.Equal(suite.T(), ErrPatchChecksum, err, format.String())
+	}
+}
+
+func (suite *PatchTestSuite) TestTargetSizeIsLimited() {
+	for _, format := range []Format{UPS, BPS} {
+		for _, size := range []int{MaxTargetSize + 1, 1 << 40} {
+			buffer := new(bytes.Buffer)
+			buffer.Write(formatMagics[format])
+			writeNumber(buffer, len(suite.source))
+			writeNumber(buffer, size)
+			if format == BPS {
+				writeNumber(buffer, 0)
+			}
+			writeFooter(buffer, suite.source, suite.target)
+
+			_, err := Apply(suite.source, buffer.Bytes())
+			if size > maxNumber {
+				assert.Equal(suite.T(), ErrInvalidPatch, err, format.String())
+			} else {
+				assert.Equal(suite.T(), ErrTargetTooBig, err, format.String())
+			}
+		}
+	}
+}
+
+func (suite *PatchTestSuite) TestVariableLengthNumbers() {
+	for _, number := range []int{0, 1, 0x7F, 0x80, 0x407F, 0x4080, 0x1234567} {
+		buffer := new(bytes.Buffer)
+		writeNumber(buffer, number)
+		reader := &reader{data: buffer.Bytes()}
+		read, err := reader.readNumber()
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), number, read)
+	}
+
+	// Numbers that would overflow, and truncated ones
+	for _, data := range [][]byte{bytes.Repeat([]byte{0x7F}, 10), {0x7F, 0x7F, 0x7F, 0x7F, 0x80}, {0x00}} {
+		reader := &reader{data: data}
+		_, err := reader.readNumber()
+		assert.Equal(suite.T(), ErrInvalidPatch, err)
+	}
+}
+
+// TestOverflowingNumbers applies patches with valid checksums whose numbers used to overflow into negative
+// offsets and lengths
+func (suite *PatchTestSuite) TestOverflowingNumbers() {
+	for _, test := range []struct {
+		format  Format
+		records string
+	}{
+		{UPS, "7f7f7f7f7f7f7f7f7f910100"},
+		{UPS, "647f5915497517281148fa"},
+		{BPS, "647f5915497517281148fa"},
+		{BPS, "7f7f7f7f7f7f7f7f7f91"},
+		{BPS, "8a7f7f7f7f7f7f7f7f7f91"},
+		{BPS, "8b7f7f7f7f7f7f7f7f7f91"},
+	} {
+		records, _ := hex.DecodeString(test.records)
+		buffer := new(bytes.Buffer)
+		buffer.Write(formatMagics[test.format])
+		writeNumber(buffer, len(suite.source))
+		writeNumber(buffer, len(suite.source))
+		if test.format == BPS {
+			writeNumber(buffer, 0)
+		}
+		buffer.Write(records)
+		writeFooter(buffer, suite.source, suite.source)
+
+		_, err := Apply(suite.source, buffer.Bytes())
+		assert.Equal(suite.T(), ErrInvalidPatch, err, test.records)
+	}
+}
+
+func (suite *PatchTestSuite) TestParseFormat() {
+	format, err := FormatFromPath("game.BPS")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), BPS, format)
+
+	_, err = ParseFormat("xdelta")
+	assert.NotNil(suite.T(), err)
+}
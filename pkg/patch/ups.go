@@ -0,0 +1,111 @@
+package patch
+
+import (
+	"bytes"
+	"hash/crc32"
+)
+
+// ApplyUPS applies an UPS patch, which stores the XOR between both files for every run of bytes that differ.
+// Since XOR works both ways, the patch also turns a patched file back into the original one.
+func ApplyUPS(source []byte, patch []byte) ([]byte, error) {
+	footer, err := readFooter(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &reader{data: patch[:len(patch)-footerSize]}
+	if !bytes.Equal(reader.readBytes(4), formatMagics[UPS]) {
+		return nil, ErrUnknownFormat
+	}
+	sourceSize, err := reader.readNumber()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := reader.readNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	sourceChecksum := crc32.ChecksumIEEE(source)
+	switch {
+	case len(source) == sourceSize && sourceChecksum == footer.source:
+	case len(source) == targetSize && sourceChecksum == footer.target:
+		// Reverting a patched file
+		sourceSize, targetSize = targetSize, sourceSize
+		footer.source, footer.target = footer.target, footer.source
+	default:
+		return nil, ErrSourceChecksum
+	}
+	if !validTargetSize(targetSize) {
+		return nil, ErrTargetTooBig
+	}
+
+	// The records of a shrunk file run up to the end of the bigger one, but never past it
+	size := targetSize
+	if sourceSize > size {
+		size = sourceSize
+	}
+	target := make([]byte, targetSize)
+	copy(target, source)
+	for offset := 0; reader.offset < len(reader.data); {
+		skipped, err := reader.readNumber()
+		if err != nil {
+			return nil, err
+		}
+		if offset += skipped; offset > size {
+			return nil, ErrInvalidPatch
+		}
+		for {
+			value := reader.readByte()
+			if reader.truncated {
+				return nil, ErrInvalidPatch
+			}
+			if offset < targetSize {
+				target[offset] = byteAt(source, offset) ^ value
+			}
+			offset++
+			if value == 0 {
+				break
+			}
+		}
+	}
+
+	if crc32.ChecksumIEEE(target) != footer.target {
+		return nil, ErrTargetChecksum
+	}
+	return target, nil
+}
+
+// CreateUPS makes an UPS patch which turns the source data into the target data
+func CreateUPS(source []byte, target []byte) []byte {
+	buffer := new(bytes.Buffer)
+	buffer.Write(formatMagics[UPS])
+	writeNumber(buffer, len(source))
+	writeNumber(buffer, len(target))
+
+	size := len(target)
+	if len(source) > size {
+		size = len(source)
+	}
+
+	last := 0
+	for offset := 0; offset < size; {
+		if byteAt(source, offset) == byteAt(target, offset) {
+			offset++
+			continue
+		}
+
+		writeNumber(buffer, offset-last)
+		for offset < size && byteAt(source, offset) != byteAt(target, offset) {
+			buffer.WriteByte(byteAt(source, offset) ^ byteAt(target, offset))
+			offset++
+		}
+		// The run ends with a zero, which stands for a byte that didn't change
+		buffer.WriteByte(0)
+		offset++
+		last = offset
+	}
+
+	writeFooter(buffer, source, target)
+	return buffer.Bytes()
+}
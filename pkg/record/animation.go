@@ -0,0 +1,191 @@
+package record
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+)
+
+// GIF delays count hundredths of a second, and viewers slow down the frames shorter than gifMinDelay to a
+// tenth of a second. Keeping one frame out of gifFrameStep plays the clips at about 30 frames per second.
+const (
+	gifMinDelay  = 2
+	gifFrameStep = 2
+)
+
+// gifRecorder keeps the frames of an animated GIF until the clip ends, the time of the dropped frames
+// being added to the one kept before them
+type gifRecorder struct {
+	frameSize
+	output    io.Writer
+	animation gif.GIF
+	delays    frameDelays
+	frames    int
+}
+
+func (recorder *gifRecorder) WriteFrame(frame *image.RGBA) error {
+	if err := recorder.check(frame); err != nil {
+		return err
+	}
+	recorder.delays.unitsPerSecond = 100
+	delay := recorder.delays.next()
+	recorder.frames++
+	if (recorder.frames-1)%gifFrameStep != 0 {
+		recorder.animation.Delay[len(recorder.animation.Delay)-1] += delay
+		return nil
+	}
+	recorder.animation.Image = append(recorder.animation.Image, paletted(frame))
+	recorder.animation.Delay = append(recorder.animation.Delay, delay)
+	return nil
+}
+
+func (recorder *gifRecorder) Close() error {
+	last := len(recorder.animation.Image) - 1
+	if last < 0 {
+		return nil
+	}
+	if recorder.animation.Delay[last] < gifMinDelay {
+		recorder.animation.Delay[last] = gifMinDelay
+	}
+	return gif.EncodeAll(recorder.output, &recorder.animation)
+}
+
+// paletted converts a frame into 256 colors, exactly when it doesn't use more and dithered otherwise
+func paletted(frame *image.RGBA) *image.Paletted {
+	bounds := image.Rect(0, 0, frame.Rect.Dx(), frame.Rect.Dy())
+	var colors color.Palette
+	indexes := map[color.RGBA]uint8{}
+pixels:
+	for y := frame.Rect.Min.Y; y < frame.Rect.Max.Y; y++ {
+		for x := frame.Rect.Min.X; x < frame.Rect.Max.X; x++ {
+			pixel := frame.RGBAAt(x, y)
+			if _, found := indexes[pixel]; !found {
+				if len(indexes) == 256 {
+					colors = nil
+					break pixels
+				}
+				indexes[pixel] = uint8(len(indexes))
+				colors = append(colors, pixel)
+			}
+		}
+	}
+
+	if colors == nil {
+		output := image.NewPaletted(bounds, palette.Plan9)
+		draw.FloydSteinberg.Draw(output, bounds, frame, frame.Rect.Min)
+		return output
+	}
+	output := image.NewPaletted(bounds, colors)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			output.Pix[y*output.Stride+x] = indexes[frame.RGBAAt(frame.Rect.Min.X+x, frame.Rect.Min.Y+y)]
+		}
+	}
+	return output
+}
+
+// apngRecorder keeps the compressed frames of an animated PNG until the clip ends, since the number of
+// frames comes before them
+type apngRecorder struct {
+	frameSize
+	output io.Writer
+	frames [][]byte
+	delays []int
+	timing frameDelays
+}
+
+// Delays of the frames are fractions of a second whose denominator fits in 16 bits
+const apngDelayDenominator = 10000
+
+func (recorder *apngRecorder) WriteFrame(frame *image.RGBA) error {
+	if err := recorder.check(frame); err != nil {
+		return err
+	}
+	// Every row starts with the filter type, 0 for none
+	var data bytes.Buffer
+	writer := zlib.NewWriter(&data)
+	for y := frame.Rect.Min.Y; y < frame.Rect.Max.Y; y++ {
+		writer.Write([]byte{0})
+		offset := frame.PixOffset(frame.Rect.Min.X, y)
+		writer.Write(frame.Pix[offset : offset+frame.Rect.Dx()*4])
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	recorder.timing.unitsPerSecond = apngDelayDenominator
+	recorder.frames = append(recorder.frames, data.Bytes())
+	recorder.delays = append(recorder.delays, recorder.timing.next())
+	return nil
+}
+
+func (recorder *apngRecorder) Close() error {
+	if len(recorder.frames) == 0 {
+		return nil
+	}
+	png := &pngWriter{output: recorder.output}
+	png.write([]byte("\x89PNG\r\n\x1a\n"))
+
+	width, height := uint32(recorder.size.X), uint32(recorder.size.Y)
+	// 8 bits RGBA, compressed with deflate, not interlaced
+	png.chunk("IHDR", be32(width), be32(height), []byte{8, 6, 0, 0, 0})
+	// The number of frames, and playing forever
+	png.chunk("acTL", be32(uint32(len(recorder.frames))), be32(0))
+
+	sequence := uint32(0)
+	for i, data := range recorder.frames {
+		// Every frame covers the whole image and replaces the previous one
+		png.chunk("fcTL", be32(sequence), be32(width), be32(height), be32(0), be32(0),
+			be16(uint16(recorder.delays[i])), be16(apngDelayDenominator), []byte{0, 0})
+		sequence++
+		if i == 0 {
+			png.chunk("IDAT", data)
+		} else {
+			png.chunk("fdAT", be32(sequence), data)
+			sequence++
+		}
+	}
+	png.chunk("IEND")
+	return png.err
+}
+
+// pngWriter writes the chunks of a PNG file, keeping the first error
+type pngWriter struct {
+	output io.Writer
+	err    error
+}
+
+func (png *pngWriter) write(data []byte) {
+	if png.err == nil {
+		_, png.err = png.output.Write(data)
+	}
+}
+
+// chunk writes a chunk made of the given parts, with its length and its checksum
+func (png *pngWriter) chunk(name string, parts ...[]byte) {
+	body := []byte(name)
+	for _, part := range parts {
+		body = append(body, part...)
+	}
+	png.write(be32(uint32(len(body) - len(name))))
+	png.write(body)
+	png.write(be32(crc32.ChecksumIEEE(body)))
+}
+
+func be32(value uint32) []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, value)
+	return data
+}
+
+func be16(value uint16) []byte {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, value)
+	return data
+}
@@ -0,0 +1,126 @@
+// Package record writes the frames of the console into video files, either streams meant to be piped into
+// an encoder like ffmpeg or animated images for short clips, and the sound into WAV files
+package record
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Rate of the frames, the console draws a frame every 280896 cycles of its 16.78MHz clock, which is
+// 262144/4389 or about 59.7275 frames per second
+const (
+	FrameRateNumerator   = 262144
+	FrameRateDenominator = 4389
+	FrameRate            = float64(FrameRateNumerator) / FrameRateDenominator
+)
+
+// Format identifies a video format
+type Format int
+
+// Constants for defining the supported formats
+const (
+	Y4M Format = iota
+	RawRGB
+	GIF
+	APNG
+)
+
+// Formats lists every supported format
+var Formats = []Format{Y4M, RawRGB, GIF, APNG}
+
+var formatNames = map[Format]string{
+	Y4M:    "y4m",
+	RawRGB: "raw",
+	GIF:    "gif",
+	APNG:   "apng",
+}
+
+// Errors returned while recording
+var (
+	ErrUnknownFormat = errors.New("unknown video format")
+	ErrFrameSize     = errors.New("the frames of a video must all have the same size")
+)
+
+func (format Format) String() string {
+	return formatNames[format]
+}
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(name)
+	for format, formatName := range formatNames {
+		if formatName == name {
+			return format, nil
+		}
+	}
+	return 0, fmt.Errorf("%v: %s", ErrUnknownFormat, name)
+}
+
+// FormatOf guesses the format of a file from its extension, .png files being APNG and .rgb raw
+func FormatOf(path string) (Format, error) {
+	switch extension := strings.ToLower(filepath.Ext(path)); extension {
+	case ".png", ".apng":
+		return APNG, nil
+	case ".rgb", ".raw":
+		return RawRGB, nil
+	default:
+		return ParseFormat(strings.TrimPrefix(extension, "."))
+	}
+}
+
+// Recorder receives the frames of a video one after the other
+type Recorder interface {
+	WriteFrame(frame *image.RGBA) error
+	// Close finishes the video, the animated images are only written then
+	Close() error
+}
+
+// NewRecorder creates a recorder writing a video in the given format
+func NewRecorder(format Format, output io.Writer) (Recorder, error) {
+	switch format {
+	case Y4M:
+		return &y4mRecorder{output: output}, nil
+	case RawRGB:
+		return &rawRecorder{output: output}, nil
+	case GIF:
+		return &gifRecorder{output: output}, nil
+	case APNG:
+		return &apngRecorder{output: output}, nil
+	}
+	return nil, fmt.Errorf("%v: %d", ErrUnknownFormat, format)
+}
+
+// frameSize checks that every frame has the size of the first one
+type frameSize struct {
+	size image.Point
+}
+
+func (frameSize *frameSize) check(frame *image.RGBA) error {
+	size := frame.Rect.Size()
+	if frameSize.size == (image.Point{}) {
+		frameSize.size = size
+	} else if size != frameSize.size {
+		return fmt.Errorf("%v: %v instead of %v", ErrFrameSize, size, frameSize.size)
+	}
+	return nil
+}
+
+// frameDelays splits the time between the frames into whole units of an animated image format, carrying
+// the remainders over so that the clip keeps the speed of the console
+type frameDelays struct {
+	unitsPerSecond int
+	elapsed        int
+}
+
+// next returns the delay of the next frame, in units
+func (delays *frameDelays) next() int {
+	// Time elapsed since the first frame, in units multiplied by the numerator of the frame rate
+	start := delays.elapsed / FrameRateNumerator
+	delays.elapsed += delays.unitsPerSecond * FrameRateDenominator
+	return delays.elapsed/FrameRateNumerator - start
+}
@@ -0,0 +1,184 @@
+package record
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RecordTestSuite struct {
+	suite.Suite
+}
+
+func TestRecordTestSuite(t *testing.T) {
+	suite.Run(t, new(RecordTestSuite))
+}
+
+// solid returns a 4x2 frame of a single color
+func solid(c color.RGBA) *image.RGBA {
+	frame := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for i := 0; i < 8; i++ {
+		frame.SetRGBA(i%4, i/4, c)
+	}
+	return frame
+}
+
+// record writes frames in a format, returning the file
+func (suite *RecordTestSuite) record(format Format, frames ...*image.RGBA) []byte {
+	var output bytes.Buffer
+	recorder, err := NewRecorder(format, &output)
+	assert.Nil(suite.T(), err)
+	for _, frame := range frames {
+		assert.Nil(suite.T(), recorder.WriteFrame(frame))
+	}
+	assert.Nil(suite.T(), recorder.Close())
+	return output.Bytes()
+}
+
+func (suite *RecordTestSuite) TestFormats() {
+	for path, expected := range map[string]Format{"a.y4m": Y4M, "a.RGB": RawRGB, "a.gif": GIF, "a.png": APNG, "a.apng": APNG} {
+		format, err := FormatOf(path)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), expected, format)
+	}
+	_, err := FormatOf("a.mp4")
+	assert.NotNil(suite.T(), err)
+	assert.InDelta(suite.T(), 59.7275, FrameRate, 0.0001)
+}
+
+func (suite *RecordTestSuite) TestY4M() {
+	output := suite.record(Y4M, solid(color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}), solid(color.RGBA{0, 0, 0xFF, 0xFF}))
+	header := "YUV4MPEG2 W4 H2 F262144:4389 Ip A1:1 C444\n"
+	assert.Equal(suite.T(), header, string(output[:len(header)]))
+
+	frameSize := len("FRAME\n") + 8*3
+	assert.Equal(suite.T(), len(header)+frameSize*2, len(output))
+	white := output[len(header):]
+	assert.Equal(suite.T(), "FRAME\n", string(white[:6]))
+	assert.Equal(suite.T(), []byte{235, 128, 128}, []byte{white[6], white[6+8], white[6+16]})
+	blue := output[len(header)+frameSize+6:]
+	assert.Equal(suite.T(), []byte{41, 240, 110}, []byte{blue[0], blue[8], blue[16]})
+}
+
+func (suite *RecordTestSuite) TestRawRGB() {
+	output := suite.record(RawRGB, solid(color.RGBA{1, 2, 3, 0xFF}), solid(color.RGBA{4, 5, 6, 0xFF}))
+	assert.Equal(suite.T(), 2*8*3, len(output))
+	assert.Equal(suite.T(), []byte{1, 2, 3, 1, 2, 3}, output[:6])
+	assert.Equal(suite.T(), []byte{4, 5, 6}, output[24:27])
+}
+
+func (suite *RecordTestSuite) TestFrameSize() {
+	recorder, _ := NewRecorder(RawRGB, ioutil.Discard)
+	assert.Nil(suite.T(), recorder.WriteFrame(solid(color.RGBA{})))
+	assert.NotNil(suite.T(), recorder.WriteFrame(image.NewRGBA(image.Rect(0, 0, 2, 2))))
+}
+
+func (suite *RecordTestSuite) TestFrameDelays() {
+	// A second of frames takes a second, the delays of the frames taking turns between 1 and 2 hundredths
+	delays := frameDelays{unitsPerSecond: 100}
+	total := 0
+	for i := 0; i < FrameRateNumerator/FrameRateDenominator+1; i++ {
+		delay := delays.next()
+		assert.True(suite.T(), delay == 1 || delay == 2)
+		total += delay
+	}
+	assert.InDelta(suite.T(), 100, total, 2)
+}
+
+func (suite *RecordTestSuite) TestGIF() {
+	red, green := color.RGBA{0xFF, 0, 0, 0xFF}, color.RGBA{0, 0xFF, 0, 0xFF}
+	output := suite.record(GIF, solid(red), solid(red), solid(green), solid(green), solid(red), solid(red), solid(green))
+
+	// Every other frame is dropped, and the last one of a hundredth lasts two of them
+	animation, err := gif.DecodeAll(bytes.NewReader(output))
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 4, len(animation.Image))
+	assert.Equal(suite.T(), []int{3, 3, 4, 2}, animation.Delay)
+	r, g, b, _ := animation.Image[1].At(3, 1).RGBA()
+	assert.Equal(suite.T(), []uint32{0, 0xFFFF, 0}, []uint32{r, g, b})
+}
+
+func (suite *RecordTestSuite) TestGIFDelays() {
+	// Ten seconds of frames play in ten seconds at half the frame rate
+	frames := make([]*image.RGBA, 597)
+	for i := range frames {
+		frames[i] = solid(color.RGBA{uint8(i), 0, 0, 0xFF})
+	}
+	animation, err := gif.DecodeAll(bytes.NewReader(suite.record(GIF, frames...)))
+	assert.Nil(suite.T(), err)
+
+	total := 0
+	for _, delay := range animation.Delay {
+		assert.GreaterOrEqual(suite.T(), delay, gifMinDelay)
+		total += delay
+	}
+	assert.InDelta(suite.T(), 1000, total, 2)
+	assert.Equal(suite.T(), 299, len(animation.Image))
+}
+
+func (suite *RecordTestSuite) TestGIFWithManyColors() {
+	frame := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for i := 0; i < 32*32; i++ {
+		frame.SetRGBA(i%32, i/32, color.RGBA{uint8(i), uint8(i >> 2), uint8(i >> 8), 0xFF})
+	}
+	animation, err := gif.DecodeAll(bytes.NewReader(suite.record(GIF, frame)))
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 1, len(animation.Image))
+}
+
+func (suite *RecordTestSuite) TestAPNG() {
+	red, green := color.RGBA{0xFF, 0, 0, 0xFF}, color.RGBA{0, 0xFF, 0, 0xFF}
+	output := suite.record(APNG, solid(red), solid(green), solid(red))
+
+	// Viewers without animations show the first frame
+	first, err := png.Decode(bytes.NewReader(output))
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), red, color.RGBAModel.Convert(first.At(2, 1)))
+
+	// The chunks, with the delays of the frames
+	var chunks []string
+	var delays []uint16
+	for offset := 8; offset < len(output); {
+		length := int(binary.BigEndian.Uint32(output[offset:]))
+		name := string(output[offset+4 : offset+8])
+		chunks = append(chunks, name)
+		if name == "fcTL" {
+			delays = append(delays, binary.BigEndian.Uint16(output[offset+8+20:]))
+		}
+		offset += length + 12
+	}
+	assert.Equal(suite.T(), []string{"IHDR", "acTL", "fcTL", "IDAT", "fcTL", "fdAT", "fcTL", "fdAT", "IEND"}, chunks)
+	assert.Equal(suite.T(), []uint16{167, 167, 168}, delays)
+}
+
+func (suite *RecordTestSuite) TestWAV() {
+	directory, _ := ioutil.TempDir("", "gomu")
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "audio.wav")
+
+	file, _ := os.Create(path)
+	wav, err := NewWAVWriter(file, 32768)
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), wav.WriteSamples([]int16{1, -1, 2, -2}))
+	assert.Nil(suite.T(), wav.WriteSamples([]int16{3, -3}))
+	assert.Nil(suite.T(), wav.Close())
+	file.Close()
+
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal(suite.T(), 44+12, len(data))
+	assert.Equal(suite.T(), "RIFF", string(data[:4]))
+	assert.Equal(suite.T(), uint32(36+12), binary.LittleEndian.Uint32(data[4:]))
+	assert.Equal(suite.T(), uint32(32768), binary.LittleEndian.Uint32(data[24:]))
+	assert.Equal(suite.T(), uint32(12), binary.LittleEndian.Uint32(data[40:]))
+	assert.Equal(suite.T(), []byte{0x03, 0x00, 0xFD, 0xFF}, data[52:])
+}
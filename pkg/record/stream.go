@@ -0,0 +1,77 @@
+package record
+
+import (
+	"fmt"
+	"image"
+	"io"
+)
+
+// y4mRecorder writes a YUV4MPEG2 stream, which most video encoders read, with full chroma resolution
+type y4mRecorder struct {
+	frameSize
+	output io.Writer
+	buffer []byte
+}
+
+func (recorder *y4mRecorder) WriteFrame(frame *image.RGBA) error {
+	first := recorder.size == (image.Point{})
+	if err := recorder.check(frame); err != nil {
+		return err
+	}
+	if first {
+		header := fmt.Sprintf("YUV4MPEG2 W%d H%d F%d:%d Ip A1:1 C444\n",
+			recorder.size.X, recorder.size.Y, FrameRateNumerator, FrameRateDenominator)
+		if _, err := io.WriteString(recorder.output, header); err != nil {
+			return err
+		}
+		recorder.buffer = make([]byte, len("FRAME\n")+recorder.size.X*recorder.size.Y*3)
+	}
+
+	// The three planes follow each other, converted with the BT.601 coefficients in the limited range
+	copy(recorder.buffer, "FRAME\n")
+	planes := recorder.buffer[len("FRAME\n"):]
+	area := recorder.size.X * recorder.size.Y
+	for y := 0; y < recorder.size.Y; y++ {
+		for x := 0; x < recorder.size.X; x++ {
+			pixel := frame.RGBAAt(frame.Rect.Min.X+x, frame.Rect.Min.Y+y)
+			r, g, b := int(pixel.R), int(pixel.G), int(pixel.B)
+			i := y*recorder.size.X + x
+			planes[i] = byte((66*r+129*g+25*b+128)>>8 + 16)
+			planes[area+i] = byte((-38*r-74*g+112*b+128)>>8 + 128)
+			planes[area*2+i] = byte((112*r-94*g-18*b+128)>>8 + 128)
+		}
+	}
+	_, err := recorder.output.Write(recorder.buffer)
+	return err
+}
+
+func (recorder *y4mRecorder) Close() error {
+	return nil
+}
+
+// rawRecorder writes the frames as 24 bits RGB pixels without any header, ffmpeg reads them with
+// -f rawvideo -pixel_format rgb24 -video_size 240x160 -framerate 59.7275
+type rawRecorder struct {
+	frameSize
+	output io.Writer
+	buffer []byte
+}
+
+func (recorder *rawRecorder) WriteFrame(frame *image.RGBA) error {
+	if err := recorder.check(frame); err != nil {
+		return err
+	}
+	recorder.buffer = recorder.buffer[:0]
+	for y := frame.Rect.Min.Y; y < frame.Rect.Max.Y; y++ {
+		for x := frame.Rect.Min.X; x < frame.Rect.Max.X; x++ {
+			pixel := frame.RGBAAt(x, y)
+			recorder.buffer = append(recorder.buffer, pixel.R, pixel.G, pixel.B)
+		}
+	}
+	_, err := recorder.output.Write(recorder.buffer)
+	return err
+}
+
+func (recorder *rawRecorder) Close() error {
+	return nil
+}
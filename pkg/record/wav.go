@@ -0,0 +1,77 @@
+package record
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Size of the header of a WAV file, and where its two sizes are
+const (
+	wavHeaderSize     = 44
+	wavRIFFSizeOffset = 4
+	wavDataSizeOffset = 40
+)
+
+// WAVWriter writes 16 bits stereo samples into a WAV file, whose sizes are filled in when it's closed
+type WAVWriter struct {
+	output     io.WriteSeeker
+	sampleRate int
+	samples    int
+	err        error
+}
+
+// NewWAVWriter starts a WAV file with the given number of samples per second
+func NewWAVWriter(output io.WriteSeeker, sampleRate int) (*WAVWriter, error) {
+	wav := &WAVWriter{output: output, sampleRate: sampleRate}
+	header := make([]byte, wavHeaderSize)
+	copy(header[0:], "RIFF")
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	// PCM, 2 channels of 16 bits
+	binary.LittleEndian.PutUint16(header[20:], 1)
+	binary.LittleEndian.PutUint16(header[22:], 2)
+	binary.LittleEndian.PutUint32(header[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(sampleRate*4))
+	binary.LittleEndian.PutUint16(header[32:], 4)
+	binary.LittleEndian.PutUint16(header[34:], 16)
+	copy(header[36:], "data")
+	if _, err := output.Write(header); err != nil {
+		return nil, err
+	}
+	return wav, nil
+}
+
+// WriteSamples appends samples, interleaving the left and right channels
+func (wav *WAVWriter) WriteSamples(samples []int16) error {
+	if wav.err != nil {
+		return wav.err
+	}
+	data := make([]byte, len(samples)*2)
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(sample))
+	}
+	wav.samples += len(samples) / 2
+	_, wav.err = wav.output.Write(data)
+	return wav.err
+}
+
+// Close writes the sizes into the header
+func (wav *WAVWriter) Close() error {
+	if wav.err != nil {
+		return wav.err
+	}
+	dataSize := uint32(wav.samples * 4)
+	for _, field := range []struct {
+		offset int64
+		value  uint32
+	}{{wavRIFFSizeOffset, dataSize + wavHeaderSize - 8}, {wavDataSizeOffset, dataSize}} {
+		if _, err := wav.output.Seek(field.offset, io.SeekStart); err != nil {
+			return err
+		}
+		if err := binary.Write(wav.output, binary.LittleEndian, field.value); err != nil {
+			return err
+		}
+	}
+	_, err := wav.output.Seek(0, io.SeekEnd)
+	return err
+}